@@ -0,0 +1,31 @@
+package action
+
+import (
+	"context"
+
+	"github.com/k0sproject/k0sctl/phase"
+)
+
+// AirgapBundle builds an offline installation kit for the cluster
+type AirgapBundle struct {
+	// Manager is the phase manager
+	Manager *phase.Manager
+	// Output is the path of the kit tarball
+	Output string
+	// SkipImages leaves the airgap image bundles out of the kit
+	SkipImages bool
+}
+
+// Run the AirgapBundle action
+func (a AirgapBundle) Run(ctx context.Context) error {
+	a.Manager.AddPhase(
+		&phase.DefaultK0sVersion{},
+		&phase.Connect{},
+		&phase.DetectOS{},
+		&phase.GatherFacts{SkipMachineIDs: true},
+		&phase.BuildAirgapKit{Output: a.Output, SkipImages: a.SkipImages},
+		&phase.Disconnect{},
+	)
+
+	return a.Manager.Run(ctx)
+}
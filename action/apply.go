@@ -20,6 +20,9 @@ type ApplyOptions struct {
 	Manager *phase.Manager
 	// DisableDowngradeCheck skips the downgrade check
 	DisableDowngradeCheck bool
+	// UpgradePath is either phase.UpgradePathStrict to refuse upgrades that skip kubernetes minor versions or
+	// phase.UpgradePathAuto to upgrade through the intermediate minor versions
+	UpgradePath string
 	// NoWait skips waiting for the cluster to be ready
 	NoWait bool
 	// NoDrain skips draining worker nodes
@@ -36,6 +39,10 @@ type ApplyOptions struct {
 	KubeconfigCluster string
 	// ConfigPaths is the list of paths to the configuration files (used for kubeconfig command tip on success)
 	ConfigPaths []string
+	// Plan is a previously generated plan. When set, the apply is refused if the gathered facts no longer match it.
+	Plan *phase.Plan
+	// CheckConnectivity probes the k0s ports between the hosts before installing the controllers
+	CheckConnectivity bool
 }
 
 type Apply struct {
@@ -49,24 +56,27 @@ type Apply struct {
 //	gatherK0sFacts := &phase.GatherK0sFacts{} // advisable to get the title from the phase itself instead of hardcoding the title.
 //	apply.Phases.InsertBefore(gatherK0sFacts.Title(), &myCustomPhase{}) // insert a custom phase before the GatherK0sFacts phase
 func NewApply(opts ApplyOptions) *Apply {
-	// lockPhase := &phase.Lock{}
-	// unlockPhase := lockPhase.UnlockPhase()
+	lockPhase := &phase.Lock{}
+	unlockPhase := lockPhase.UnlockPhase()
+	clusterLockPhase := &phase.ClusterLock{}
 	apply := &Apply{
 		ApplyOptions: opts,
 		Phases: phase.Phases{
 			&phase.DefaultK0sVersion{},
 			&phase.Connect{},
 			&phase.DetectOS{},
-			// lockPhase,
+			lockPhase,
 			&phase.PrepareHosts{},
 			&phase.GatherFacts{},
 			&phase.ValidateHosts{},
 			&phase.GatherK0sFacts{},
-			&phase.ValidateFacts{SkipDowngradeCheck: opts.DisableDowngradeCheck},
+			clusterLockPhase,
+			&phase.ValidateFacts{SkipDowngradeCheck: opts.DisableDowngradeCheck, UpgradePath: opts.UpgradePath},
 			&phase.ValidateEtcdMembers{},
 			&phase.EnsureJoinTokenWorkaround{},
 			&phase.StageBinaries{},
 			&phase.UploadFiles{},
+			&phase.UploadAirgapBundles{},
 			&phase.InstallBinaries{},
 			&phase.PrepareArm{},
 			&phase.ConfigureK0s{},
@@ -77,16 +87,28 @@ func NewApply(opts ApplyOptions) *Apply {
 			&phase.InitializeK0s{},
 			&phase.InstallControllers{},
 			&phase.InstallWorkers{},
+			&phase.ReconcileNodeMetadata{},
+			&phase.UpgradePath{Mode: opts.UpgradePath, NoDrain: opts.NoDrain},
 			&phase.UpgradeControllers{},
 			&phase.UpgradeWorkers{NoDrain: opts.NoDrain},
+			&phase.UpgradeAutopilot{},
 			&phase.Reinstall{},
 			&phase.ResetWorkers{NoDrain: opts.NoDrain},
 			&phase.ResetControllers{NoDrain: opts.NoDrain},
 			&phase.RunHooks{Stage: "after", Action: "apply"},
 			&phase.ApplyManifests{},
-			// unlockPhase,
+			clusterLockPhase.UnlockPhase(),
+			unlockPhase,
 		},
 	}
+	if opts.Plan != nil {
+		validateFacts := &phase.ValidateFacts{}
+		apply.Phases.InsertAfter(validateFacts.Title(), &phase.PlanFacts{Verify: opts.Plan})
+	}
+	if opts.CheckConnectivity {
+		installControllers := &phase.InstallControllers{}
+		apply.Phases.InsertBefore(installControllers.Title(), &phase.CheckConnectivity{FailOnError: true})
+	}
 	if opts.KubeconfigOut != nil {
 		apply.Phases = append(apply.Phases, &phase.GetKubeconfig{APIAddress: opts.KubeconfigAPIAddress, User: opts.KubeconfigUser, Cluster: opts.KubeconfigCluster})
 	}
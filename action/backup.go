@@ -20,6 +20,7 @@ func (b Backup) Run(ctx context.Context) error {
 	start := time.Now()
 
 	lockPhase := &phase.Lock{}
+	clusterLockPhase := &phase.ClusterLock{}
 
 	b.Manager.AddPhase(
 		&phase.DefaultK0sVersion{},
@@ -29,7 +30,9 @@ func (b Backup) Run(ctx context.Context) error {
 		&phase.PrepareHosts{},
 		&phase.GatherFacts{SkipMachineIDs: true},
 		&phase.GatherK0sFacts{},
+		clusterLockPhase,
 		&phase.Backup{Out: b.Out},
+		&phase.Unlock{Cancel: clusterLockPhase.Cancel},
 		&phase.Unlock{Cancel: lockPhase.Cancel},
 		&phase.Disconnect{},
 	)
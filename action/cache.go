@@ -0,0 +1,127 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/k0sproject/k0sctl/pkg/k0s/binprovider"
+	"github.com/k0sproject/version"
+	log "github.com/sirupsen/logrus"
+)
+
+// CacheList lists the k0s binaries in the local cache
+type CacheList struct {
+	// Format is the output format, one of: table, json
+	Format string
+	// Verify compares the binaries to the checksums published with the k0s releases
+	Verify bool
+	Writer io.Writer
+}
+
+// Run the CacheList action
+func (c CacheList) Run(ctx context.Context) error {
+	binaries, err := binprovider.ListCache(ctx, c.Verify)
+	if err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case "json":
+		if binaries == nil {
+			binaries = []*binprovider.CachedBinary{}
+		}
+		enc := json.NewEncoder(c.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(binaries)
+	case "", "table":
+		w := tabwriter.NewWriter(c.Writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tOS\tARCH\tSIZE\tCHECKSUM\tPATH")
+		for _, b := range binaries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", b.Version, b.OS, b.Arch, humanSize(b.Size), b.Checksum, b.Path)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", c.Format)
+	}
+}
+
+// CachePrefetch downloads k0s binaries to the local cache
+type CachePrefetch struct {
+	// Version is the k0s version, the latest stable version when nil
+	Version *version.Version
+	// OS is the list of operating systems
+	OS []string
+	// Arch is the list of architectures
+	Arch []string
+}
+
+// Run the CachePrefetch action
+func (c CachePrefetch) Run(ctx context.Context) error {
+	v := c.Version
+	if v == nil {
+		log.Info("Looking up latest stable k0s version")
+		latest, err := version.LatestByPrerelease(false)
+		if err != nil {
+			return fmt.Errorf("failed to look up k0s version online - try setting --version manually: %w", err)
+		}
+		v = latest
+	}
+	for _, osKind := range c.OS {
+		for _, arch := range c.Arch {
+			path, err := binprovider.PrefetchBinary(ctx, osKind, arch, v)
+			if err != nil {
+				return fmt.Errorf("prefetch k0s %s for %s-%s: %w", v, osKind, arch, err)
+			}
+			log.Infof("k0s %s for %s-%s is cached at %s", v, osKind, arch, path)
+		}
+	}
+	return nil
+}
+
+// CachePrune removes old k0s binaries from the local cache
+type CachePrune struct {
+	// Keep is the number of the newest versions to keep for each os and arch
+	Keep int
+	// DryRun only lists the binaries that would be removed
+	DryRun bool
+}
+
+// Run the CachePrune action
+func (c CachePrune) Run(_ context.Context) error {
+	removed, err := binprovider.PruneCache(c.Keep, c.DryRun)
+	var total int64
+	for _, b := range removed {
+		total += b.Size
+		if c.DryRun {
+			log.Infof("would remove k0s %s for %s-%s (%s)", b.Version, b.OS, b.Arch, humanSize(b.Size))
+		} else {
+			log.Infof("removed k0s %s for %s-%s (%s)", b.Version, b.OS, b.Arch, humanSize(b.Size))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if c.DryRun {
+		log.Infof("%d binaries would be removed, freeing %s", len(removed), humanSize(total))
+	} else {
+		log.Infof("removed %d binaries, freed %s", len(removed), humanSize(total))
+	}
+	return nil
+}
+
+// humanSize formats a size in bytes using binary prefixes
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,91 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/k0sproject/k0sctl/phase"
+)
+
+// ErrDrift is returned by Diff when the live state of the cluster differs from the configuration
+var ErrDrift = errors.New("configuration drift detected")
+
+// Diff reports the differences between the configuration and the live state of the cluster
+type Diff struct {
+	// Manager is the phase manager
+	Manager *phase.Manager
+	// Format is the output format, one of: text, json
+	Format string
+	Writer io.Writer
+}
+
+// Run the Diff action
+func (d Diff) Run(ctx context.Context) error {
+	driftPhase := &phase.DetectDrift{}
+	d.Manager.AddPhase(
+		&phase.Connect{},
+		&phase.DetectOS{},
+		&phase.GatherFacts{SkipMachineIDs: true},
+		&phase.GatherK0sFacts{},
+		driftPhase,
+		&phase.Disconnect{},
+	)
+
+	if err := d.Manager.Run(ctx); err != nil {
+		return err
+	}
+
+	if err := d.write(driftPhase.Drifts); err != nil {
+		return err
+	}
+
+	if len(driftPhase.Drifts) > 0 {
+		return ErrDrift
+	}
+	return nil
+}
+
+func (d Diff) write(drifts []phase.Drift) error {
+	if d.Format == "json" {
+		if drifts == nil {
+			drifts = []phase.Drift{}
+		}
+		enc := json.NewEncoder(d.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(drifts)
+	}
+
+	if len(drifts) == 0 {
+		fmt.Fprintln(d.Writer, "no drift detected")
+		return nil
+	}
+
+	var host string
+	for _, drift := range drifts {
+		h := drift.Host
+		if h == "" {
+			h = "cluster"
+		}
+		if h != host {
+			fmt.Fprintf(d.Writer, "* %s:\n", h)
+			host = h
+		}
+		fmt.Fprintf(d.Writer, "  ~ [%s] %s\n", drift.Kind, drift.Message)
+		if drift.Expected != "" {
+			fmt.Fprintf(d.Writer, "      expected: %s\n", drift.Expected)
+		}
+		if drift.Actual != "" {
+			fmt.Fprintf(d.Writer, "      actual:   %s\n", drift.Actual)
+		}
+		if drift.Diff != "" {
+			for _, line := range strings.Split(strings.TrimRight(drift.Diff, "\n"), "\n") {
+				fmt.Fprintf(d.Writer, "      %s\n", line)
+			}
+		}
+	}
+	return nil
+}
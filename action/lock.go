@@ -0,0 +1,79 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/k0sproject/k0sctl/phase"
+)
+
+// LockStatus displays the k0sctl lock state of the hosts
+type LockStatus struct {
+	// Manager is the phase manager
+	Manager *phase.Manager
+	Writer  io.Writer
+}
+
+// Run the LockStatus action
+func (l LockStatus) Run(ctx context.Context) error {
+	statusPhase := &phase.LockStatus{}
+	l.Manager.AddPhase(
+		&phase.Connect{},
+		&phase.DetectOS{},
+		statusPhase,
+		&phase.Disconnect{},
+	)
+
+	if err := l.Manager.Run(ctx); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(l.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tSTATUS\tOWNER\tPID\tCOMMAND\tSTARTED\tEXPIRES")
+	for _, lock := range statusPhase.Locks {
+		if lock.Info == nil {
+			fmt.Fprintf(w, "%s\tunlocked\t-\t-\t-\t-\t-\n", lock.Host)
+			continue
+		}
+		status := "locked"
+		if lock.Expired {
+			status = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s@%s\t%d\t%s\t%s\t%s\n",
+			lock.Host,
+			status,
+			lock.Info.User,
+			lock.Info.Hostname,
+			lock.Info.PID,
+			lock.Info.Command,
+			lock.Info.Started.Format(time.RFC3339),
+			lock.Info.Expires().Format(time.RFC3339),
+		)
+	}
+	return w.Flush()
+}
+
+// LockBreak forcibly removes the k0sctl locks from the hosts
+type LockBreak struct {
+	// Manager is the phase manager
+	Manager *phase.Manager
+}
+
+// Run the LockBreak action
+func (l LockBreak) Run(ctx context.Context) error {
+	if !phase.Force {
+		return fmt.Errorf("breaking the locks may lead to concurrent operations on the cluster, --force is required to proceed")
+	}
+
+	l.Manager.AddPhase(
+		&phase.Connect{},
+		&phase.DetectOS{},
+		&phase.BreakLock{},
+		&phase.Disconnect{},
+	)
+
+	return l.Manager.Run(ctx)
+}
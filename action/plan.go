@@ -0,0 +1,55 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/k0sproject/k0sctl/phase"
+	log "github.com/sirupsen/logrus"
+)
+
+type PlanOptions struct {
+	// Manager is the phase manager
+	Manager *phase.Manager
+	// DisableDowngradeCheck skips the downgrade check
+	DisableDowngradeCheck bool
+	// UpgradePath is the upgrade path mode, see ApplyOptions.UpgradePath
+	UpgradePath string
+	// NoDrain skips draining worker nodes
+	NoDrain bool
+	// Out is where the plan is written to
+	Out io.Writer
+}
+
+// Plan performs a dry-run of the apply action and writes out a machine-readable plan of the actions
+// that the apply would perform.
+type Plan struct {
+	PlanOptions
+}
+
+// Run the Plan action
+func (p Plan) Run(ctx context.Context) error {
+	p.Manager.DryRun = true
+
+	apply := NewApply(ApplyOptions{
+		Manager:               p.Manager,
+		DisableDowngradeCheck: p.DisableDowngradeCheck,
+		UpgradePath:           p.UpgradePath,
+		NoDrain:               p.NoDrain,
+	})
+	validateFacts := &phase.ValidateFacts{}
+	apply.Phases.InsertAfter(validateFacts.Title(), &phase.PlanFacts{})
+
+	p.Manager.SetPhases(apply.Phases)
+	if err := p.Manager.Run(ctx); err != nil {
+		log.Info(phase.Colorize.Red("==> Plan failed").String())
+		return err
+	}
+
+	if err := p.Manager.Plan().Write(p.Out); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+
+	return nil
+}
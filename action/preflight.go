@@ -0,0 +1,146 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/k0sproject/k0sctl/phase"
+)
+
+// ErrPreflightFailed is returned by Preflight when a preflight check failed
+var ErrPreflightFailed = errors.New("preflight checks failed")
+
+// Preflight runs the preflight checks on the hosts without changing anything
+type Preflight struct {
+	// Manager is the phase manager
+	Manager *phase.Manager
+	// Format is the output format, one of: table, json
+	Format string
+	// Strict makes warnings fail the checks
+	Strict bool
+	Writer io.Writer
+}
+
+// Run the Preflight action
+func (p Preflight) Run(ctx context.Context) error {
+	preflightPhase := &phase.Preflight{}
+	connectivityPhase := &phase.CheckConnectivity{}
+	p.Manager.AddPhase(
+		&phase.Connect{},
+		&phase.DetectOS{},
+		&phase.GatherFacts{SkipMachineIDs: true},
+		preflightPhase,
+		connectivityPhase,
+		&phase.Disconnect{},
+	)
+
+	if err := p.Manager.Run(ctx); err != nil {
+		return err
+	}
+
+	results := slices.Concat(preflightPhase.Results, connectivityResults(connectivityPhase.Pairs()))
+	if err := p.write(results); err != nil {
+		return err
+	}
+	if p.Format != "json" {
+		if err := p.writeMatrix(connectivityPhase.Pairs()); err != nil {
+			return err
+		}
+	}
+
+	var errs, warns int
+	for _, r := range results {
+		switch r.Severity {
+		case phase.PreflightError:
+			errs++
+		case phase.PreflightWarn:
+			warns++
+		}
+	}
+	if errs > 0 || (p.Strict && warns > 0) {
+		return fmt.Errorf("%w: %d errors, %d warnings", ErrPreflightFailed, errs, warns)
+	}
+	return nil
+}
+
+func (p Preflight) write(results []phase.PreflightResult) error {
+	switch p.Format {
+	case "json":
+		if results == nil {
+			results = []phase.PreflightResult{}
+		}
+		enc := json.NewEncoder(p.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case "", "table":
+		w := tabwriter.NewWriter(p.Writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tCHECK\tSEVERITY\tMESSAGE")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Host, r.Check, r.Severity, r.Message)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", p.Format)
+	}
+}
+
+// connectivityResults converts the connectivity check results into preflight results
+func connectivityResults(pairs []phase.ConnectivityPair) []phase.PreflightResult {
+	results := make([]phase.PreflightResult, 0, len(pairs))
+	for _, pair := range pairs {
+		r := phase.PreflightResult{Host: pair.From, Check: "connectivity"}
+		switch pair.Status() {
+		case phase.ConnectivityFailed:
+			r.Severity = phase.PreflightError
+			r.Message = fmt.Sprintf("can not connect to %s on ports %s", pair.To, strings.Join(pair.Failed, ", "))
+		case phase.ConnectivityUnknown:
+			r.Severity = phase.PreflightWarn
+			r.Message = fmt.Sprintf("could not verify connectivity to %s on ports %s", pair.To, strings.Join(pair.Unknown, ", "))
+		default:
+			r.Severity = phase.PreflightOK
+			r.Message = fmt.Sprintf("can connect to %s on ports %s", pair.To, strings.Join(pair.OK, ", "))
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// writeMatrix writes the connectivity between the hosts as a matrix with the sources as the rows
+// and the targets as the columns
+func (p Preflight) writeMatrix(pairs []phase.ConnectivityPair) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+	var hosts []string
+	status := make(map[[2]string]string, len(pairs))
+	for _, pair := range pairs {
+		for _, h := range []string{pair.From, pair.To} {
+			if !slices.Contains(hosts, h) {
+				hosts = append(hosts, h)
+			}
+		}
+		status[[2]string{pair.From, pair.To}] = pair.Status()
+	}
+
+	fmt.Fprintln(p.Writer)
+	w := tabwriter.NewWriter(p.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FROM \\ TO\t"+strings.Join(hosts, "\t"))
+	for _, from := range hosts {
+		row := []string{from}
+		for _, to := range hosts {
+			s, ok := status[[2]string{from, to}]
+			if !ok {
+				s = "-"
+			}
+			row = append(row, s)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
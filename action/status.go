@@ -0,0 +1,125 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/k0sproject/k0sctl/phase"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrDegraded is returned by Status when problems were found on any of the hosts
+var ErrDegraded = errors.New("cluster is degraded")
+
+// Status displays the health status of the cluster hosts
+type Status struct {
+	// Manager is the phase manager
+	Manager *phase.Manager
+	// Format is the output format, one of: table, json, yaml
+	Format string
+	Writer io.Writer
+}
+
+// Run the Status action
+func (s Status) Run(ctx context.Context) error {
+	statusPhase := &phase.ClusterStatus{}
+	s.Manager.AddPhase(
+		&phase.Connect{},
+		&phase.DetectOS{},
+		&phase.GatherFacts{SkipMachineIDs: true},
+		&phase.GatherK0sFacts{},
+		statusPhase,
+		&phase.Disconnect{},
+	)
+
+	if err := s.Manager.Run(ctx); err != nil {
+		return err
+	}
+
+	if err := s.write(statusPhase.Hosts); err != nil {
+		return err
+	}
+
+	if statusPhase.Degraded() {
+		return ErrDegraded
+	}
+	return nil
+}
+
+func (s Status) write(hosts []phase.HostStatus) error {
+	switch s.Format {
+	case "json":
+		enc := json.NewEncoder(s.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(hosts)
+	case "yaml":
+		out, err := yaml.Marshal(hosts)
+		if err != nil {
+			return fmt.Errorf("failed to encode status: %w", err)
+		}
+		_, err = s.Writer.Write(out)
+		return err
+	case "", "table":
+		return s.writeTable(hosts)
+	default:
+		return fmt.Errorf("unknown output format %q", s.Format)
+	}
+}
+
+func (s Status) writeTable(hosts []phase.HostStatus) error {
+	w := tabwriter.NewWriter(s.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tROLE\tINSTALLED\tRUNNING\tSERVICE\tREADY\tETCD\tCONFIG\tUPGRADE\tSTATUS")
+	for _, h := range hosts {
+		config := "-"
+		if h.ConfigMatches != nil {
+			config = "match"
+			if !*h.ConfigMatches {
+				config = "differs"
+			}
+		}
+		upgrade := "-"
+		if h.UpgradePending {
+			upgrade = "pending"
+		}
+		status := "ok"
+		if h.Degraded() {
+			status = "degraded: " + strings.Join(h.Problems, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			h.Host,
+			h.Role,
+			dashIfEmpty(h.InstalledVersion),
+			dashIfEmpty(h.RunningVersion),
+			h.Service,
+			yesNo(h.NodeReady),
+			yesNo(h.EtcdMember),
+			config,
+			upgrade,
+			status,
+		)
+	}
+	return w.Flush()
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func yesNo(b *bool) string {
+	switch {
+	case b == nil:
+		return "-"
+	case *b:
+		return "yes"
+	default:
+		return "no"
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/action"
+	"github.com/k0sproject/k0sctl/phase"
+
+	"github.com/urfave/cli/v2"
+)
+
+var airgapBundleCommand = &cli.Command{
+	Name:  "bundle",
+	Usage: "Build an offline installation kit for the cluster",
+	Description: "Connects to the hosts to detect their operating systems and architectures and writes a tarball with the k0s binaries, " +
+		"the airgap image bundles and the uploaded files of every host together with a k0sctl.yaml that refers to the bundled copies. " +
+		"Extract the kit in a disconnected environment and run k0sctl apply with the bundled k0sctl.yaml.",
+	Flags: []cli.Flag{
+		configFlag,
+		overlayFlag,
+		clusterFlag,
+		concurrencyFlag,
+		debugFlag,
+		traceFlag,
+		redactFlag,
+		timeoutFlag,
+		&cli.StringFlag{
+			Name:    "output",
+			Usage:   "Path of the kit tarball",
+			Aliases: []string{"o"},
+			Value:   "k0sctl-airgap-kit.tar.gz",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-images",
+			Usage: "Leave the airgap image bundles out of the kit",
+		},
+	},
+	Before: actions(initLogging, initConfig, initManager),
+	After:  actions(cancelTimeout),
+	Action: func(ctx *cli.Context) error {
+		bundleAction := action.AirgapBundle{
+			Manager:    ctx.Context.Value(ctxManagerKey{}).(*phase.Manager),
+			Output:     ctx.String("output"),
+			SkipImages: ctx.Bool("skip-images"),
+		}
+
+		if err := bundleAction.Run(ctx.Context); err != nil {
+			return fmt.Errorf("airgap bundle failed - log file saved to %s: %w", ctx.Context.Value(ctxLogFileKey{}).(string), err)
+		}
+
+		return nil
+	},
+}
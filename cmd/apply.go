@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"strings"
 
+	"github.com/adrg/xdg"
 	"github.com/k0sproject/k0sctl/action"
 	"github.com/k0sproject/k0sctl/phase"
 	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
@@ -19,6 +23,8 @@ var applyCommand = &cli.Command{
 	Usage: "Apply a k0sctl configuration",
 	Flags: []cli.Flag{
 		configFlag,
+		overlayFlag,
+		clusterFlag,
 		concurrencyFlag,
 		concurrentUploadsFlag,
 		dryRunFlag,
@@ -54,11 +60,26 @@ var applyCommand = &cli.Command{
 			Usage:       "Set kubernetes cluster name",
 			DefaultText: "k0s-cluster",
 		},
+		&cli.StringFlag{
+			Name:      "plan",
+			Aliases:   []string{"plan-file"},
+			Usage:     "Path to a plan generated with \"k0sctl plan\". The apply is refused if the cluster state no longer matches the plan",
+			TakesFile: true,
+		},
+		&cli.BoolFlag{
+			Name:  "resume",
+			Usage: "Resume a previously interrupted apply from its checkpoint, skipping the phases and hosts that already completed",
+		},
+		&cli.BoolFlag{
+			Name:  "check-connectivity",
+			Usage: "Probe the k0s ports between the hosts before installing the controllers and fail if they can not be reached",
+		},
 		&cli.BoolFlag{
 			Name:   "disable-downgrade-check",
 			Usage:  "Skip downgrade check",
 			Hidden: true,
 		},
+		upgradePathFlag,
 		&cli.StringFlag{
 			Name:  "evict-taint",
 			Usage: "Taint to be applied to nodes before draining and removed after uncordoning in the format of <key=value>:<effect> (default: from spec.options.evictTaint)",
@@ -67,6 +88,7 @@ var applyCommand = &cli.Command{
 		debugFlag,
 		traceFlag,
 		redactFlag,
+		outputFormatFlag,
 		retryIntervalFlag,
 		retryTimeoutFlag,
 		timeoutFlag,
@@ -107,6 +129,25 @@ var applyCommand = &cli.Command{
 			}
 		}
 
+		checkpoint, err := applyCheckpoint(ctx.Bool("resume"), manager.Config.Metadata.Name)
+		if err != nil {
+			return err
+		}
+		manager.Checkpoint = checkpoint
+		// the progress recorded so far is kept when the apply is interrupted without waiting for it to stop
+		onForcedExit(manager.SaveCheckpoint)
+
+		var plan *phase.Plan
+		if planFile := ctx.String("plan"); planFile != "" {
+			if manager.DryRun {
+				return fmt.Errorf("--plan can not be used together with --dry-run")
+			}
+			plan, err = phase.ReadPlanFile(planFile)
+			if err != nil {
+				return err
+			}
+		}
+
 		applyOpts := action.ApplyOptions{
 			Manager:               manager,
 			KubeconfigOut:         kubeconfigOut,
@@ -116,8 +157,11 @@ var applyCommand = &cli.Command{
 			NoWait:                ctx.Bool("no-wait") || !manager.Config.Spec.Options.Wait.EnabledValue(),
 			NoDrain:               getNoDrainFlagOrConfig(ctx, manager.Config.Spec.Options.Drain),
 			DisableDowngradeCheck: ctx.Bool("disable-downgrade-check"),
+			UpgradePath:           ctx.String("upgrade-path"),
 			RestoreFrom:           ctx.String("restore-from"),
 			ConfigPaths:           ctx.StringSlice("config"),
+			Plan:                  plan,
+			CheckConnectivity:     ctx.Bool("check-connectivity"),
 		}
 
 		applyAction := action.NewApply(applyOpts)
@@ -130,6 +174,27 @@ var applyCommand = &cli.Command{
 	},
 }
 
+// applyCheckpoint returns the checkpoint for recording the apply progress of the named cluster. When resume is
+// true, the checkpoint left behind by a previous failed apply is loaded.
+func applyCheckpoint(resume bool, clusterName string) (*phase.Checkpoint, error) {
+	checkpointPath, err := xdg.StateFile(path.Join("k0sctl", "checkpoints", clusterName+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine checkpoint path: %w", err)
+	}
+	if !resume {
+		return phase.NewCheckpoint(checkpointPath), nil
+	}
+	checkpoint, err := phase.LoadCheckpoint(checkpointPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no checkpoint found for cluster %q, nothing to resume", clusterName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Resuming from checkpoint %s", checkpointPath)
+	return checkpoint, nil
+}
+
 func getNoDrainFlagOrConfig(ctx *cli.Context, drain cluster.DrainOption) bool {
 	if ctx.IsSet("no-drain") {
 		return ctx.Bool("no-drain")
@@ -23,12 +23,15 @@ var backupCommand = &cli.Command{
 			Usage:   "Output path for the backup. Default is k0s_backup_<timestamp>.tar.gz in current directory",
 		},
 		configFlag,
+		overlayFlag,
+		clusterFlag,
 		dryRunFlag,
 		concurrencyFlag,
 		forceFlag,
 		debugFlag,
 		traceFlag,
 		redactFlag,
+		outputFormatFlag,
 		timeoutFlag,
 		retryIntervalFlag,
 		retryTimeoutFlag,
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/action"
+	"github.com/k0sproject/version"
+
+	"github.com/urfave/cli/v2"
+)
+
+var cacheListCommand = &cli.Command{
+	Name:  "list",
+	Usage: "List the k0s binaries in the local cache",
+	Flags: []cli.Flag{
+		debugFlag,
+		traceFlag,
+		&cli.StringFlag{
+			Name:    "output",
+			Usage:   "Output format, one of: table, json",
+			Aliases: []string{"o"},
+			Value:   "table",
+			Action: func(_ *cli.Context, format string) error {
+				if format != "table" && format != "json" {
+					return fmt.Errorf("invalid output format %q, expected table or json", format)
+				}
+				return nil
+			},
+		},
+		&cli.BoolFlag{
+			Name:  "verify",
+			Usage: "Compare the binaries to the checksums published with the k0s releases, downloads the checksums of each cached release",
+		},
+	},
+	Before: actions(initSilentLogging),
+	Action: func(ctx *cli.Context) error {
+		listAction := action.CacheList{
+			Format: ctx.String("output"),
+			Verify: ctx.Bool("verify"),
+			Writer: ctx.App.Writer,
+		}
+		return listAction.Run(ctx.Context)
+	},
+}
+
+var cachePrefetchCommand = &cli.Command{
+	Name:  "prefetch",
+	Usage: "Download k0s binaries to the local cache",
+	Flags: []cli.Flag{
+		debugFlag,
+		traceFlag,
+		&cli.StringFlag{
+			Name:  "version",
+			Usage: "k0s version to download, the latest stable version when not set",
+		},
+		&cli.StringSliceFlag{
+			Name:  "os",
+			Usage: "Operating systems to download the binaries for, one of: linux, windows",
+			Value: cli.NewStringSlice("linux"),
+		},
+		&cli.StringSliceFlag{
+			Name:  "arch",
+			Usage: "Architectures to download the binaries for, for example amd64, arm64 or arm",
+			Value: cli.NewStringSlice("amd64"),
+		},
+	},
+	Before: actions(initLogging),
+	Action: func(ctx *cli.Context) error {
+		prefetchAction := action.CachePrefetch{
+			OS:   ctx.StringSlice("os"),
+			Arch: ctx.StringSlice("arch"),
+		}
+		if s := ctx.String("version"); s != "" {
+			v, err := version.NewVersion(s)
+			if err != nil {
+				return fmt.Errorf("invalid version %q: %w", s, err)
+			}
+			prefetchAction.Version = v
+		}
+		return prefetchAction.Run(ctx.Context)
+	},
+}
+
+var cachePruneCommand = &cli.Command{
+	Name:  "prune",
+	Usage: "Remove old k0s binaries from the local cache",
+	Flags: []cli.Flag{
+		debugFlag,
+		traceFlag,
+		&cli.IntFlag{
+			Name:  "keep",
+			Usage: "Number of the newest versions to keep for each OS and architecture",
+			Value: 2,
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only list the binaries that would be removed",
+		},
+	},
+	Before: actions(initLogging),
+	Action: func(ctx *cli.Context) error {
+		pruneAction := action.CachePrune{
+			Keep:   ctx.Int("keep"),
+			DryRun: ctx.Bool("dry-run"),
+		}
+		return pruneAction.Run(ctx.Context)
+	},
+}
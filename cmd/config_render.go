@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/pkg/secret"
+	"github.com/urfave/cli/v2"
+)
+
+var configRenderCommand = &cli.Command{
+	Name:  "render",
+	Usage: "Print the configuration with the overlays applied and the host defaults and groups merged into the hosts",
+	Flags: []cli.Flag{
+		configFlag,
+		overlayFlag,
+		clusterFlag,
+		debugFlag,
+		traceFlag,
+		redactFlag,
+	},
+	Before: actions(initSilentLogging, initConfig),
+	Action: func(ctx *cli.Context) error {
+		cfg, err := readConfig(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(ctx.App.Writer, secret.Redact(cfg.String()))
+		return err
+	},
+}
@@ -11,6 +11,8 @@ var configStatusCommand = &cli.Command{
 	Usage: "Show k0s dynamic config reconciliation events",
 	Flags: []cli.Flag{
 		configFlag,
+		overlayFlag,
+		clusterFlag,
 		forceFlag,
 		debugFlag,
 		traceFlag,
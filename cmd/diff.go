@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/action"
+	"github.com/k0sproject/k0sctl/phase"
+
+	"github.com/urfave/cli/v2"
+)
+
+// driftExitCode is the exit code used by the diff command when drift was detected
+const driftExitCode = 2
+
+var diffCommand = &cli.Command{
+	Name:  "diff",
+	Usage: "Show differences between the configuration and the live state of the hosts",
+	Description: "Gathers facts from the hosts without changing anything and reports drift from the configuration: " +
+		"k0s version, install flags, k0s configuration, uploaded files, environment and kubernetes nodes or etcd members that are not in the configuration. " +
+		"Exits with exit code 2 when drift was detected.",
+	Flags: []cli.Flag{
+		configFlag,
+		overlayFlag,
+		clusterFlag,
+		concurrencyFlag,
+		forceFlag,
+		debugFlag,
+		traceFlag,
+		redactFlag,
+		timeoutFlag,
+		&cli.StringFlag{
+			Name:    "output",
+			Usage:   "Output format, one of: text, json",
+			Aliases: []string{"o"},
+			Value:   "text",
+			Action: func(_ *cli.Context, format string) error {
+				if format != "text" && format != "json" {
+					return fmt.Errorf("invalid output format %q, expected text or json", format)
+				}
+				return nil
+			},
+		},
+	},
+	Before: actions(initSilentLogging, initConfig, initManager),
+	After:  actions(cancelTimeout),
+	Action: func(ctx *cli.Context) error {
+		diffAction := action.Diff{
+			Manager: ctx.Context.Value(ctxManagerKey{}).(*phase.Manager),
+			Format:  ctx.String("output"),
+			Writer:  ctx.App.Writer,
+		}
+
+		if err := diffAction.Run(ctx.Context); err != nil {
+			if errors.Is(err, action.ErrDrift) {
+				return cli.Exit(err.Error(), driftExitCode)
+			}
+			return fmt.Errorf("diff failed - log file saved to %s: %w", ctx.Context.Value(ctxLogFileKey{}).(string), err)
+		}
+
+		return nil
+	},
+}
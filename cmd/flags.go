@@ -9,6 +9,7 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
@@ -19,13 +20,16 @@ import (
 	"github.com/k0sproject/k0sctl/phase"
 	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
 	"github.com/k0sproject/k0sctl/pkg/manifest"
+	"github.com/k0sproject/k0sctl/pkg/overlay"
 	"github.com/k0sproject/k0sctl/pkg/retry"
+	"github.com/k0sproject/k0sctl/pkg/secret"
 	k0sctl "github.com/k0sproject/k0sctl/version"
 	"github.com/k0sproject/rig/v2/cmd"
 	"github.com/logrusorgru/aurora"
 	"github.com/shiena/ansicolor"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
 )
 
 type (
@@ -59,6 +63,30 @@ var (
 		Hidden:  false,
 	}
 
+	outputFormatFlag = &cli.StringFlag{
+		Name:  "output-format",
+		Usage: "Progress output format, one of: text, json. With json, progress events are written to stdout as newline delimited JSON and the log is written to stderr",
+		Value: "text",
+		Action: func(_ *cli.Context, format string) error {
+			if format != "text" && format != "json" {
+				return fmt.Errorf("invalid output format %q, expected text or json", format)
+			}
+			return nil
+		},
+	}
+
+	upgradePathFlag = &cli.StringFlag{
+		Name:  "upgrade-path",
+		Usage: "How to handle upgrades that skip kubernetes minor versions, one of: strict, auto. With strict, such upgrades are refused. With auto, the cluster is upgraded through the latest k0s release of each skipped minor version",
+		Value: phase.UpgradePathStrict,
+		Action: func(_ *cli.Context, mode string) error {
+			if mode != phase.UpgradePathStrict && mode != phase.UpgradePathAuto {
+				return fmt.Errorf("invalid upgrade path %q, expected strict or auto", mode)
+			}
+			return nil
+		},
+	}
+
 	redactFlag = &cli.BoolFlag{
 		Name:  "no-redact",
 		Usage: "Do not hide sensitive information in the output",
@@ -82,6 +110,17 @@ var (
 		TakesFile: true,
 	}
 
+	clusterFlag = &cli.StringFlag{
+		Name:  "cluster",
+		Usage: "Name (metadata.name) of the cluster to use when the configuration has multiple clusters",
+	}
+
+	overlayFlag = &cli.StringSliceFlag{
+		Name:      "overlay",
+		Usage:     "Path to a config overlay yaml to apply on top of the cluster config, a strategic merge patch or a JSON patch. Can be given multiple times.",
+		TakesFile: true,
+	}
+
 	concurrencyFlag = &cli.IntFlag{
 		Name:  "concurrency",
 		Usage: "Maximum number of hosts to configure in parallel, set to 0 for unlimited",
@@ -256,7 +295,15 @@ func initConfig(ctx *cli.Context) error {
 	return nil
 }
 
+// jsonOutput returns true when the progress output should be written as JSON events
+func jsonOutput(ctx *cli.Context) bool {
+	return ctx.String("output-format") == "json"
+}
+
 func displayCopyright(ctx *cli.Context) error {
+	if jsonOutput(ctx) {
+		return nil
+	}
 	fmt.Fprintf(ctx.App.Writer, "k0sctl %s Copyright 2026, k0sctl authors.\n", k0sctl.Version)
 	return nil
 }
@@ -302,22 +349,42 @@ func readConfig(ctx *cli.Context) (*v1beta1.Cluster, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster resources: %w", err)
 	}
-	if len(ctlConfigs) != 1 {
-		return nil, fmt.Errorf("expected exactly one cluster config, got %d", len(ctlConfigs))
+	clusterConfig, err := selectCluster(ctx, ctlConfigs)
+	if err != nil {
+		return nil, err
+	}
+	belongs := clusterResourceFilter(clusterConfig, ctlConfigs)
+	if overlays := ctx.StringSlice("overlay"); len(overlays) > 0 {
+		raw, err := applyOverlays(ctx, clusterConfig.Raw, overlays)
+		if err != nil {
+			return nil, err
+		}
+		patched := *clusterConfig
+		patched.Raw = raw
+		clusterConfig = &patched
+	}
+	clusterConfig, err = resolveSecrets(ctx.Context, clusterConfig)
+	if err != nil {
+		return nil, err
 	}
 	cfg := &v1beta1.Cluster{}
-	if err := ctlConfigs[0].Unmarshal(cfg); err != nil {
+	if err := clusterConfig.Unmarshal(cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal cluster config: %w", err)
 	}
-	cfg.Origin = ctlConfigs[0].Origin
+	cfg.Origin = clusterConfig.Origin
 	if err := cfg.Resolve(configBaseDir(cfg.Origin)); err != nil {
-		return nil, fmt.Errorf("failed to resolve upload file paths: %w", err)
+		return nil, fmt.Errorf("failed to resolve cluster config: %w", err)
 	}
 	if k0sConfigs, err := mr.GetResources("k0s.k0sproject.io/v1beta1", "ClusterConfig"); err == nil && len(k0sConfigs) > 0 {
+		k0sConfigs = slices.DeleteFunc(k0sConfigs, func(rd *manifest.ResourceDefinition) bool { return !belongs(rd) })
 		if cfg.Spec.K0s.Config == nil {
 			cfg.Spec.K0s.Config = make(dig.Mapping)
 		}
 		for _, k0sConfig := range k0sConfigs {
+			k0sConfig, err := resolveSecrets(ctx.Context, k0sConfig)
+			if err != nil {
+				return nil, err
+			}
 			k0s := make(dig.Mapping)
 			log.Debugf("unmarshalling %d bytes of config from %v", len(k0sConfig.Raw), k0sConfig.Filename())
 			if err := k0sConfig.Unmarshal(&k0s); err != nil {
@@ -334,11 +401,13 @@ func readConfig(ctx *cli.Context) (*v1beta1.Cluster, error) {
 		if strings.EqualFold(rd.APIVersion, "k0s.k0sproject.io/v1beta1") && strings.EqualFold(rd.Kind, "clusterconfig") {
 			return false
 		}
-		return true
+		return belongs(rd)
 	})
 	if len(otherConfigs) > 0 {
 		cfg.Metadata.Manifests = make(map[string][]byte)
 		log.Debugf("found %d additional resources in the configuration", len(otherConfigs))
+		// secret references are not resolved in the other resources, a secretRef mapping is
+		// common in kubernetes manifests, for example in envFrom of a container
 		for _, otherConfig := range otherConfigs {
 			log.Debugf("found resource: %s (%d bytes)", otherConfig.Filename(), len(otherConfig.Raw))
 			cfg.Metadata.Manifests[otherConfig.Filename()] = otherConfig.Raw
@@ -351,6 +420,157 @@ func readConfig(ctx *cli.Context) (*v1beta1.Cluster, error) {
 	return cfg, nil
 }
 
+// resourceClusterName returns the name of the cluster a Cluster resource describes
+func resourceClusterName(rd *manifest.ResourceDefinition) string {
+	if rd.Metadata.Name == "" {
+		return v1beta1.DefaultClusterName
+	}
+	return rd.Metadata.Name
+}
+
+// selectCluster returns the Cluster resource selected with --cluster. The flag is required when
+// the configuration has multiple Cluster resources.
+func selectCluster(ctx *cli.Context, clusters []*manifest.ResourceDefinition) (*manifest.ResourceDefinition, error) {
+	names := make([]string, len(clusters))
+	for i, rd := range clusters {
+		names[i] = resourceClusterName(rd)
+	}
+
+	name := ctx.String("cluster")
+	if name == "" {
+		if len(clusters) != 1 {
+			return nil, fmt.Errorf("expected exactly one cluster config, got %d (%s), use --cluster to select one", len(clusters), strings.Join(names, ", "))
+		}
+		return clusters[0], nil
+	}
+
+	// the --cluster flag of the kubeconfig command sets the cluster name in the kubeconfig, it
+	// only selects the cluster when there are multiple ones to choose from
+	if len(clusters) == 1 && ctx.Command != nil && !slices.Contains(ctx.Command.Flags, cli.Flag(clusterFlag)) {
+		return clusters[0], nil
+	}
+
+	var selected *manifest.ResourceDefinition
+	for i, rd := range clusters {
+		if names[i] != name {
+			continue
+		}
+		if selected != nil {
+			return nil, fmt.Errorf("multiple cluster configs named %q found in %s and %s", name, selected.Origin, rd.Origin)
+		}
+		selected = rd
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("cluster %q not found in the configuration, available clusters: %s", name, strings.Join(names, ", "))
+	}
+	log.Debugf("using cluster %s from %s", name, selected.Origin)
+	return selected, nil
+}
+
+// clusterResourceFilter returns a function that tells if a resource in the configuration belongs
+// to the selected cluster. A resource with the cluster annotation belongs to the named cluster.
+// Other resources belong to the clusters in the closest directory containing the resource's file,
+// the resources outside of all the cluster directories are shared by all the clusters.
+func clusterResourceFilter(selected *manifest.ResourceDefinition, clusters []*manifest.ResourceDefinition) func(*manifest.ResourceDefinition) bool {
+	name := resourceClusterName(selected)
+	if len(clusters) == 1 {
+		return func(rd *manifest.ResourceDefinition) bool {
+			owner, ok := rd.Metadata.Annotations[v1beta1.ClusterAnnotation]
+			return !ok || owner == name
+		}
+	}
+
+	return func(rd *manifest.ResourceDefinition) bool {
+		if owner, ok := rd.Metadata.Annotations[v1beta1.ClusterAnnotation]; ok {
+			return owner == name
+		}
+		dir := configBaseDir(rd.Origin)
+		closest := ""
+		var owners []string
+		for _, c := range clusters {
+			cdir := configBaseDir(c.Origin)
+			if !isSubPath(cdir, dir) || len(cdir) < len(closest) {
+				continue
+			}
+			if len(cdir) > len(closest) {
+				closest = cdir
+				owners = owners[:0]
+			}
+			owners = append(owners, resourceClusterName(c))
+		}
+		return len(owners) == 0 || slices.Contains(owners, name)
+	}
+}
+
+// isSubPath returns true when path is the same as dir or a path under it
+func isSubPath(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.FromSlash(dir), filepath.FromSlash(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// applyOverlays applies the overlay files in the given order on top of the cluster config
+func applyOverlays(ctx *cli.Context, raw []byte, files []string) ([]byte, error) {
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read overlay: %w", err)
+		}
+		subst, err := envsubst.Bytes(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to substitute variables in overlay %s: %w", f, err)
+		}
+		resolved, err := resolveSecretRefs(ctx.Context, subst, configBaseDir(f))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve secret references in %s: %w", f, err)
+		}
+		o, err := overlay.Parse(resolved, f)
+		if err != nil {
+			return nil, err
+		}
+		log.Debugf("applying config overlay %s", f)
+		raw, err = o.Apply(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// resolveSecrets returns the resource definition with the secret references replaced by the
+// referenced values
+func resolveSecrets(ctx context.Context, rd *manifest.ResourceDefinition) (*manifest.ResourceDefinition, error) {
+	raw, err := resolveSecretRefs(ctx, rd.Raw, configBaseDir(rd.Origin))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secret references in %s: %w", rd.Origin, err)
+	}
+	if bytes.Equal(raw, rd.Raw) {
+		return rd, nil
+	}
+	patched := *rd
+	patched.Raw = raw
+	return &patched, nil
+}
+
+// resolveSecretRefs decodes the YAML document, replaces the secret references in it with the
+// referenced values and returns the document encoded again
+func resolveSecretRefs(ctx context.Context, data []byte, baseDir string) ([]byte, error) {
+	if !bytes.Contains(data, []byte("secretRef")) {
+		return data, nil
+	}
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	resolved, err := secret.Resolve(ctx, doc, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(resolved)
+}
+
 func configBaseDir(origin string) string {
 	if origin == "" {
 		return ""
@@ -388,6 +608,10 @@ func initManager(ctx *cli.Context) error {
 	}
 	manager.DryRun = ctx.Bool("dry-run")
 	manager.Writer = ctx.App.Writer
+	if jsonOutput(ctx) {
+		manager.Writer = ctx.App.ErrWriter
+		manager.AddObserver(phase.NewJSONObserver(ctx.App.Writer))
+	}
 
 	ctx.Context = context.WithValue(ctx.Context, ctxManagerKey{}, manager)
 
@@ -535,8 +759,12 @@ func (h *loghook) Fire(entry *log.Entry) error {
 func screenLoggerHook(ctx *cli.Context, lvl log.Level) *loghook {
 	var forceColors bool
 	writer := ctx.App.Writer
+	if jsonOutput(ctx) {
+		// stdout is reserved for the json events
+		writer = ctx.App.ErrWriter
+	}
 	if runtime.GOOS == "windows" {
-		writer = ansicolor.NewAnsiColorWriter((ctx.App.Writer))
+		writer = ansicolor.NewAnsiColorWriter(writer)
 		forceColors = true
 	} else {
 		if outF, ok := writer.(*os.File); ok {
@@ -577,6 +805,9 @@ func fileLoggerHook(logFile io.Writer) *loghook {
 }
 
 func displayLogo(ctx *cli.Context) error {
+	if jsonOutput(ctx) {
+		return nil
+	}
 	fmt.Fprint(ctx.App.Writer, logo)
 	return nil
 }
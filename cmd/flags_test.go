@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/k0sproject/k0sctl/pkg/manifest"
+	"github.com/k0sproject/k0sctl/pkg/secret"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
 )
@@ -77,3 +78,210 @@ spec:
 	require.Len(t, cfg.Spec.Hosts[0].Files[0].Sources, 1)
 	require.Equal(t, "script.sh", cfg.Spec.Hosts[0].Files[0].Sources[0].Path)
 }
+
+func TestReadConfigAppliesOverlays(t *testing.T) {
+	dir := t.TempDir()
+	origin := filepath.Join(dir, "cluster.yaml")
+	clusterYAML := `apiVersion: k0sctl.k0sproject.io/v1beta1
+kind: Cluster
+metadata:
+  name: staging
+spec:
+  hosts:
+    - role: controller
+      ssh:
+        address: 10.0.0.1
+`
+	mergeOverlay := filepath.Join(dir, "production.yaml")
+	require.NoError(t, os.WriteFile(mergeOverlay, []byte(`metadata:
+  name: production
+spec:
+  hosts:
+    - role: controller
+      environment:
+        TZ: UTC
+`), 0o644))
+	patchOverlay := filepath.Join(dir, "patch.yaml")
+	require.NoError(t, os.WriteFile(patchOverlay, []byte(`- op: replace
+  path: /spec/hosts/address=10.0.0.1/ssh/port
+  value: 2222
+`), 0o644))
+
+	mr := &manifest.Reader{}
+	require.NoError(t, mr.ParseBytesWithOrigin([]byte(clusterYAML), origin))
+
+	app := cli.NewApp()
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	overlays := &cli.StringSlice{}
+	require.NoError(t, overlays.Set(mergeOverlay))
+	flagSet.Var(overlays, "overlay", "")
+	ctx := cli.NewContext(app, flagSet, nil)
+	ctx.Context = context.WithValue(context.Background(), ctxConfigsKey{}, mr)
+
+	_, err := readConfig(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, overlays.Set(patchOverlay))
+	_, err = readConfig(ctx)
+	require.ErrorContains(t, err, "/ssh/port not found")
+
+	patch := `- op: add
+  path: /spec/hosts/address=10.0.0.1/ssh/port
+  value: 2222
+`
+	require.NoError(t, os.WriteFile(patchOverlay, []byte(patch), 0o644))
+	cfg, err := readConfig(ctx)
+	require.NoError(t, err)
+	require.Equal(t, origin, cfg.Origin)
+	require.Equal(t, "production", cfg.Metadata.Name)
+	require.Equal(t, 2222, cfg.Spec.Hosts[0].SSH.Port)
+	require.Equal(t, map[string]string{"TZ": "UTC"}, cfg.Spec.Hosts[0].Environment)
+}
+
+func TestReadConfigResolvesSecretRefs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token.txt"), []byte("registry-token\n"), 0o600))
+	origin := filepath.Join(dir, "cluster.yaml")
+	clusterYAML := `apiVersion: k0sctl.k0sproject.io/v1beta1
+kind: Cluster
+metadata:
+  name: test
+spec:
+  hosts:
+    - role: controller
+      ssh:
+        address: 10.0.0.1
+      environment:
+        TOKEN: {secretRef: "file://token.txt"}
+        NOTE: "secretRef: is not a reference"
+---
+apiVersion: k0s.k0sproject.io/v1beta1
+kind: ClusterConfig
+metadata:
+  name: test
+spec:
+  api:
+    extraArgs:
+      token:
+        secretRef: exec://echo api-token
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: app
+          envFrom:
+            - secretRef:
+                name: app
+`
+
+	mr := &manifest.Reader{}
+	require.NoError(t, mr.ParseBytesWithOrigin([]byte(clusterYAML), origin))
+
+	app := cli.NewApp()
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	ctx := cli.NewContext(app, flagSet, nil)
+	ctx.Context = context.WithValue(context.Background(), ctxConfigsKey{}, mr)
+
+	cfg, err := readConfig(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "registry-token", cfg.Spec.Hosts[0].Environment["TOKEN"])
+	require.Equal(t, "secretRef: is not a reference", cfg.Spec.Hosts[0].Environment["NOTE"])
+	require.Equal(t, "api-token", cfg.Spec.K0s.Config.DigString("spec", "api", "extraArgs", "token"))
+
+	// the kubernetes secretRef of the deployment is not a secret reference
+	require.Len(t, cfg.Metadata.Manifests, 1)
+	for _, manifest := range cfg.Metadata.Manifests {
+		require.Contains(t, string(manifest), "secretRef:\n                name: app")
+	}
+
+	// the config is only redacted when it is output
+	require.Contains(t, cfg.String(), "registry-token")
+	require.NotContains(t, secret.Redact(cfg.String()), "registry-token")
+	require.NotContains(t, secret.Redact(cfg.String()), "api-token")
+}
+
+func TestReadConfigSelectsCluster(t *testing.T) {
+	dir := t.TempDir()
+	clusterYAML := func(name, address string) string {
+		return `apiVersion: k0sctl.k0sproject.io/v1beta1
+kind: Cluster
+metadata:
+  name: ` + name + `
+spec:
+  hosts:
+    - role: controller
+      ssh:
+        address: ` + address + `
+`
+	}
+	manifestYAML := func(name string, annotations string) string {
+		return `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ` + name + annotations + `
+`
+	}
+	k0sConfigYAML := `apiVersion: k0s.k0sproject.io/v1beta1
+kind: ClusterConfig
+metadata:
+  name: k0s
+  annotations:
+    k0sctl.k0sproject.io/cluster: production
+spec:
+  telemetry:
+    enabled: false
+`
+
+	mr := &manifest.Reader{}
+	for origin, content := range map[string]string{
+		"staging/k0sctl.yaml":            clusterYAML("staging", "10.0.0.1"),
+		"staging/manifests/staging.yaml": manifestYAML("staging", ""),
+		"production/k0sctl.yaml":         clusterYAML("production", "10.0.1.1"),
+		"production/manifests/prod.yaml": manifestYAML("prod", ""),
+		"k0s.yaml":                       k0sConfigYAML,
+		"shared.yaml":                    manifestYAML("shared", ""),
+		"annotated.yaml":                 manifestYAML("annotated", "\n  annotations:\n    k0sctl.k0sproject.io/cluster: staging"),
+	} {
+		require.NoError(t, mr.ParseBytesWithOrigin([]byte(content), filepath.Join(dir, origin)))
+	}
+
+	app := cli.NewApp()
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.String("cluster", "", "")
+	ctx := cli.NewContext(app, flagSet, nil)
+	ctx.Command = &cli.Command{Name: "apply", Flags: []cli.Flag{clusterFlag}}
+	ctx.Context = context.WithValue(context.Background(), ctxConfigsKey{}, mr)
+
+	_, err := readConfig(ctx)
+	require.ErrorContains(t, err, "use --cluster to select one")
+
+	require.NoError(t, flagSet.Set("cluster", "testing"))
+	_, err = readConfig(ctx)
+	require.ErrorContains(t, err, `cluster "testing" not found`)
+
+	require.NoError(t, flagSet.Set("cluster", "staging"))
+	cfg, err := readConfig(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "staging", cfg.Metadata.Name)
+	require.Equal(t, "10.0.0.1", cfg.Spec.Hosts[0].SSH.Address)
+	require.Nil(t, cfg.Spec.K0s.Config.Dig("spec", "telemetry"))
+	require.Len(t, cfg.Metadata.Manifests, 3)
+	require.Contains(t, cfg.Metadata.Manifests, "staging.yaml")
+	require.Contains(t, cfg.Metadata.Manifests, "shared.yaml")
+	require.Contains(t, cfg.Metadata.Manifests, "annotated.yaml")
+
+	require.NoError(t, flagSet.Set("cluster", "production"))
+	cfg, err = readConfig(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "production", cfg.Metadata.Name)
+	require.Equal(t, false, cfg.Spec.K0s.Config.Dig("spec", "telemetry", "enabled"))
+	require.Len(t, cfg.Metadata.Manifests, 2)
+	require.Contains(t, cfg.Metadata.Manifests, "prod.yaml")
+	require.Contains(t, cfg.Metadata.Manifests, "shared.yaml")
+}
@@ -25,11 +25,12 @@ var kubeconfigCommand = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:        "cluster",
-			Usage:       "Set kubernetes cluster name",
+			Usage:       "Set kubernetes cluster name, also selects the cluster to use when the configuration has multiple clusters",
 			Aliases:     []string{"n"},
 			DefaultText: "k0s-cluster",
 		},
 		configFlag,
+		overlayFlag,
 		dryRunFlag,
 		forceFlag,
 		debugFlag,
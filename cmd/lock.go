@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/action"
+	"github.com/k0sproject/k0sctl/phase"
+
+	"github.com/urfave/cli/v2"
+)
+
+var lockStatusCommand = &cli.Command{
+	Name:  "status",
+	Usage: "Show the k0sctl lock status of the hosts",
+	Flags: []cli.Flag{
+		configFlag,
+		overlayFlag,
+		clusterFlag,
+		concurrencyFlag,
+		debugFlag,
+		traceFlag,
+		redactFlag,
+		timeoutFlag,
+	},
+	Before: actions(initSilentLogging, initConfig, initManager),
+	After:  actions(cancelTimeout),
+	Action: func(ctx *cli.Context) error {
+		lockStatusAction := action.LockStatus{
+			Manager: ctx.Context.Value(ctxManagerKey{}).(*phase.Manager),
+			Writer:  ctx.App.Writer,
+		}
+
+		if err := lockStatusAction.Run(ctx.Context); err != nil {
+			return fmt.Errorf("lock status failed - log file saved to %s: %w", ctx.Context.Value(ctxLogFileKey{}).(string), err)
+		}
+
+		return nil
+	},
+}
+
+var lockBreakCommand = &cli.Command{
+	Name:  "break",
+	Usage: "Forcibly remove the k0sctl locks from the hosts",
+	Flags: []cli.Flag{
+		configFlag,
+		overlayFlag,
+		clusterFlag,
+		concurrencyFlag,
+		dryRunFlag,
+		forceFlag,
+		debugFlag,
+		traceFlag,
+		redactFlag,
+		timeoutFlag,
+	},
+	Before: actions(initLogging, initConfig, initManager, displayCopyright),
+	After:  actions(cancelTimeout),
+	Action: func(ctx *cli.Context) error {
+		lockBreakAction := action.LockBreak{
+			Manager: ctx.Context.Value(ctxManagerKey{}).(*phase.Manager),
+		}
+
+		if err := lockBreakAction.Run(ctx.Context); err != nil {
+			return fmt.Errorf("lock break failed - log file saved to %s: %w", ctx.Context.Value(ctxLogFileKey{}).(string), err)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/k0sproject/k0sctl/action"
+	"github.com/k0sproject/k0sctl/phase"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+var planCommand = &cli.Command{
+	Name:  "plan",
+	Usage: "Output a machine-readable plan of the actions \"apply\" would perform",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:      "output",
+			Aliases:   []string{"o"},
+			Usage:     "Write the plan to the given path instead of stdout",
+			Value:     "-",
+			TakesFile: true,
+		},
+		configFlag,
+		overlayFlag,
+		clusterFlag,
+		concurrencyFlag,
+		&cli.BoolFlag{
+			Name:  "no-drain",
+			Usage: "Do not drain worker nodes when upgrading",
+		},
+		&cli.BoolFlag{
+			Name:   "disable-downgrade-check",
+			Usage:  "Skip downgrade check",
+			Hidden: true,
+		},
+		upgradePathFlag,
+		forceFlag,
+		debugFlag,
+		traceFlag,
+		redactFlag,
+		retryIntervalFlag,
+		retryTimeoutFlag,
+		timeoutFlag,
+	},
+	Before: actions(initPlanLogging, initConfig, initManager, warnOldCache, warnRigMigration),
+	After:  actions(cancelTimeout),
+	Action: func(ctx *cli.Context) error {
+		manager, ok := ctx.Context.Value(ctxManagerKey{}).(*phase.Manager)
+		if !ok {
+			return fmt.Errorf("failed to retrieve manager from context")
+		}
+
+		var out io.Writer = ctx.App.Writer
+		if output := ctx.String("output"); output != "-" {
+			f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				return fmt.Errorf("failed to open plan output file: %w", err)
+			}
+			defer func() {
+				if err := f.Close(); err != nil {
+					log.Warnf("failed to close plan output file %s: %v", output, err)
+				}
+			}()
+			out = f
+		} else {
+			// keep stdout clean for the plan
+			manager.Writer = ctx.App.ErrWriter
+		}
+
+		planAction := action.Plan{
+			PlanOptions: action.PlanOptions{
+				Manager:               manager,
+				DisableDowngradeCheck: ctx.Bool("disable-downgrade-check"),
+				UpgradePath:           ctx.String("upgrade-path"),
+				NoDrain:               getNoDrainFlagOrConfig(ctx, manager.Config.Spec.Options.Drain),
+				Out:                   out,
+			},
+		}
+
+		if err := planAction.Run(ctx.Context); err != nil {
+			return fmt.Errorf("plan failed - log file saved to %s: %w", ctx.Context.Value(ctxLogFileKey{}).(string), err)
+		}
+
+		return nil
+	},
+}
+
+// initPlanLogging initializes silent logging when the plan is written to stdout
+func initPlanLogging(ctx *cli.Context) error {
+	if ctx.String("output") == "-" {
+		return initSilentLogging(ctx)
+	}
+	if err := initLogging(ctx); err != nil {
+		return err
+	}
+	return displayCopyright(ctx)
+}
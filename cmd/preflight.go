@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/action"
+	"github.com/k0sproject/k0sctl/phase"
+
+	"github.com/urfave/cli/v2"
+)
+
+var preflightCommand = &cli.Command{
+	Name:  "preflight",
+	Usage: "Check that the hosts meet the requirements of k0s",
+	Description: "Connects to the hosts and checks the free disk space in the k0s data directory, memory and CPUs, " +
+		"that the k0s ports are free, cgroup v2, kernel modules, swap and the SELinux mode without changing anything. " +
+		"Exits with a non-zero exit code when a check failed.",
+	Flags: []cli.Flag{
+		configFlag,
+		overlayFlag,
+		clusterFlag,
+		concurrencyFlag,
+		debugFlag,
+		traceFlag,
+		redactFlag,
+		timeoutFlag,
+		&cli.StringFlag{
+			Name:    "output",
+			Usage:   "Output format, one of: table, json",
+			Aliases: []string{"o"},
+			Value:   "table",
+			Action: func(_ *cli.Context, format string) error {
+				if format != "table" && format != "json" {
+					return fmt.Errorf("invalid output format %q, expected table or json", format)
+				}
+				return nil
+			},
+		},
+		&cli.BoolFlag{
+			Name:  "strict",
+			Usage: "Exit with a non-zero exit code also when there are warnings",
+		},
+	},
+	Before: actions(initSilentLogging, initConfig, initManager),
+	After:  actions(cancelTimeout),
+	Action: func(ctx *cli.Context) error {
+		preflightAction := action.Preflight{
+			Manager: ctx.Context.Value(ctxManagerKey{}).(*phase.Manager),
+			Format:  ctx.String("output"),
+			Strict:  ctx.Bool("strict"),
+			Writer:  ctx.App.Writer,
+		}
+
+		if err := preflightAction.Run(ctx.Context); err != nil {
+			if errors.Is(err, action.ErrPreflightFailed) {
+				return err
+			}
+			return fmt.Errorf("preflight failed - log file saved to %s: %w", ctx.Context.Value(ctxLogFileKey{}).(string), err)
+		}
+
+		return nil
+	},
+}
@@ -14,12 +14,15 @@ var resetCommand = &cli.Command{
 	Usage: "Remove traces of k0s from all of the hosts",
 	Flags: []cli.Flag{
 		configFlag,
+		overlayFlag,
+		clusterFlag,
 		concurrencyFlag,
 		dryRunFlag,
 		forceFlag,
 		debugFlag,
 		traceFlag,
 		redactFlag,
+		outputFormatFlag,
 		timeoutFlag,
 		retryIntervalFlag,
 		retryTimeoutFlag,
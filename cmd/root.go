@@ -5,12 +5,33 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 )
 
+var (
+	forcedExitMu    sync.Mutex
+	forcedExitFuncs []func()
+)
+
+// onForcedExit registers a function to be called before the process exits on a repeated interrupt signal
+func onForcedExit(fn func()) {
+	forcedExitMu.Lock()
+	defer forcedExitMu.Unlock()
+	forcedExitFuncs = append(forcedExitFuncs, fn)
+}
+
+func runForcedExitFuncs() {
+	forcedExitMu.Lock()
+	defer forcedExitMu.Unlock()
+	for _, fn := range forcedExitFuncs {
+		fn()
+	}
+}
+
 func trapSignals(ctx context.Context, cancel context.CancelFunc) {
 	ch := make(chan os.Signal, 2) // Buffer size 2 to catch double signals
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
@@ -28,6 +49,7 @@ func trapSignals(ctx context.Context, cancel context.CancelFunc) {
 					cancel()
 				} else {
 					log.Error("Forced exit")
+					runForcedExitFuncs()
 					os.Exit(130)
 				}
 			}
@@ -48,16 +70,45 @@ func NewK0sctl(in io.Reader, out, errOut io.Writer) *cli.App {
 		Commands: []*cli.Command{
 			versionCommand,
 			applyCommand,
+			planCommand,
 			kubeconfigCommand,
 			initCommand,
 			resetCommand,
 			backupCommand,
+			statusCommand,
+			diffCommand,
+			preflightCommand,
 			{
 				Name:  "config",
 				Usage: "Configuration related sub-commands",
 				Subcommands: []*cli.Command{
 					configEditCommand,
 					configStatusCommand,
+					configRenderCommand,
+				},
+			},
+			{
+				Name:  "airgap",
+				Usage: "Airgap installation related sub-commands",
+				Subcommands: []*cli.Command{
+					airgapBundleCommand,
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "Local k0s binary cache related sub-commands",
+				Subcommands: []*cli.Command{
+					cacheListCommand,
+					cachePrefetchCommand,
+					cachePruneCommand,
+				},
+			},
+			{
+				Name:  "lock",
+				Usage: "Host lock related sub-commands",
+				Subcommands: []*cli.Command{
+					lockStatusCommand,
+					lockBreakCommand,
 				},
 			},
 			completionCommand,
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/action"
+	"github.com/k0sproject/k0sctl/phase"
+
+	"github.com/urfave/cli/v2"
+)
+
+var statusCommand = &cli.Command{
+	Name:  "status",
+	Usage: "Show the health status of the cluster hosts",
+	Description: "Displays the k0s version, service state, node readiness, etcd membership and configuration state of each host. " +
+		"Exits with a non-zero exit code when problems were found on any of the hosts.",
+	Flags: []cli.Flag{
+		configFlag,
+		overlayFlag,
+		clusterFlag,
+		concurrencyFlag,
+		forceFlag,
+		debugFlag,
+		traceFlag,
+		redactFlag,
+		timeoutFlag,
+		&cli.StringFlag{
+			Name:    "output",
+			Usage:   "Output format, one of: table, json, yaml",
+			Aliases: []string{"o"},
+			Value:   "table",
+			Action: func(_ *cli.Context, format string) error {
+				switch format {
+				case "table", "json", "yaml":
+					return nil
+				default:
+					return fmt.Errorf("invalid output format %q, expected table, json or yaml", format)
+				}
+			},
+		},
+	},
+	Before: actions(initSilentLogging, initConfig, initManager),
+	After:  actions(cancelTimeout),
+	Action: func(ctx *cli.Context) error {
+		statusAction := action.Status{
+			Manager: ctx.Context.Value(ctxManagerKey{}).(*phase.Manager),
+			Format:  ctx.String("output"),
+			Writer:  ctx.App.Writer,
+		}
+
+		if err := statusAction.Run(ctx.Context); err != nil {
+			if errors.Is(err, action.ErrDegraded) {
+				return err
+			}
+			return fmt.Errorf("status failed - log file saved to %s: %w", ctx.Context.Value(ctxLogFileKey{}).(string), err)
+		}
+
+		return nil
+	},
+}
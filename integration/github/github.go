@@ -142,3 +142,43 @@ func fetchLatestNonPrereleaseRelease() (Release, error) {
 
 	return Release{}, fmt.Errorf("no release found")
 }
+
+// K0sReleases returns the k0s release versions from github in ascending order. The releases are
+// listed newest first, so when since is given, the listing stops after the page that reaches the
+// kubernetes minor version of since.
+func K0sReleases(preok bool, since *k0sversion.Version) (k0sversion.Collection, error) {
+	var versions k0sversion.Collection
+	for page := 1; ; page++ {
+		var releases []Release
+		if err := unmarshalURLBody(fmt.Sprintf("https://api.github.com/repos/k0sproject/k0s/releases?per_page=100&page=%d", page), &releases); err != nil {
+			return nil, fmt.Errorf("failed to fetch the k0s releases: %w", err)
+		}
+		if len(releases) == 0 {
+			break
+		}
+		var reached bool
+		for _, r := range releases {
+			if r.PreRelease && !preok {
+				continue
+			}
+			if v, err := k0sversion.NewVersion(r.TagName); err == nil {
+				versions = append(versions, v)
+				reached = reached || (since != nil && !minorGreaterThan(v, since))
+			}
+		}
+		if reached {
+			break
+		}
+	}
+	sort.Sort(versions)
+	return versions, nil
+}
+
+// minorGreaterThan returns true when the kubernetes minor version of a is greater than that of b
+func minorGreaterThan(a, b *k0sversion.Version) bool {
+	as, bs := a.Segments(), b.Segments()
+	if as[0] != bs[0] {
+		return as[0] > bs[0]
+	}
+	return as[1] > bs[1]
+}
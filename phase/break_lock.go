@@ -0,0 +1,55 @@
+package phase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	log "github.com/sirupsen/logrus"
+)
+
+// BreakLock forcibly removes the k0sctl lock files from the hosts
+type BreakLock struct {
+	GenericPhase
+}
+
+// Title for the phase
+func (p *BreakLock) Title() string {
+	return "Break host locks"
+}
+
+// Run the phase
+func (p *BreakLock) Run(ctx context.Context) error {
+	err := p.parallelDo(ctx, p.Config.Spec.Hosts, func(_ context.Context, h *cluster.Host) error {
+		info, err := ReadLockInfo(h)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			log.Infof("%s: host is not locked", h)
+			return nil
+		}
+		lfp := h.Configurer.K0sctlLockFilePath(h)
+		return p.Wet(h, fmt.Sprintf("remove lock file %s held by %s", lfp, info), func() error {
+			log.Warnf("%s: breaking lock held by %s", h, info)
+			if err := h.Sudo().FS().Remove(lfp); err != nil {
+				return fmt.Errorf("failed to remove lock file %s: %w", lfp, err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	leader := p.Config.Spec.K0sLeader()
+	if leader == nil || !leader.Sudo().FS().FileExist(leader.Configurer.K0sBinaryPath()) {
+		return nil
+	}
+	return p.Wet(leader, fmt.Sprintf("delete lease %s/%s", ClusterLockNamespace, ClusterLockName), func() error {
+		if _, err := clusterLockKubectl(leader, "delete lease "+ClusterLockName+" --ignore-not-found"); err != nil {
+			log.Warnf("%s: failed to delete the cluster-wide lock lease, the control plane may not be running: %s", leader, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,488 @@
+package phase
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	k0s "github.com/k0sproject/k0sctl/pkg/k0s"
+	"github.com/k0sproject/k0sctl/pkg/k0s/binprovider"
+	"github.com/k0sproject/k0sctl/pkg/secret"
+	"github.com/k0sproject/version"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// AirgapKitConfigFileName is the name of the rewritten configuration in the airgap installation kit
+const AirgapKitConfigFileName = "k0sctl.yaml"
+
+// kitEntry is a file in the airgap installation kit. The content comes either from a local file or from data.
+type kitEntry struct {
+	name   string
+	source string
+	data   []byte
+	mode   int64
+	dir    bool
+}
+
+// BuildAirgapKit writes a tarball with the k0s binaries, the airgap image bundles and the files of
+// every host together with a configuration that refers to the bundled copies
+type BuildAirgapKit struct {
+	GenericPhase
+	// Output is the path of the tarball
+	Output string
+	// SkipImages leaves out the airgap image bundles
+	SkipImages bool
+
+	entries []*kitEntry
+	names   map[string]bool
+	tmpDir  string
+}
+
+// Title for the phase
+func (p *BuildAirgapKit) Title() string {
+	return "Build airgap installation kit"
+}
+
+// Prepare the phase
+func (p *BuildAirgapKit) Prepare(config *v1beta1.Cluster) error {
+	p.Config = config
+	p.names = make(map[string]bool)
+	return nil
+}
+
+// Run the phase
+func (p *BuildAirgapKit) Run(ctx context.Context) error {
+	target := p.Config.Spec.K0s.Version
+	if target == nil {
+		return fmt.Errorf("no k0s version set")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "k0sctl-airgap-kit")
+	if err != nil {
+		return fmt.Errorf("create temporary directory: %w", err)
+	}
+	p.tmpDir = tmpDir
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warnf("failed to remove temporary directory %s: %v", tmpDir, err)
+		}
+	}()
+
+	kit, err := p.kitConfig()
+	if err != nil {
+		return err
+	}
+
+	for i, h := range p.Config.Spec.Hosts {
+		kh := kit.Spec.Hosts[i]
+		if !h.Reset && !h.UseExistingK0s {
+			name, err := p.addBinary(ctx, h, target)
+			if err != nil {
+				return fmt.Errorf("%s: %w", h, err)
+			}
+			kh.K0sBinaryPath = name
+			kh.K0sBinarySource = ""
+			kh.K0sDownloadURLOverride = ""
+			kh.UploadBinary = false
+		}
+		for j, f := range h.Files {
+			if err := p.addFile(ctx, f, kh.Files[j]); err != nil {
+				return fmt.Errorf("%s: file %s: %w", h, f, err)
+			}
+		}
+	}
+
+	kit.Spec.K0s.Airgap = nil
+	if !p.SkipImages {
+		airgap, err := p.addAirgapBundles(ctx, target)
+		if err != nil {
+			return err
+		}
+		kit.Spec.K0s.Airgap = airgap
+	}
+
+	if v := kit.Spec.K0s.Verify; v != nil && v.Signature != nil && v.Signature.PublicKey != "" {
+		name := path.Join("keys", filepath.Base(v.Signature.PublicKey))
+		if err := p.add(&kitEntry{name: name, data: p.Config.Spec.K0s.Verify.Signature.PublicKeyData(), mode: 0o644}); err != nil {
+			return err
+		}
+		v.Signature.PublicKey = name
+	}
+
+	config, err := p.renderConfig(kit)
+	if err != nil {
+		return err
+	}
+	if err := p.add(&kitEntry{name: AirgapKitConfigFileName, data: config, mode: 0o600}); err != nil {
+		return err
+	}
+
+	if err := p.write(); err != nil {
+		return err
+	}
+	log.Infof("wrote airgap installation kit with %d files to %s", len(p.entries), p.Output)
+	return nil
+}
+
+// kitConfig returns a copy of the configuration for the kit
+func (p *BuildAirgapKit) kitConfig() (*v1beta1.Cluster, error) {
+	out, err := yaml.Marshal(p.Config)
+	if err != nil {
+		return nil, fmt.Errorf("marshal configuration: %w", err)
+	}
+	kit := &v1beta1.Cluster{}
+	if err := yaml.Unmarshal(out, kit); err != nil {
+		return nil, fmt.Errorf("unmarshal configuration: %w", err)
+	}
+	if len(kit.Spec.Hosts) != len(p.Config.Spec.Hosts) {
+		return nil, fmt.Errorf("configuration copy has %d hosts, expected %d", len(kit.Spec.Hosts), len(p.Config.Spec.Hosts))
+	}
+	if kit.Spec.K0s == nil {
+		kit.Spec.K0s = &cluster.K0s{}
+	}
+	kit.Spec.K0s.Version = p.Config.Spec.K0s.Version
+	kit.Spec.K0s.VersionChannel = ""
+	return kit, nil
+}
+
+// renderConfig renders the kit configuration followed by the additional manifests of the original configuration.
+// The values resolved from secret references are replaced with the references and the other passwords are
+// left out, so that the kit does not carry credentials.
+func (p *BuildAirgapKit) renderConfig(kit *v1beta1.Cluster) ([]byte, error) {
+	out, err := yaml.Marshal(kit)
+	if err != nil {
+		return nil, fmt.Errorf("marshal kit configuration: %w", err)
+	}
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal kit configuration: %w", err)
+	}
+	out, err = yaml.Marshal(stripPasswords(secret.Unresolve(doc), ""))
+	if err != nil {
+		return nil, fmt.Errorf("marshal kit configuration: %w", err)
+	}
+	names := make([]string, 0, len(p.Config.Metadata.Manifests))
+	for name := range p.Config.Metadata.Manifests {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		out = append(out, []byte("---\n")...)
+		out = append(out, p.Config.Metadata.Manifests[name]...)
+		if !strings.HasSuffix(string(out), "\n") {
+			out = append(out, '\n')
+		}
+	}
+	return out, nil
+}
+
+// stripPasswords removes the password values that are not secret references from the decoded configuration
+func stripPasswords(node any, path string) any {
+	switch node := node.(type) {
+	case yaml.MapSlice:
+		kept := node[:0]
+		for _, item := range node {
+			key := fmt.Sprint(item.Key)
+			itemPath := key
+			if path != "" {
+				itemPath = path + "." + key
+			}
+			if value, ok := item.Value.(string); ok && value != "" && strings.EqualFold(key, "password") {
+				log.Warnf("leaving the password %s out of the kit configuration, use a secret reference to include it", itemPath)
+				continue
+			}
+			item.Value = stripPasswords(item.Value, itemPath)
+			kept = append(kept, item)
+		}
+		return kept
+	case []any:
+		for i, item := range node {
+			node[i] = stripPasswords(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	return node
+}
+
+// addBinary adds the k0s binary of the host to the kit and returns its path in the kit
+func (p *BuildAirgapKit) addBinary(ctx context.Context, h *cluster.Host, target *version.Version) (string, error) {
+	exe := "k0s"
+	if h.IsWindows() {
+		exe = "k0s.exe"
+	}
+
+	if local := h.K0sBinaryPath; local != "" {
+		name := path.Join("bin", "local-"+shortHash(local), filepath.Base(local))
+		return name, p.add(&kitEntry{name: name, source: local})
+	}
+
+	platform, err := hostPlatform(h)
+	if err != nil {
+		return "", err
+	}
+
+	if h.K0sDownloadURLOverride != "" && h.K0sBinarySource == "" {
+		u := h.ExpandTokens(h.K0sDownloadURLOverride, target)
+		name := path.Join("bin", platform+"-"+shortHash(u), exe)
+		if p.names[name] {
+			return name, nil
+		}
+		local, err := p.download(ctx, u)
+		if err != nil {
+			return "", fmt.Errorf("download k0s binary: %w", err)
+		}
+		return name, p.add(&kitEntry{name: name, source: local, mode: 0o755})
+	}
+
+	var bc k0s.BinaryCacher
+	if h.K0sBinarySource != "" {
+		provider, err := h.K0sBinaryProvider(target)
+		if err != nil {
+			return "", err
+		}
+		c, ok := provider.(k0s.BinaryCacher)
+		if !ok {
+			return "", fmt.Errorf("%T can not be cached locally", provider)
+		}
+		bc = c
+	} else {
+		bc, _ = binprovider.NewLocalUpload(h, h.K0sInstallLocation(), target).(k0s.BinaryCacher)
+	}
+
+	local, err := bc.BinaryCacheKey()
+	if err != nil {
+		return "", fmt.Errorf("get k0s binary cache key: %w", err)
+	}
+	name := path.Join("bin", platform, exe)
+	if h.K0sBinarySource != "" {
+		name = path.Join("bin", platform+"-"+shortHash(local), exe)
+	}
+	if p.names[name] {
+		return name, nil
+	}
+	if err := bc.EnsureCached(ctx); err != nil {
+		return "", fmt.Errorf("cache k0s binary: %w", err)
+	}
+	log.Infof("%s: bundling k0s %s binary for %s", h, target, platform)
+	return name, p.add(&kitEntry{name: name, source: local, mode: 0o755})
+}
+
+// addFile adds the local or downloaded sources of the file to the kit and points the kit file at them
+func (p *BuildAirgapKit) addFile(ctx context.Context, f, kf *cluster.UploadFile) error {
+	if f.HasData() {
+		return nil
+	}
+	dir := path.Join("files", shortHash(f.Base+"\x00"+f.Source))
+
+	if f.IsURL() {
+		u, err := url.Parse(f.Source)
+		if err != nil {
+			return fmt.Errorf("parse url: %w", err)
+		}
+		name := path.Join(dir, path.Base(u.Path))
+		kf.Source = name
+		if p.names[name] {
+			return nil
+		}
+		local, err := p.download(ctx, f.Source)
+		if err != nil {
+			return err
+		}
+		return p.add(&kitEntry{name: name, source: local})
+	}
+
+	// a single file source is referred to directly, globs and directories become a directory
+	if len(f.Sources) == 1 && path.Base(filepath.ToSlash(f.Source)) == f.Sources[0].Path && !strings.ContainsAny(f.Source, "*%?[]{}") {
+		kf.Source = path.Join(dir, f.Sources[0].Path)
+	} else {
+		kf.Source = dir
+		if err := p.add(&kitEntry{name: dir, dir: true, mode: 0o755}); err != nil {
+			return err
+		}
+	}
+	for _, s := range f.Sources {
+		entry := &kitEntry{name: path.Join(dir, s.Path), source: filepath.Join(filepath.FromSlash(f.Base), filepath.FromSlash(s.Path))}
+		if mode, err := strconv.ParseInt(s.PermMode, 8, 64); err == nil {
+			entry.mode = mode & 0o7777
+		}
+		if err := p.add(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addAirgapBundles adds the airgap image bundles of the hosts that run workloads and returns
+// the airgap configuration that refers to them
+func (p *BuildAirgapKit) addAirgapBundles(ctx context.Context, target *version.Version) (*cluster.K0sAirgap, error) {
+	airgap := p.Config.Spec.K0s.Airgap
+	if airgap == nil {
+		airgap = &cluster.K0sAirgap{}
+	}
+	checksums := make(map[string]string)
+	for _, h := range p.Config.Spec.Hosts {
+		if h.Reset || h.Role == "controller" || h.IsWindows() {
+			continue
+		}
+		arch, err := h.Arch()
+		if err != nil {
+			return nil, fmt.Errorf("%s: get host arch: %w", h, err)
+		}
+		if _, ok := checksums[arch]; ok {
+			continue
+		}
+		b, err := airgap.Bundle(h, target)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", h, err)
+		}
+		if err := b.EnsureCached(ctx); err != nil {
+			return nil, fmt.Errorf("%s: cache k0s airgap bundle: %w", h, err)
+		}
+		local, err := b.BinaryCacheKey()
+		if err != nil {
+			return nil, fmt.Errorf("%s: get k0s airgap bundle cache key: %w", h, err)
+		}
+		sum, err := binprovider.FileSha256(local)
+		if err != nil {
+			return nil, fmt.Errorf("calculate checksum of %s: %w", local, err)
+		}
+		log.Infof("%s: bundling k0s %s airgap image bundle for %s", h, target, arch)
+		if err := p.add(&kitEntry{name: path.Join("images", arch, binprovider.AirgapBundleFileName), source: local, mode: 0o644}); err != nil {
+			return nil, err
+		}
+		checksums[arch] = sum
+	}
+	if len(checksums) == 0 {
+		return nil, nil
+	}
+	return &cluster.K0sAirgap{Path: path.Join("images", "%p", binprovider.AirgapBundleFileName), Checksums: checksums}, nil
+}
+
+// download downloads the url into the temporary directory
+func (p *BuildAirgapKit) download(ctx context.Context, u string) (string, error) {
+	dest := filepath.Join(p.tmpDir, shortHash(u))
+	log.Infof("downloading %s", u)
+	if err := binprovider.DownloadFile(ctx, u, dest); err != nil {
+		return "", fmt.Errorf("download %s: %w", u, err)
+	}
+	return dest, nil
+}
+
+// add adds an entry to the kit, entries with a name that is already in the kit are ignored
+func (p *BuildAirgapKit) add(e *kitEntry) error {
+	if p.names[e.name] {
+		return nil
+	}
+	if e.source != "" {
+		stat, err := os.Stat(e.source)
+		if err != nil {
+			return fmt.Errorf("kit file %s: %w", e.name, err)
+		}
+		if e.mode == 0 {
+			e.mode = int64(stat.Mode().Perm())
+		}
+	}
+	p.names[e.name] = true
+	p.entries = append(p.entries, e)
+	return nil
+}
+
+// write writes the entries into a gzipped tarball. The tarball is written to a temporary
+// file first so that an interrupted run does not leave a partial kit behind.
+func (p *BuildAirgapKit) write() (retErr error) {
+	tmp, err := os.CreateTemp(filepath.Dir(p.Output), filepath.Base(p.Output)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("create kit file: %w", err)
+	}
+	defer func() {
+		if retErr != nil {
+			_ = tmp.Close()
+			if err := os.Remove(tmp.Name()); err != nil && !os.IsNotExist(err) {
+				log.Warnf("failed to remove partial kit file %s: %v", tmp.Name(), err)
+			}
+		}
+	}()
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	for _, e := range p.entries {
+		if err := writeKitEntry(tw, e); err != nil {
+			return fmt.Errorf("write %s to kit: %w", e.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("write kit file: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("write kit file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write kit file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.Output); err != nil {
+		return fmt.Errorf("move kit file to %s: %w", p.Output, err)
+	}
+	return nil
+}
+
+func writeKitEntry(tw *tar.Writer, e *kitEntry) (retErr error) {
+	if e.dir {
+		return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: e.name + "/", Mode: e.mode})
+	}
+	if e.source == "" {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: e.name, Mode: e.mode, Size: int64(len(e.data))}); err != nil {
+			return err
+		}
+		_, err := tw.Write(e.data)
+		return err
+	}
+	f, err := os.Open(e.source)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: e.name, Mode: e.mode, Size: stat.Size(), ModTime: stat.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// hostPlatform returns the os-arch of the host
+func hostPlatform(h *cluster.Host) (string, error) {
+	osKind, err := h.OSKind()
+	if err != nil {
+		return "", fmt.Errorf("get host os kind: %w", err)
+	}
+	arch, err := h.Arch()
+	if err != nil {
+		return "", fmt.Errorf("get host arch: %w", err)
+	}
+	return osKind + "-" + arch, nil
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
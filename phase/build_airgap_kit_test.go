@@ -0,0 +1,150 @@
+package phase
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	linuxcfg "github.com/k0sproject/k0sctl/configurer/linux"
+	v1beta1 "github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/k0sctl/pkg/secret"
+	"github.com/k0sproject/version"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func readKit(t *testing.T, file string) map[string]string {
+	t.Helper()
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	entries := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries[hdr.Name] = string(data)
+	}
+}
+
+func TestBuildAirgapKit(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "k0s-custom")
+	require.NoError(t, os.WriteFile(binary, []byte("k0s binary"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "manifests"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifests", "a.yaml"), []byte("a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifests", "b.yaml"), []byte("b"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "motd"), []byte("hello"), 0o644))
+
+	files := []*cluster.UploadFile{
+		{Name: "manifests", Source: "manifests/*.yaml", DestinationDir: "/var/lib/k0s/manifests/test"},
+		{Name: "motd", Source: "motd", DestinationDir: "/etc"},
+		{Name: "data", Data: "inline", DestinationFile: "/etc/data"},
+	}
+	for _, f := range files {
+		require.NoError(t, f.ResolveRelativeTo(dir))
+	}
+
+	h := &cluster.Host{
+		Role:          "worker",
+		K0sBinaryPath: binary,
+		UploadBinary:  true,
+		Files:         files,
+		Configurer:    &linuxcfg.Ubuntu{},
+		Metadata:      cluster.HostMetadata{Arch: "amd64"},
+		Environment:   map[string]string{"TOKEN": resolveKitSecret(t, "exec://echo kit-token")},
+	}
+	cfg := &v1beta1.Cluster{
+		APIVersion: v1beta1.APIVersion,
+		Kind:       "Cluster",
+		Metadata:   &v1beta1.ClusterMetadata{Name: "test", Manifests: map[string][]byte{"extra.yaml": []byte("kind: ConfigMap")}},
+		Spec: &cluster.Spec{
+			Hosts: cluster.Hosts{h},
+			K0s:   &cluster.K0s{Version: version.MustParse("v1.30.0+k0s.0")},
+		},
+	}
+
+	output := filepath.Join(t.TempDir(), "kit.tar.gz")
+	p := &BuildAirgapKit{Output: output, SkipImages: true}
+	require.NoError(t, p.Prepare(cfg))
+	require.NoError(t, p.Run(context.Background()))
+
+	entries := readKit(t, output)
+	require.Equal(t, "k0s binary", entries["bin/local-"+shortHash(binary)+"/k0s-custom"])
+
+	// the kit configuration is read the same way as k0sctl reads it, with the secret references resolved
+	var doc any
+	require.NoError(t, yaml.Unmarshal([]byte(entries[AirgapKitConfigFileName]), &doc))
+	doc, err := secret.Resolve(context.Background(), doc, dir)
+	require.NoError(t, err)
+	resolved, err := yaml.Marshal(doc)
+	require.NoError(t, err)
+	kit := &v1beta1.Cluster{}
+	require.NoError(t, yaml.Unmarshal(resolved, kit))
+	require.Contains(t, entries[AirgapKitConfigFileName], "---\nkind: ConfigMap\n")
+	require.Equal(t, "v1.30.0+k0s.0", kit.Spec.K0s.Version.String())
+
+	kh := kit.Spec.Hosts[0]
+	require.Equal(t, "bin/local-"+shortHash(binary)+"/k0s-custom", kh.K0sBinaryPath)
+	require.False(t, kh.UploadBinary)
+
+	globDir := "files/" + shortHash(files[0].Base+"\x00"+files[0].Source)
+	require.Equal(t, globDir, kh.Files[0].Source)
+	require.Equal(t, "a", entries[globDir+"/a.yaml"])
+	require.Equal(t, "b", entries[globDir+"/b.yaml"])
+
+	motd := "files/" + shortHash(files[1].Base+"\x00"+files[1].Source) + "/motd"
+	require.Equal(t, motd, kh.Files[1].Source)
+	require.Equal(t, "hello", entries[motd])
+
+	require.Equal(t, "inline", kh.Files[2].Data)
+	require.Equal(t, "kit-token", kh.Environment["TOKEN"])
+
+	// the secret value is not written into the kit, the reference is
+	require.NotContains(t, entries[AirgapKitConfigFileName], "TOKEN: kit-token")
+	require.Contains(t, entries[AirgapKitConfigFileName], "secretRef: exec://echo kit-token")
+}
+
+func resolveKitSecret(t *testing.T, ref string) string {
+	t.Helper()
+	resolved, err := secret.Resolve(context.Background(), yaml.MapSlice{{Key: "secretRef", Value: ref}}, t.TempDir())
+	require.NoError(t, err)
+	return resolved.(string)
+}
+
+func TestStripPasswords(t *testing.T) {
+	var doc yaml.MapSlice
+	require.NoError(t, yaml.Unmarshal([]byte(`spec:
+  hosts:
+    - winRM:
+        address: 10.0.0.1
+        password: plain-password
+    - ssh:
+        address: 10.0.0.2
+        password:
+          secretRef: file://password.txt
+`), &doc))
+	out, err := yaml.Marshal(stripPasswords(doc, ""))
+	require.NoError(t, err)
+	require.YAMLEq(t, `spec:
+  hosts:
+    - winRM:
+        address: 10.0.0.1
+    - ssh:
+        address: 10.0.0.2
+        password:
+          secretRef: file://password.txt
+`, string(out))
+}
@@ -0,0 +1,161 @@
+package phase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrCheckpointMismatch is returned when resuming from a checkpoint that was written for a different configuration
+var ErrCheckpointMismatch = errors.New("configuration has changed since the checkpoint was saved")
+
+// Checkpoint records the progress of a Manager run to a local state file so that an
+// interrupted run can be resumed without redoing the phases and hosts that already finished.
+type Checkpoint struct {
+	// ConfigHash is a hash of the cluster configuration the checkpoint was written for
+	ConfigHash string `json:"configHash"`
+	// Phases lists the titles of the phases that have completed
+	Phases []string `json:"phases,omitempty"`
+	// Hosts maps phase titles to the hosts that have completed their part of the phase
+	Hosts map[string][]string `json:"hosts,omitempty"`
+	// Updated is the time of the last update
+	Updated time.Time `json:"updated"`
+
+	path string
+	mu   sync.Mutex
+	// persist is true when every change is written to disk right away, otherwise the checkpoint
+	// is only written by Save
+	persist bool
+}
+
+// resumable is implemented by phases that can be skipped when a checkpoint says they have already completed.
+type resumable interface {
+	Resumable() bool
+}
+
+// NewCheckpoint returns an empty checkpoint for the given path. The progress is kept in memory and
+// only written to disk by Save, which the Manager calls when a run fails.
+func NewCheckpoint(path string) *Checkpoint {
+	return &Checkpoint{path: path}
+}
+
+// LoadCheckpoint reads a checkpoint from the given path. The progress of a resumed run is written to
+// disk as it is made.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	c := &Checkpoint{path: path, persist: true}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("decode checkpoint %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Path returns the path of the checkpoint state file
+func (c *Checkpoint) Path() string {
+	return c.path
+}
+
+// Bind associates the checkpoint with a configuration hash. A checkpoint that was already bound to
+// a different configuration can not be used and ErrCheckpointMismatch is returned.
+func (c *Checkpoint) Bind(hash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ConfigHash != "" && c.ConfigHash != hash {
+		return ErrCheckpointMismatch
+	}
+	c.ConfigHash = hash
+	return c.update()
+}
+
+// PhaseDone returns true if the phase with the given title has been recorded as completed
+func (c *Checkpoint) PhaseDone(title string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Contains(c.Phases, title)
+}
+
+// MarkPhase records the phase with the given title as completed
+func (c *Checkpoint) MarkPhase(title string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if slices.Contains(c.Phases, title) {
+		return nil
+	}
+	c.Phases = append(c.Phases, title)
+	return c.update()
+}
+
+// HostDone returns true if the host has been recorded as completed for the phase with the given title
+func (c *Checkpoint) HostDone(title string, host fmt.Stringer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Contains(c.Hosts[title], host.String())
+}
+
+// MarkHost records the host as completed for the phase with the given title
+func (c *Checkpoint) MarkHost(title string, host fmt.Stringer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Hosts == nil {
+		c.Hosts = make(map[string][]string)
+	}
+	if slices.Contains(c.Hosts[title], host.String()) {
+		return nil
+	}
+	c.Hosts[title] = append(c.Hosts[title], host.String())
+	return c.update()
+}
+
+// Remove deletes the checkpoint state file
+func (c *Checkpoint) Remove() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Save writes the checkpoint to disk
+func (c *Checkpoint) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+// update writes the checkpoint to disk when it is persisted on every change. The caller must hold the lock.
+func (c *Checkpoint) update() error {
+	if !c.persist {
+		return nil
+	}
+	return c.save()
+}
+
+// save writes the checkpoint to disk via a temporary file to avoid leaving a partially written state file behind.
+// The caller must hold the lock.
+func (c *Checkpoint) save() error {
+	c.Updated = time.Now()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode checkpoint: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return fmt.Errorf("create checkpoint directory: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package phase
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
+
+type resumablePhase struct {
+	title    string
+	fail     bool
+	runCount int
+}
+
+func (p *resumablePhase) Title() string {
+	return p.title
+}
+
+func (p *resumablePhase) Resumable() bool {
+	return true
+}
+
+func (p *resumablePhase) Run(_ context.Context) error {
+	p.runCount++
+	if p.fail {
+		return fmt.Errorf("run failed")
+	}
+	return nil
+}
+
+func TestCheckpointPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	c := NewCheckpoint(path)
+	require.NoError(t, c.Bind("config"))
+	require.NoError(t, c.MarkPhase("phase 1"))
+	require.NoError(t, c.MarkHost("phase 2", stringer("host1")))
+	_, err := LoadCheckpoint(path)
+	require.Error(t, err, "a new checkpoint should only be written by Save")
+	require.NoError(t, c.Save())
+
+	loaded, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	require.True(t, loaded.PhaseDone("phase 1"))
+	require.False(t, loaded.PhaseDone("phase 2"))
+	require.True(t, loaded.HostDone("phase 2", stringer("host1")))
+	require.False(t, loaded.HostDone("phase 2", stringer("host2")))
+
+	require.NoError(t, loaded.Bind("config"))
+	require.ErrorIs(t, loaded.Bind("changed config"), ErrCheckpointMismatch)
+
+	// a loaded checkpoint is written on every change
+	require.NoError(t, loaded.MarkPhase("phase 2"))
+	reloaded, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	require.True(t, reloaded.PhaseDone("phase 2"))
+
+	require.NoError(t, loaded.Remove())
+	_, err = LoadCheckpoint(path)
+	require.Error(t, err)
+}
+
+func TestManagerResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	p1 := &resumablePhase{title: "phase 1"}
+	p2 := &resumablePhase{title: "phase 2", fail: true}
+
+	m := Manager{Config: &v1beta1.Cluster{Spec: &cluster.Spec{}}, Checkpoint: NewCheckpoint(path)}
+	m.AddPhase(p1, p2)
+	require.Error(t, m.Run(context.Background()))
+	require.Equal(t, 1, p1.runCount)
+	require.Equal(t, 1, p2.runCount)
+
+	checkpoint, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	require.True(t, checkpoint.PhaseDone("phase 1"))
+	require.False(t, checkpoint.PhaseDone("phase 2"))
+
+	p2.fail = false
+	m = Manager{Config: &v1beta1.Cluster{Spec: &cluster.Spec{}}, Checkpoint: checkpoint}
+	m.AddPhase(p1, p2)
+	require.NoError(t, m.Run(context.Background()))
+	require.Equal(t, 1, p1.runCount, "completed phase should have been skipped")
+	require.Equal(t, 2, p2.runCount)
+
+	_, err = LoadCheckpoint(path)
+	require.Error(t, err, "checkpoint should be removed after a successful run")
+}
+
+func TestManagerSaveCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	m := &Manager{Checkpoint: NewCheckpoint(path)}
+	m.MarkHostDone("phase", stringer("h1"))
+
+	m.SaveCheckpoint()
+	loaded, err := LoadCheckpoint(path)
+	require.NoError(t, err)
+	require.True(t, loaded.HostDone("phase", stringer("h1")))
+
+	dry := &Manager{Checkpoint: NewCheckpoint(filepath.Join(t.TempDir(), "state.json")), DryRun: true}
+	dry.SaveCheckpoint()
+	_, err = LoadCheckpoint(dry.Checkpoint.Path())
+	require.Error(t, err)
+}
@@ -0,0 +1,328 @@
+package phase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/k0sctl/pkg/retry"
+	"github.com/k0sproject/rig/v2/cmd"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ClusterLockName is the name of the Lease object used as the cluster-wide lock
+	ClusterLockName = "k0sctl"
+	// ClusterLockNamespace is the namespace of the Lease object used as the cluster-wide lock
+	ClusterLockNamespace = "kube-system"
+
+	clusterLockInfoAnnotation = "k0sctl.k0sproject.io/lock-info"
+	leaseTimeFormat           = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// ClusterLockLeaseDuration is the duration of the cluster-wide lock lease. It is longer than the
+// host lock lease because the lease can not be renewed while the k0s controllers are restarted
+// during an upgrade of a cluster with a single controller.
+var ClusterLockLeaseDuration = 5 * time.Minute
+
+// lease is the subset of a coordination.k8s.io/v1 Lease that k0sctl uses
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+}
+
+// expired returns true when the lease has no holder or has not been renewed in time
+func (l *lease) expired(now time.Time) bool {
+	if l.Spec.HolderIdentity == "" {
+		return true
+	}
+	renewed, err := time.Parse(leaseTimeFormat, l.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(l.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// holder returns a description of the lease holder
+func (l *lease) holder() string {
+	info := &LockInfo{}
+	if err := json.Unmarshal([]byte(l.Metadata.Annotations[clusterLockInfoAnnotation]), info); err == nil {
+		return info.String()
+	}
+	return l.Spec.HolderIdentity
+}
+
+// ClusterLock acquires a cluster-wide lock by taking a Lease in the kube-system namespace via the
+// leader's kubectl. Unlike the host locks, the lease also protects against concurrent operations
+// that target a different subset of the cluster's hosts.
+type ClusterLock struct {
+	GenericPhase
+
+	leader *cluster.Host
+	info   *LockInfo
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	m      sync.Mutex
+}
+
+// Title for the phase
+func (p *ClusterLock) Title() string {
+	return "Acquire cluster-wide lock"
+}
+
+// Prepare the phase
+func (p *ClusterLock) Prepare(c *v1beta1.Cluster) error {
+	p.Config = c
+	p.leader = p.Config.Spec.K0sLeader()
+	p.info = NewLockInfo()
+	p.info.Lease = int(ClusterLockLeaseDuration.Seconds())
+	return nil
+}
+
+// ShouldRun is true when the control plane is up
+func (p *ClusterLock) ShouldRun() bool {
+	return p.leader != nil && p.leader.Metadata.K0sRunningVersion != nil && !p.leader.Reset
+}
+
+// DryRun checks that no other k0sctl instance is holding the lock without acquiring it
+func (p *ClusterLock) DryRun() error {
+	current, err := p.get()
+	if err != nil {
+		return err
+	}
+	if current != nil && current.Spec.HolderIdentity != p.info.ID && !current.expired(lockHostTime(p.leader)) {
+		return fmt.Errorf("%w: the cluster is locked by %s", ErrLocked, current.holder())
+	}
+	p.DryMsg(p.leader, fmt.Sprintf("acquire lease %s/%s", ClusterLockNamespace, ClusterLockName))
+	return nil
+}
+
+// UnlockPhase returns an unlock phase for this lock phase
+func (p *ClusterLock) UnlockPhase() Phase {
+	return &Unlock{Cancel: p.Cancel}
+}
+
+// Cancel stops the renewal and releases the lease
+func (p *ClusterLock) Cancel() {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.wg.Wait()
+}
+
+// CleanUp calls Cancel to release the lock
+func (p *ClusterLock) CleanUp() {
+	p.Cancel()
+}
+
+// Run the phase
+func (p *ClusterLock) Run(ctx context.Context) error {
+	err := retry.Times(ctx, 10, func(_ context.Context) error {
+		return p.tryLock()
+	})
+	if err != nil {
+		return err
+	}
+	p.startTicker(ctx)
+	return nil
+}
+
+func (p *ClusterLock) startTicker(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	p.m.Lock()
+	p.cancel = cancel
+	p.m.Unlock()
+
+	p.wg.Add(1)
+	ticker := time.NewTicker(time.Duration(p.info.Lease) * time.Second / 3)
+	go func() {
+		defer p.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.renew(); err != nil {
+					if errors.Is(err, ErrLockLost) {
+						p.manager.Abort(fmt.Errorf("cluster-wide lock: %w", err))
+						continue
+					}
+					log.Warnf("failed to renew the cluster-wide lock lease: %s", err)
+				}
+			case <-ctx.Done():
+				if err := p.release(); err != nil {
+					log.Debugf("%s: failed to release the cluster-wide lock lease, the next invocation may be delayed until the lease expires: %s", p.leader, err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (p *ClusterLock) kubectl(args string, opts ...cmd.ExecOption) (string, error) {
+	return clusterLockKubectl(p.leader, args, opts...)
+}
+
+// clusterLockKubectl runs a kubectl command in the cluster lock namespace on the host
+func clusterLockKubectl(h *cluster.Host, args string, opts ...cmd.ExecOption) (string, error) {
+	return h.Sudo().ExecOutput(h.Configurer.KubectlCmdf(h, h.K0sDataDir(), "-n %s %s", ClusterLockNamespace, args), opts...)
+}
+
+// get returns the current lease or nil if it does not exist
+func (p *ClusterLock) get() (*lease, error) {
+	return p.getOn(p.leader)
+}
+
+// getOn returns the current lease through the host's kubectl
+func (p *ClusterLock) getOn(h *cluster.Host) (*lease, error) {
+	out, err := clusterLockKubectl(h, fmt.Sprintf("get lease %s --ignore-not-found -o json", ClusterLockName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lease: %w", err)
+	}
+	if strings.TrimSpace(out) == "" {
+		return nil, nil
+	}
+	l := &lease{}
+	if err := json.Unmarshal([]byte(out), l); err != nil {
+		return nil, fmt.Errorf("failed to decode lease: %w", err)
+	}
+	return l, nil
+}
+
+// put creates or replaces the lease. The resource version of an existing lease makes the
+// replace fail if the lease has been modified by someone else in the meantime.
+func (p *ClusterLock) put(current *lease) error {
+	return p.putOn(p.leader, current)
+}
+
+// putOn creates or replaces the lease through the host's kubectl
+func (p *ClusterLock) putOn(h *cluster.Host, current *lease) error {
+	now := lockHostTime(h).UTC().Format(leaseTimeFormat)
+	infoJSON, err := json.Marshal(p.info)
+	if err != nil {
+		return fmt.Errorf("failed to encode lock info: %w", err)
+	}
+
+	l := &lease{
+		APIVersion: "coordination.k8s.io/v1",
+		Kind:       "Lease",
+		Metadata: leaseMetadata{
+			Name:        ClusterLockName,
+			Namespace:   ClusterLockNamespace,
+			Annotations: map[string]string{clusterLockInfoAnnotation: string(infoJSON)},
+		},
+		Spec: leaseSpec{
+			HolderIdentity:       p.info.ID,
+			LeaseDurationSeconds: p.info.Lease,
+			AcquireTime:          now,
+			RenewTime:            now,
+		},
+	}
+
+	verb := "create"
+	if current != nil {
+		verb = "replace"
+		l.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+		if current.Spec.HolderIdentity == p.info.ID {
+			l.Spec.AcquireTime = current.Spec.AcquireTime
+		}
+	}
+
+	data, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to encode lease: %w", err)
+	}
+	if _, err := clusterLockKubectl(h, verb+" -f -", cmd.StdinString(string(data))); err != nil {
+		return fmt.Errorf("failed to %s lease: %w", verb, err)
+	}
+	return nil
+}
+
+func (p *ClusterLock) tryLock() error {
+	current, err := p.get()
+	if err != nil {
+		return err
+	}
+	if current != nil && current.Spec.HolderIdentity != p.info.ID && !current.expired(lockHostTime(p.leader)) {
+		return fmt.Errorf("%w: the cluster is locked by %s. use `k0sctl lock break --force` to remove a stale lock", ErrLocked, current.holder())
+	}
+	if current != nil && current.Spec.HolderIdentity != p.info.ID {
+		log.Warnf("%s: taking over an expired cluster-wide lock held by %s", p.leader, current.holder())
+	}
+	return p.put(current)
+}
+
+// renew renews the lease through the leader or, when that fails, for example because k0s is
+// being restarted on the leader, through the other running controllers
+func (p *ClusterLock) renew() error {
+	var errs []error
+	for _, h := range p.renewHosts() {
+		err := p.renewOn(h)
+		if err == nil || errors.Is(err, ErrLockLost) {
+			return err
+		}
+		log.Debugf("%s: failed to renew the cluster-wide lock lease: %s", h, err)
+		errs = append(errs, fmt.Errorf("%s: %w", h, err))
+	}
+	return errors.Join(errs...)
+}
+
+// renewHosts returns the leader followed by the other controllers that are running k0s
+func (p *ClusterLock) renewHosts() cluster.Hosts {
+	hosts := cluster.Hosts{p.leader}
+	for _, h := range p.Config.Spec.Hosts.Controllers() {
+		if h != p.leader && !h.Reset && h.Metadata.K0sRunningVersion != nil {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+func (p *ClusterLock) renewOn(h *cluster.Host) error {
+	current, err := p.getOn(h)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Spec.HolderIdentity != p.info.ID {
+		return ErrLockLost
+	}
+	return p.putOn(h, current)
+}
+
+func (p *ClusterLock) release() error {
+	current, err := p.get()
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Spec.HolderIdentity != p.info.ID {
+		return nil
+	}
+	if _, err := p.kubectl("delete lease " + ClusterLockName); err != nil {
+		return fmt.Errorf("failed to delete lease: %w", err)
+	}
+	return nil
+}
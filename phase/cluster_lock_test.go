@@ -0,0 +1,50 @@
+package phase
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/version"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaseExpired(t *testing.T) {
+	now := time.Now().UTC()
+	l := &lease{Spec: leaseSpec{HolderIdentity: "foo-1", LeaseDurationSeconds: 60, RenewTime: now.Format(leaseTimeFormat)}}
+	require.False(t, l.expired(now.Add(30*time.Second)))
+	require.True(t, l.expired(now.Add(61*time.Second)))
+
+	l.Spec.HolderIdentity = ""
+	require.True(t, l.expired(now))
+
+	l.Spec.HolderIdentity = "foo-1"
+	l.Spec.RenewTime = "garbage"
+	require.True(t, l.expired(now))
+}
+
+func TestLeaseHolder(t *testing.T) {
+	l := &lease{Spec: leaseSpec{HolderIdentity: "foo-1"}}
+	require.Equal(t, "foo-1", l.holder())
+
+	info := NewLockInfo()
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+	l.Metadata.Annotations = map[string]string{clusterLockInfoAnnotation: string(data)}
+	require.Contains(t, l.holder(), info.User+"@"+info.Hostname)
+}
+
+func TestClusterLockRenewHosts(t *testing.T) {
+	running := version.MustParse("v1.30.0+k0s.0")
+	leader := &cluster.Host{Role: "controller", Metadata: cluster.HostMetadata{K0sRunningVersion: running}}
+	other := &cluster.Host{Role: "controller+worker", Metadata: cluster.HostMetadata{K0sRunningVersion: running}}
+	stopped := &cluster.Host{Role: "controller"}
+	reset := &cluster.Host{Role: "controller", Reset: true, Metadata: cluster.HostMetadata{K0sRunningVersion: running}}
+	worker := &cluster.Host{Role: "worker", Metadata: cluster.HostMetadata{K0sRunningVersion: running}}
+
+	p := &ClusterLock{leader: leader}
+	p.Config = &v1beta1.Cluster{Spec: &cluster.Spec{Hosts: cluster.Hosts{stopped, other, leader, reset, worker}}}
+	require.Equal(t, cluster.Hosts{leader, other}, p.renewHosts())
+}
@@ -49,6 +49,42 @@ func (p *ConfigureK0s) Title() string {
 // Prepare the phase
 func (p *ConfigureK0s) Prepare(config *v1beta1.Cluster) error {
 	p.Config = config
+	if err := p.prepareBaseConfig(); err != nil {
+		return err
+	}
+
+	for _, h := range p.Config.Spec.Hosts.Controllers() {
+		if h.Reset {
+			continue
+		}
+
+		cfgNew, err := p.configFor(h)
+		if err != nil {
+			return fmt.Errorf("failed to build k0s config for %s: %w", h, err)
+		}
+		if err := p.validateHostConfig(h, cfgNew); err != nil {
+			return err
+		}
+
+		changed, err := configChanged(h, cfgNew)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			log.Debugf("%s: configuration will not change", h)
+			continue
+		}
+
+		log.Debugf("%s: configuration will change", h)
+		h.Metadata.K0sNewConfig = cfgNew
+		p.hosts = append(p.hosts, h)
+	}
+
+	return nil
+}
+
+// prepareBaseConfig builds the k0s configuration that the host specific configurations are based on
+func (p *ConfigureK0s) prepareBaseConfig() error {
 	p.leader = p.Config.Spec.K0sLeader()
 
 	if len(p.Config.Spec.K0s.Config) > 0 {
@@ -113,69 +149,56 @@ func (p *ConfigureK0s) Prepare(config *v1beta1.Cluster) error {
 	// assign populated sans to the base config
 	p.newBaseConfig.DigMapping("spec", "api")["sans"] = sans
 
-	for _, h := range p.Config.Spec.Hosts.Controllers() {
-		if h.Reset {
-			continue
-		}
-
-		cfgNew, err := p.configFor(h)
-		if err != nil {
-			return fmt.Errorf("failed to build k0s config for %s: %w", h, err)
-		}
-		tempConfigPath, err := h.FS().CreateTemp("", "")
-		if err != nil {
-			return fmt.Errorf("failed to create temporary file for config: %w", err)
-		}
-		defer func() {
-			if err := h.Sudo().FS().Remove(tempConfigPath); err != nil {
-				log.Warnf("%s: failed to delete temporary file %s: %s", h, tempConfigPath, err)
-			}
-		}()
-
-		if err := h.Sudo().FS().WriteFile(tempConfigPath, []byte(cfgNew), 0o600); err != nil {
-			return err
-		}
+	return nil
+}
 
-		// Prepare has no ctx; the Prepare interface takes only *v1beta1.Cluster.
-		// TODO: thread a real ctx if the Prepare interface ever takes a context.
-		if err := p.validateConfig(context.Background(), h, tempConfigPath); err != nil {
-			return err
+// validateHostConfig validates the configuration with the k0s binary on the host
+func (p *ConfigureK0s) validateHostConfig(h *cluster.Host, cfg string) error {
+	tempConfigPath, err := h.FS().CreateTemp("", "")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for config: %w", err)
+	}
+	defer func() {
+		if err := h.Sudo().FS().Remove(tempConfigPath); err != nil {
+			log.Warnf("%s: failed to delete temporary file %s: %s", h, tempConfigPath, err)
 		}
+	}()
 
-		cfgA := make(map[string]any)
-		cfgB := make(map[string]any)
-		if err := yaml.Unmarshal([]byte(cfgNew), &cfgA); err != nil {
-			return fmt.Errorf("failed to unmarshal new config: %w", err)
-		}
-		if err := yaml.Unmarshal([]byte(h.Metadata.K0sExistingConfig), &cfgB); err != nil {
-			return fmt.Errorf("failed to unmarshal existing config: %w", err)
-		}
-		cfgAString, err := yaml.Marshal(cfgA)
-		if err != nil {
-			return fmt.Errorf("failed to marshal new config: %w", err)
-		}
-		cfgBString, err := yaml.Marshal(cfgB)
-		if err != nil {
-			return fmt.Errorf("failed to marshal existing config: %w", err)
-		}
+	if err := h.Sudo().FS().WriteFile(tempConfigPath, []byte(cfg), 0o600); err != nil {
+		return err
+	}
 
-		if bytes.Equal(cfgAString, cfgBString) {
-			log.Debugf("%s: configuration will not change", h)
-			continue
-		}
+	// Prepare has no ctx; the Prepare interface takes only *v1beta1.Cluster.
+	// TODO: thread a real ctx if the Prepare interface ever takes a context.
+	return p.validateConfig(context.Background(), h, tempConfigPath)
+}
 
-		log.Debugf("%s: configuration will change", h)
-		h.Metadata.K0sNewConfig = cfgNew
-		p.hosts = append(p.hosts, h)
+// configChanged returns true when the new configuration differs from the existing configuration of the host
+func configChanged(h *cluster.Host, cfgNew string) (bool, error) {
+	cfgA := make(map[string]any)
+	cfgB := make(map[string]any)
+	if err := yaml.Unmarshal([]byte(cfgNew), &cfgA); err != nil {
+		return false, fmt.Errorf("failed to unmarshal new config: %w", err)
 	}
-
-	return nil
+	if err := yaml.Unmarshal([]byte(h.Metadata.K0sExistingConfig), &cfgB); err != nil {
+		return false, fmt.Errorf("failed to unmarshal existing config: %w", err)
+	}
+	cfgAString, err := yaml.Marshal(cfgA)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal new config: %w", err)
+	}
+	cfgBString, err := yaml.Marshal(cfgB)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal existing config: %w", err)
+	}
+	return !bytes.Equal(cfgAString, cfgBString), nil
 }
 
 // DryRun prints the actions that would be taken
 func (p *ConfigureK0s) DryRun() error {
 	for _, h := range p.hosts {
 		p.DryMsgf(h, "write k0s configuration to %s", h.Configurer.K0sConfigPath())
+		p.PlanChange(h, PlanAction{Action: PlanActionConfigure, Target: h.Configurer.K0sConfigPath(), Old: contentChecksum([]byte(h.Metadata.K0sExistingConfig)), New: contentChecksum([]byte(h.Metadata.K0sNewConfig))})
 		switch p.configSource {
 		case configSourceDefault:
 			p.DryMsg(h, "k0s configuration is based on a generated k0s default configuration")
@@ -391,3 +414,51 @@ func (p *ConfigureK0s) configFor(h *cluster.Host) (string, error) {
 	}
 	return fmt.Sprintf("# generated-by-k0sctl %s\n%s", time.Now().Format(time.RFC3339), c), nil
 }
+
+// k0sConfigChanges builds the k0s configuration for the controllers the same way as the phase
+// does, without writing or validating it, and returns the controllers that were compared and the
+// ones where the configuration on disk differs. The new configuration is stored into the host
+// metadata.
+func k0sConfigChanges(config *v1beta1.Cluster) (compared, changed cluster.Hosts, err error) {
+	compared = config.Spec.Hosts.Controllers().Filter(func(h *cluster.Host) bool {
+		return !h.Reset && h.Metadata.K0sBinaryVersion != nil
+	})
+	if len(compared) == 0 {
+		return nil, nil, nil
+	}
+
+	p := &ConfigureK0s{GenericPhase: GenericPhase{Config: config}}
+	if err := p.prepareBaseConfig(); err != nil {
+		return nil, nil, err
+	}
+	for _, h := range compared {
+		h.Metadata.K0sNewConfig = ""
+		cfgNew, err := p.configFor(h)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build k0s config for %s: %w", h, err)
+		}
+		hostChanged, err := configChanged(h, cfgNew)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", h, err)
+		}
+		if hostChanged {
+			h.Metadata.K0sNewConfig = cfgNew
+			changed = append(changed, h)
+		}
+	}
+	return compared, changed, nil
+}
+
+// normalizeK0sConfig re-encodes a k0s configuration to make it comparable, dropping comments
+// and ordering the keys
+func normalizeK0sConfig(cfg string) string {
+	m := make(map[string]any)
+	if err := yaml.Unmarshal([]byte(cfg), &m); err != nil {
+		return cfg
+	}
+	out, err := yaml.Marshal(m)
+	if err != nil {
+		return cfg
+	}
+	return string(out)
+}
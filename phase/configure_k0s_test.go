@@ -8,6 +8,8 @@ import (
 	"github.com/k0sproject/k0sctl/configurer/linux"
 	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
 	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/rig/v2"
+	"github.com/k0sproject/rig/v2/protocol/ssh"
 	"github.com/k0sproject/version"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
@@ -86,3 +88,34 @@ func apiAddressFromConfig(t *testing.T, cfg string) string {
 	require.NoError(t, yaml.Unmarshal([]byte(parts[1]), &parsed))
 	return parsed.Spec.API.Address
 }
+
+func TestK0sConfigChangesSkipsUninstalledControllers(t *testing.T) {
+	installed := &cluster.Host{
+		Role:            "controller",
+		CompositeConfig: rig.CompositeConfig{SSH: &ssh.Config{Address: "10.0.0.1", Port: 22}},
+		Metadata: cluster.HostMetadata{
+			K0sBinaryVersion:  version.MustParse("v1.30.0+k0s.0"),
+			K0sRunningVersion: version.MustParse("v1.30.0+k0s.0"),
+			K0sExistingConfig: "apiVersion: k0s.k0sproject.io/v1beta1\nkind: ClusterConfig\n",
+		},
+	}
+	// k0s is not installed on the host, so the configuration can not be validated on it
+	uninstalled := &cluster.Host{
+		Role:            "controller",
+		CompositeConfig: rig.CompositeConfig{SSH: &ssh.Config{Address: "10.0.0.2", Port: 22}},
+	}
+	config := &v1beta1.Cluster{Spec: &cluster.Spec{
+		Hosts: cluster.Hosts{installed, uninstalled},
+		K0s: &cluster.K0s{
+			Version: version.MustParse("v1.30.0+k0s.0"),
+			Config:  dig.Mapping{"spec": dig.Mapping{"api": dig.Mapping{"port": 6443}}},
+		},
+	}}
+
+	compared, changed, err := k0sConfigChanges(config)
+	require.NoError(t, err)
+	require.Equal(t, cluster.Hosts{installed}, compared)
+	require.Equal(t, cluster.Hosts{installed}, changed)
+	require.Contains(t, installed.Metadata.K0sNewConfig, "10.0.0.2")
+	require.Empty(t, uninstalled.Metadata.K0sNewConfig)
+}
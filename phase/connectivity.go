@@ -0,0 +1,490 @@
+package phase
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/k0sctl/pkg/retry"
+	log "github.com/sirupsen/logrus"
+)
+
+// Connectivity check statuses
+const (
+	ConnectivityOK      = "ok"
+	ConnectivityFailed  = "failed"
+	ConnectivityUnknown = "unknown"
+)
+
+// connectivityServices are the names of the k0s ports probed between the hosts
+var connectivityServices = map[int]string{
+	2380:  "etcd",
+	6443:  "kube-api",
+	8132:  "konnectivity",
+	9443:  "k0s-api",
+	10250: "kubelet",
+}
+
+// connectivityListenerTimeout is how long the temporary listeners stay up in seconds if they are not stopped
+const connectivityListenerTimeout = 300
+
+// connectivityListenerScript accepts and closes connections on the ports given as arguments. Ports that
+// are already in use are skipped, the process listening on them is probed instead.
+const connectivityListenerScript = `import socket,select,sys,time
+fam = socket.AF_INET6 if ":" in sys.argv[1] else socket.AF_INET
+bind = "::" if fam == socket.AF_INET6 else "0.0.0.0"
+socks = []
+for p in sys.argv[3:]:
+    s = socket.socket(fam)
+    s.setsockopt(socket.SOL_SOCKET, socket.SO_REUSEADDR, 1)
+    try:
+        s.bind((bind, int(p)))
+        s.listen(16)
+    except OSError:
+        s.close()
+        continue
+    socks.append(s)
+print("ready", flush=True)
+end = time.time() + int(sys.argv[2])
+while socks and time.time() < end:
+    r, _, _ = select.select(socks, [], [], 1)
+    for s in r:
+        c, _ = s.accept()
+        c.close()
+`
+
+// connectivityProbePython connects to the address,port targets given as arguments
+const connectivityProbePython = `import socket,sys
+for t in sys.argv[1:]:
+    a, p = t.rsplit(",", 1)
+    try:
+        socket.create_connection((a, int(p)), 3).close()
+        print(t, "ok")
+    except Exception:
+        print(t, "fail")
+`
+
+// ConnectivityResult is the result of probing a port of a host from another host
+type ConnectivityResult struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	Service string `json:"service"`
+	Status  string `json:"status"`
+}
+
+// ConnectivityPair is the summary of the probes from a host to another host
+type ConnectivityPair struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	OK      []string `json:"ok"`
+	Failed  []string `json:"failed"`
+	Unknown []string `json:"unknown"`
+}
+
+// Status returns the worst status of the probes between the pair
+func (c ConnectivityPair) Status() string {
+	switch {
+	case len(c.Failed) > 0:
+		return ConnectivityFailed
+	case len(c.Unknown) > 0:
+		return ConnectivityUnknown
+	default:
+		return ConnectivityOK
+	}
+}
+
+// connectivityFlow is a port that a host needs to reach on another host
+type connectivityFlow struct {
+	from *cluster.Host
+	to   *cluster.Host
+	port int
+}
+
+// CheckConnectivity starts temporary listeners on the k0s ports of the hosts and probes them from
+// the peers that need to reach them to detect firewalls blocking the traffic between the nodes
+type CheckConnectivity struct {
+	GenericPhase
+	// FailOnError makes the phase fail when a connection could not be established
+	FailOnError bool
+	// Results of the probes in the order of the hosts
+	Results []ConnectivityResult
+
+	hosts cluster.Hosts
+}
+
+// Title for the phase
+func (p *CheckConnectivity) Title() string {
+	return "Check network connectivity between hosts"
+}
+
+// Prepare the phase
+func (p *CheckConnectivity) Prepare(config *v1beta1.Cluster) error {
+	p.Config = config
+	p.hosts = p.Config.Spec.Hosts.Filter(func(h *cluster.Host) bool {
+		return !h.Reset && !h.IsWindows()
+	})
+	return nil
+}
+
+// ShouldRun is true when there are hosts that need to reach each other
+func (p *CheckConnectivity) ShouldRun() bool {
+	return len(p.hosts) > 1
+}
+
+// Run the phase
+func (p *CheckConnectivity) Run(ctx context.Context) error {
+	flows := connectivityFlows(p.hosts, p.Config.StorageType() == "etcd")
+	if len(flows) == 0 {
+		return nil
+	}
+
+	targets := make(map[*cluster.Host][]int)
+	for _, f := range flows {
+		if !slices.Contains(targets[f.to], f.port) {
+			targets[f.to] = append(targets[f.to], f.port)
+		}
+	}
+
+	var mu sync.Mutex
+	var listeners []*connectivityListener
+	defer func() {
+		for _, l := range listeners {
+			l.stop()
+		}
+	}()
+	unknown := make(map[*cluster.Host][]int)
+	err := p.parallelDo(ctx, p.hosts, func(ctx context.Context, h *cluster.Host) error {
+		ports, ok := targets[h]
+		if !ok {
+			return nil
+		}
+		l, missing := startConnectivityListener(ctx, h, ports)
+		mu.Lock()
+		defer mu.Unlock()
+		if l != nil {
+			listeners = append(listeners, l)
+		}
+		if len(missing) > 0 {
+			log.Warnf("%s: can not verify connectivity to ports %s, no temporary listener could be started", h, joinPorts(missing))
+			unknown[h] = missing
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	bySource := make(map[*cluster.Host][]connectivityFlow)
+	for _, f := range flows {
+		bySource[f.from] = append(bySource[f.from], f)
+	}
+	results := make(map[*cluster.Host][]ConnectivityResult)
+	err = p.parallelDo(ctx, p.hosts, func(_ context.Context, h *cluster.Host) error {
+		hostFlows, ok := bySource[h]
+		if !ok {
+			return nil
+		}
+		hostResults := probeConnectivity(h, hostFlows, unknown)
+		mu.Lock()
+		results[h] = hostResults
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.Results = nil
+	for _, h := range p.hosts {
+		p.Results = append(p.Results, results[h]...)
+	}
+
+	var failed int
+	for _, pair := range p.Pairs() {
+		switch pair.Status() {
+		case ConnectivityFailed:
+			failed++
+			log.Errorf("%s: can not connect to %s on ports %s", pair.From, pair.To, strings.Join(pair.Failed, ", "))
+		case ConnectivityUnknown:
+			log.Warnf("%s: connectivity to %s on ports %s could not be verified", pair.From, pair.To, strings.Join(pair.Unknown, ", "))
+		default:
+			log.Debugf("%s: can connect to %s on ports %s", pair.From, pair.To, strings.Join(pair.OK, ", "))
+		}
+	}
+	if failed > 0 && p.FailOnError {
+		return fmt.Errorf("network connectivity check failed between %d host pairs", failed)
+	}
+	return nil
+}
+
+// Pairs returns the results grouped by the source and the target hosts
+func (p *CheckConnectivity) Pairs() []ConnectivityPair {
+	return connectivityPairs(p.Results)
+}
+
+// Failed returns the number of failed probes
+func (p *CheckConnectivity) Failed() int {
+	var n int
+	for _, r := range p.Results {
+		if r.Status == ConnectivityFailed {
+			n++
+		}
+	}
+	return n
+}
+
+func connectivityPairs(results []ConnectivityResult) []ConnectivityPair {
+	var pairs []ConnectivityPair
+	index := make(map[[2]string]int)
+	for _, r := range results {
+		key := [2]string{r.From, r.To}
+		i, ok := index[key]
+		if !ok {
+			i = len(pairs)
+			index[key] = i
+			pairs = append(pairs, ConnectivityPair{From: r.From, To: r.To})
+		}
+		desc := fmt.Sprintf("%d/%s", r.Port, r.Service)
+		switch r.Status {
+		case ConnectivityOK:
+			pairs[i].OK = append(pairs[i].OK, desc)
+		case ConnectivityFailed:
+			pairs[i].Failed = append(pairs[i].Failed, desc)
+		default:
+			pairs[i].Unknown = append(pairs[i].Unknown, desc)
+		}
+	}
+	return pairs
+}
+
+// connectivityFlows returns the ports each host needs to reach on the other hosts. Controllers
+// talk to each other over etcd and the k0s and kubernetes apis, workers connect to the kubernetes
+// api and konnectivity on the controllers and to the kubelets of the other workers.
+func connectivityFlows(hosts cluster.Hosts, etcd bool) []connectivityFlow {
+	var flows []connectivityFlow
+	for _, from := range hosts {
+		for _, to := range hosts {
+			if from == to {
+				continue
+			}
+			var ports []int
+			if to.IsController() {
+				if from.IsController() {
+					if etcd {
+						ports = append(ports, 2380)
+					}
+					ports = append(ports, 6443, 9443)
+				}
+				if from.Role != "controller" {
+					ports = append(ports, 6443, 8132)
+				}
+			}
+			if to.Role != "controller" && from.Role != "controller" {
+				ports = append(ports, 10250)
+			}
+			slices.Sort(ports)
+			for _, port := range slices.Compact(ports) {
+				flows = append(flows, connectivityFlow{from: from, to: to, port: port})
+			}
+		}
+	}
+	return flows
+}
+
+// connectivityListener is a temporary listener process on a host
+type connectivityListener struct {
+	host *cluster.Host
+	pid  string
+	out  string
+}
+
+// startConnectivityListener starts a listener on the host for the ports that are not already in use.
+// The ports that can not be probed are returned.
+func startConnectivityListener(ctx context.Context, h *cluster.Host, ports []int) (*connectivityListener, []int) {
+	if !h.FS().CommandExist("python3") {
+		return nil, unlistenedPorts(h, ports)
+	}
+
+	out, err := h.ExecOutput("mktemp")
+	if err != nil {
+		log.Debugf("%s: failed to create a temporary file: %s", h, err)
+		return nil, unlistenedPorts(h, ports)
+	}
+	l := &connectivityListener{host: h, out: strings.TrimSpace(out)}
+
+	fs := h.FS()
+	args := []string{fs.ShellQuote(peerAddress(h)), strconv.Itoa(connectivityListenerTimeout)}
+	for _, port := range ports {
+		args = append(args, strconv.Itoa(port))
+	}
+	cmd := fmt.Sprintf("nohup python3 -c %s %s >%s 2>&1 & echo $!", fs.ShellQuote(connectivityListenerScript), strings.Join(args, " "), fs.ShellQuote(l.out))
+	pid, err := h.ExecOutput(cmd)
+	if err != nil {
+		log.Debugf("%s: failed to start the connectivity check listener: %s", h, err)
+		l.stop()
+		return nil, unlistenedPorts(h, ports)
+	}
+	l.pid = strings.TrimSpace(pid)
+	if _, err := strconv.Atoi(l.pid); err != nil {
+		l.pid = ""
+		l.stop()
+		return nil, unlistenedPorts(h, ports)
+	}
+
+	err = retry.Times(ctx, 10, func(_ context.Context) error {
+		out, err := h.ExecOutput("cat " + fs.ShellQuote(l.out))
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(out, "ready") {
+			return fmt.Errorf("listener not ready")
+		}
+		return nil
+	})
+	if err != nil {
+		log.Debugf("%s: connectivity check listener did not become ready: %s", h, err)
+		l.stop()
+		return nil, unlistenedPorts(h, ports)
+	}
+	return l, nil
+}
+
+// unlistenedPorts returns the ports nothing is listening on, all of the ports when it can not be checked
+func unlistenedPorts(h *cluster.Host, ports []int) []int {
+	if !h.FS().CommandExist("ss") {
+		return ports
+	}
+	out, err := h.ExecOutput("ss -Htln")
+	if err != nil {
+		return ports
+	}
+	listeners := parseListeners(out)
+	var missing []int
+	for _, port := range ports {
+		if _, ok := listeners[port]; !ok {
+			missing = append(missing, port)
+		}
+	}
+	return missing
+}
+
+// stop stops the listener and removes its output file
+func (l *connectivityListener) stop() {
+	if l.pid != "" {
+		if err := l.host.Exec("kill " + l.pid); err != nil {
+			log.Debugf("%s: failed to stop the connectivity check listener: %s", l.host, err)
+		}
+		l.pid = ""
+	}
+	if l.out != "" {
+		if err := l.host.Exec("rm -f " + l.host.FS().ShellQuote(l.out)); err != nil {
+			log.Debugf("%s: failed to remove %s: %s", l.host, l.out, err)
+		}
+		l.out = ""
+	}
+}
+
+// probeConnectivity probes the targets of the flows from the host
+func probeConnectivity(h *cluster.Host, flows []connectivityFlow, unknown map[*cluster.Host][]int) []ConnectivityResult {
+	results := make([]ConnectivityResult, 0, len(flows))
+	var targets []string
+	for _, f := range flows {
+		r := ConnectivityResult{
+			From:    h.String(),
+			To:      f.to.String(),
+			Address: peerAddress(f.to),
+			Port:    f.port,
+			Service: connectivityServices[f.port],
+			Status:  ConnectivityUnknown,
+		}
+		results = append(results, r)
+		if !slices.Contains(unknown[f.to], f.port) {
+			targets = append(targets, connectivityTarget(r.Address, r.Port))
+		}
+	}
+	if len(targets) == 0 {
+		return results
+	}
+
+	cmd := connectivityProbeCommand(h, targets)
+	if cmd == "" {
+		log.Warnf("%s: can not probe the connectivity to the other hosts without python3, nc or bash", h)
+		return results
+	}
+	out, err := h.ExecOutput(cmd)
+	if err != nil {
+		log.Warnf("%s: failed to probe the connectivity to the other hosts: %s", h, err)
+		return results
+	}
+	statuses := parseConnectivityProbes(out)
+	for i, r := range results {
+		if status, ok := statuses[connectivityTarget(r.Address, r.Port)]; ok {
+			results[i].Status = status
+		}
+	}
+	return results
+}
+
+// connectivityTarget returns the address,port argument of the probe commands
+func connectivityTarget(addr string, port int) string {
+	return addr + "," + strconv.Itoa(port)
+}
+
+// connectivityProbeCommand returns a command that probes the targets using the tools available on the
+// host or an empty string when there are none
+func connectivityProbeCommand(h *cluster.Host, targets []string) string {
+	fs := h.FS()
+	quoted := make([]string, len(targets))
+	for i, t := range targets {
+		quoted[i] = fs.ShellQuote(t)
+	}
+	switch {
+	case fs.CommandExist("python3"):
+		return fmt.Sprintf("python3 -c %s %s", fs.ShellQuote(connectivityProbePython), strings.Join(quoted, " "))
+	case fs.CommandExist("nc"):
+		return connectivityShellProbe(`nc -z -w 3 "${t%,*}" "${t##*,}" >/dev/null 2>&1`, quoted)
+	case fs.CommandExist("bash") && fs.CommandExist("timeout"):
+		return "bash -c " + fs.ShellQuote(connectivityShellProbe(`timeout 3 bash -c ": >/dev/tcp/${t%,*}/${t##*,}" 2>/dev/null`, quoted))
+	default:
+		return ""
+	}
+}
+
+func connectivityShellProbe(probe string, targets []string) string {
+	return fmt.Sprintf(`for t in %s; do if %s; then echo "$t ok"; else echo "$t fail"; fi; done`, strings.Join(targets, " "), probe)
+}
+
+// parseConnectivityProbes returns the statuses of the targets from the output of the probe commands
+func parseConnectivityProbes(out string) map[string]string {
+	statuses := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[1] {
+		case "ok":
+			statuses[fields[0]] = ConnectivityOK
+		case "fail":
+			statuses[fields[0]] = ConnectivityFailed
+		}
+	}
+	return statuses
+}
+
+func joinPorts(ports []int) string {
+	s := make([]string, len(ports))
+	for i, port := range ports {
+		s[i] = strconv.Itoa(port)
+	}
+	return strings.Join(s, ", ")
+}
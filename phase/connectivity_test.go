@@ -0,0 +1,58 @@
+package phase
+
+import (
+	"testing"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectivityFlows(t *testing.T) {
+	c1 := &cluster.Host{Role: "controller"}
+	c2 := &cluster.Host{Role: "controller+worker"}
+	w1 := &cluster.Host{Role: "worker"}
+
+	ports := func(flows []connectivityFlow, from, to *cluster.Host) []int {
+		var ports []int
+		for _, f := range flows {
+			if f.from == from && f.to == to {
+				ports = append(ports, f.port)
+			}
+		}
+		return ports
+	}
+
+	flows := connectivityFlows(cluster.Hosts{c1, c2, w1}, true)
+	require.Equal(t, []int{2380, 6443, 9443}, ports(flows, c1, c2))
+	require.Equal(t, []int{2380, 6443, 8132, 9443}, ports(flows, c2, c1))
+	require.Empty(t, ports(flows, c1, w1))
+	require.Equal(t, []int{10250}, ports(flows, c2, w1))
+	require.Equal(t, []int{6443, 8132}, ports(flows, w1, c1))
+	require.Equal(t, []int{6443, 8132, 10250}, ports(flows, w1, c2))
+
+	flows = connectivityFlows(cluster.Hosts{c1, c2}, false)
+	require.Equal(t, []int{6443, 9443}, ports(flows, c1, c2))
+}
+
+func TestParseConnectivityProbes(t *testing.T) {
+	out := "10.0.0.1,6443 ok\n10.0.0.1,8132 fail\nfe80::1,10250 ok\ngarbage\n"
+	require.Equal(t, map[string]string{
+		"10.0.0.1,6443": ConnectivityOK,
+		"10.0.0.1,8132": ConnectivityFailed,
+		"fe80::1,10250": ConnectivityOK,
+	}, parseConnectivityProbes(out))
+}
+
+func TestConnectivityPairs(t *testing.T) {
+	pairs := connectivityPairs([]ConnectivityResult{
+		{From: "a", To: "b", Port: 6443, Service: "kube-api", Status: ConnectivityOK},
+		{From: "a", To: "b", Port: 8132, Service: "konnectivity", Status: ConnectivityFailed},
+		{From: "a", To: "c", Port: 10250, Service: "kubelet", Status: ConnectivityUnknown},
+		{From: "b", To: "a", Port: 6443, Service: "kube-api", Status: ConnectivityOK},
+	})
+	require.Len(t, pairs, 3)
+	require.Equal(t, ConnectivityPair{From: "a", To: "b", OK: []string{"6443/kube-api"}, Failed: []string{"8132/konnectivity"}}, pairs[0])
+	require.Equal(t, ConnectivityFailed, pairs[0].Status())
+	require.Equal(t, ConnectivityUnknown, pairs[1].Status())
+	require.Equal(t, ConnectivityOK, pairs[2].Status())
+}
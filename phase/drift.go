@@ -0,0 +1,325 @@
+package phase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/rig/v2/cmd"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	log "github.com/sirupsen/logrus"
+)
+
+// Drift kinds
+const (
+	DriftVersion     = "version"
+	DriftFlags       = "installFlags"
+	DriftConfig      = "k0sConfig"
+	DriftFile        = "file"
+	DriftEnvironment = "environment"
+	DriftUnmanaged   = "unmanaged"
+)
+
+// Drift describes a difference between the configuration and the live state of a host
+type Drift struct {
+	// Host is empty for cluster level drift such as nodes that are not in the configuration
+	Host     string `json:"host,omitempty"`
+	Kind     string `json:"kind"`
+	Target   string `json:"target,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Message  string `json:"message"`
+	// Diff is a textual diff for k0s configuration drift
+	Diff string `json:"diff,omitempty"`
+}
+
+// DetectDrift compares the configuration against the facts gathered from the hosts without
+// changing anything. It expects the facts to have been gathered by the GatherFacts and
+// GatherK0sFacts phases.
+type DetectDrift struct {
+	GenericPhase
+
+	// Drifts is populated with the detected drift, host level drift in the order of the configuration
+	Drifts []Drift
+
+	mu sync.Mutex
+}
+
+// Title for the phase
+func (p *DetectDrift) Title() string {
+	return "Detect configuration drift"
+}
+
+// Run the phase
+func (p *DetectDrift) Run(ctx context.Context) error {
+	hosts := p.Config.Spec.Hosts.Filter(func(h *cluster.Host) bool {
+		return !h.Reset
+	})
+
+	drifts := make(map[*cluster.Host][]Drift, len(hosts))
+	err := p.parallelDo(ctx, hosts, func(_ context.Context, h *cluster.Host) error {
+		d := p.hostDrift(h)
+		p.mu.Lock()
+		drifts[h] = d
+		p.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	configDrift, err := p.configDrift()
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hosts {
+		p.Drifts = append(p.Drifts, drifts[h]...)
+		p.Drifts = append(p.Drifts, configDrift[h]...)
+	}
+
+	unmanaged, err := p.unmanagedDrift()
+	if err != nil {
+		return err
+	}
+	p.Drifts = append(p.Drifts, unmanaged...)
+
+	return nil
+}
+
+func (p *DetectDrift) hostDrift(h *cluster.Host) []Drift {
+	var drifts []Drift
+	add := func(d Drift) {
+		d.Host = h.String()
+		log.Debugf("%s: drift detected: %s", h, d.Message)
+		drifts = append(drifts, d)
+	}
+
+	desired := p.Config.Spec.K0s.Version
+	switch {
+	case h.Metadata.K0sBinaryVersion == nil:
+		add(Drift{Kind: DriftVersion, Expected: desired.String(), Message: "k0s is not installed"})
+	case h.Metadata.K0sRunningVersion == nil:
+		add(Drift{Kind: DriftVersion, Expected: desired.String(), Actual: h.Metadata.K0sBinaryVersion.String(), Message: "k0s is not running"})
+	case desired != nil && !desired.Equal(h.Metadata.K0sRunningVersion):
+		add(Drift{Kind: DriftVersion, Expected: desired.String(), Actual: h.Metadata.K0sRunningVersion.String(), Message: fmt.Sprintf("k0s version %s is running instead of %s", h.Metadata.K0sRunningVersion, desired)})
+	}
+
+	if h.Metadata.K0sRunningVersion != nil && h.FlagsChanged() {
+		expected, err := h.K0sInstallFlags()
+		if err != nil {
+			log.Warnf("%s: could not get install flags: %s", h, err)
+		}
+		add(Drift{Kind: DriftFlags, Expected: strings.Join(expected, " "), Actual: strings.Join(h.Metadata.K0sStatusArgs, " "), Message: "k0s install flags differ from the running k0s"})
+	}
+
+	for _, d := range p.fileDrift(h) {
+		add(d)
+	}
+
+	for _, d := range p.environmentDrift(h) {
+		add(d)
+	}
+
+	return drifts
+}
+
+// fileDrift compares the checksums of the local upload files to the files on the host
+func (p *DetectDrift) fileDrift(h *cluster.Host) []Drift {
+	var drifts []Drift
+	for _, f := range h.Files {
+		switch {
+		case f.IsURL():
+			log.Debugf("%s: skipping drift detection for the url upload %s", h, f.Source)
+		case len(f.Sources) > 0:
+			for _, s := range f.Sources {
+				dest := f.DestinationFile
+				if dest == "" {
+					dest = path.Join(f.DestinationDir, s.Path)
+				}
+				data, err := os.ReadFile(path.Join(f.Base, s.Path))
+				if err != nil {
+					log.Warnf("%s: failed to read %s for checksum: %s", h, path.Join(f.Base, s.Path), err)
+					continue
+				}
+				if d := remoteFileDrift(h, dest, data); d != nil {
+					drifts = append(drifts, *d)
+				}
+			}
+		case f.HasData():
+			dest := f.DestinationFile
+			if dest == "" {
+				dest = path.Join(f.DestinationDir, f.Name)
+			}
+			if d := remoteFileDrift(h, dest, []byte(f.Data)); d != nil {
+				drifts = append(drifts, *d)
+			}
+		}
+	}
+	return drifts
+}
+
+func remoteFileDrift(h *cluster.Host, dest string, data []byte) *Drift {
+	expected := sha256Checksum(data)
+	if !h.Sudo().FS().FileExist(dest) {
+		return &Drift{Kind: DriftFile, Target: dest, Expected: expected, Message: fmt.Sprintf("file %s does not exist", dest)}
+	}
+	remote, err := h.Sudo().FS().ReadFile(dest)
+	if err != nil {
+		log.Warnf("%s: failed to read %s for checksum: %s", h, dest, err)
+		return nil
+	}
+	actual := sha256Checksum(remote)
+	if actual == expected {
+		return nil
+	}
+	return &Drift{Kind: DriftFile, Target: dest, Expected: expected, Actual: actual, Message: fmt.Sprintf("file %s content differs", dest)}
+}
+
+// sha256Checksum is like contentChecksum but also returns a checksum for empty data
+func sha256Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// environmentDrift compares the host environment to the values written into /etc/environment by the PrepareHosts phase
+func (p *DetectDrift) environmentDrift(h *cluster.Host) []Drift {
+	if len(h.Environment) == 0 {
+		return nil
+	}
+	if h.IsWindows() {
+		log.Debugf("%s: skipping environment drift detection on windows", h)
+		return nil
+	}
+
+	actual := make(map[string]string)
+	if data, err := h.Sudo().FS().ReadFile("/etc/environment"); err == nil {
+		actual = parseEnvironment(string(data))
+	} else {
+		log.Debugf("%s: failed to read /etc/environment: %s", h, err)
+	}
+
+	keys := make([]string, 0, len(h.Environment))
+	for k := range h.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var drifts []Drift
+	for _, k := range keys {
+		value, ok := actual[k]
+		switch {
+		case !ok:
+			drifts = append(drifts, Drift{Kind: DriftEnvironment, Target: k, Message: fmt.Sprintf("environment variable %s is not set", k)})
+		case value != h.Environment[k]:
+			// the values are not included as they may contain credentials
+			drifts = append(drifts, Drift{Kind: DriftEnvironment, Target: k, Message: fmt.Sprintf("environment variable %s has a different value", k)})
+		}
+	}
+	return drifts
+}
+
+// parseEnvironment parses the KEY=value lines of an /etc/environment file
+func parseEnvironment(data string) map[string]string {
+	env := make(map[string]string)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		env[strings.TrimSpace(strings.TrimPrefix(k, "export "))] = v
+	}
+	return env
+}
+
+// configDrift reports the controllers where the k0s configuration on disk differs from the configuration
+func (p *DetectDrift) configDrift() (map[*cluster.Host][]Drift, error) {
+	leader := p.Config.Spec.K0sLeader()
+	if leader == nil || leader.Metadata.K0sBinaryVersion == nil {
+		return nil, nil
+	}
+
+	_, changed, err := k0sConfigChanges(p.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the k0s configuration for comparison: %w", err)
+	}
+
+	drifts := make(map[*cluster.Host][]Drift, len(changed))
+	for _, h := range changed {
+		d := Drift{Host: h.String(), Kind: DriftConfig, Target: h.Configurer.K0sConfigPath()}
+		if h.Metadata.K0sExistingConfig == "" {
+			d.Message = "k0s configuration does not exist"
+		} else {
+			d.Message = "k0s configuration differs"
+			existing := normalizeK0sConfig(h.Metadata.K0sExistingConfig)
+			desired := normalizeK0sConfig(h.Metadata.K0sNewConfig)
+			dmp := diffmatchpatch.New()
+			d.Diff = dmp.PatchToText(dmp.PatchMake(existing, dmp.DiffMain(existing, desired, false)))
+		}
+		drifts[h] = append(drifts[h], d)
+	}
+	return drifts, nil
+}
+
+// kubeNodeList represents the output of `kubectl get nodes -o json`
+type kubeNodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+// unmanagedDrift reports the kubernetes nodes and etcd members that do not have a matching host in the configuration
+func (p *DetectDrift) unmanagedDrift() ([]Drift, error) {
+	var drifts []Drift
+
+	controllers := p.Config.Spec.Hosts.Controllers()
+	for _, member := range p.Config.Metadata.EtcdMembers {
+		known := controllers.Find(func(h *cluster.Host) bool {
+			return h.PrivateAddress == member || h.Address() == member
+		})
+		if known == nil {
+			drifts = append(drifts, Drift{Kind: DriftUnmanaged, Target: member, Message: fmt.Sprintf("etcd member %s is not in the configuration", member)})
+		}
+	}
+
+	leader := p.Config.Spec.K0sLeader()
+	if leader == nil || leader.Metadata.K0sRunningVersion == nil {
+		return drifts, nil
+	}
+
+	output, err := leader.Sudo().ExecOutput(leader.Configurer.KubectlCmdf(leader, leader.K0sDataDir(), "get nodes -o json"), cmd.HideOutput())
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to list kubernetes nodes: %w", leader, err)
+	}
+	nodes := &kubeNodeList{}
+	if err := json.Unmarshal([]byte(output), nodes); err != nil {
+		return nil, fmt.Errorf("%s: failed to decode kubectl get nodes output: %w", leader, err)
+	}
+
+	var names []string
+	for _, h := range p.Config.Spec.Hosts {
+		names = append(names, h.KubernetesNodeName())
+	}
+	for _, n := range nodes.Items {
+		if !slices.Contains(names, n.Metadata.Name) {
+			drifts = append(drifts, Drift{Kind: DriftUnmanaged, Target: n.Metadata.Name, Message: fmt.Sprintf("kubernetes node %s is not in the configuration", n.Metadata.Name)})
+		}
+	}
+
+	return drifts, nil
+}
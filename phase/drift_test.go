@@ -0,0 +1,28 @@
+package phase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvironment(t *testing.T) {
+	env := parseEnvironment("# comment\nPATH=/usr/bin:/bin\n\nHTTP_PROXY=http://proxy:3128\nexport FOO=bar=baz\ninvalid\n")
+	require.Equal(t, map[string]string{
+		"PATH":       "/usr/bin:/bin",
+		"HTTP_PROXY": "http://proxy:3128",
+		"FOO":        "bar=baz",
+	}, env)
+}
+
+func TestNormalizeK0sConfig(t *testing.T) {
+	a := "# generated-by-k0sctl 2026-01-01T00:00:00Z\nkind: ClusterConfig\napiVersion: k0s.k0sproject.io/v1beta1\n"
+	b := "apiVersion: k0s.k0sproject.io/v1beta1\nkind: ClusterConfig\n"
+	require.Equal(t, normalizeK0sConfig(a), normalizeK0sConfig(b))
+	require.NotEqual(t, normalizeK0sConfig(a), normalizeK0sConfig("kind: Other\n"))
+}
+
+func TestSha256Checksum(t *testing.T) {
+	require.Equal(t, "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", sha256Checksum(nil))
+	require.Equal(t, contentChecksum([]byte("foo")), sha256Checksum([]byte("foo")))
+}
@@ -0,0 +1,132 @@
+package phase
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+)
+
+// EventType identifies the kind of an Event
+type EventType string
+
+// Event types emitted by the Manager
+const (
+	EventPhaseStarted   EventType = "phaseStarted"
+	EventPhaseFinished  EventType = "phaseFinished"
+	EventPhaseSkipped   EventType = "phaseSkipped"
+	EventHostStarted    EventType = "hostStarted"
+	EventHostFinished   EventType = "hostFinished"
+	EventDryRun         EventType = "dryRun"
+	EventHookOutput     EventType = "hookOutput"
+	EventHostRolledBack EventType = "hostRolledBack"
+)
+
+// Event describes a progress event of a Manager run
+type Event struct {
+	Time  time.Time `json:"time"`
+	Type  EventType `json:"type"`
+	Phase string    `json:"phase,omitempty"`
+	Host  string    `json:"host,omitempty"`
+	// Message is a free-form message, such as a dry-run message or a reason for skipping a phase
+	Message string `json:"message,omitempty"`
+	// Command is the hook command for hook output events
+	Command string `json:"command,omitempty"`
+	// Output is the output of a hook command
+	Output string `json:"output,omitempty"`
+	// Error is set when a phase or a host step failed
+	Error string `json:"error,omitempty"`
+	// Duration is the duration of a finished phase or host step in seconds
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// Observer receives events from the Manager. The OnEvent function can be called concurrently
+// from multiple goroutines.
+type Observer interface {
+	OnEvent(Event)
+}
+
+// ObserverFunc is an adapter to allow the use of ordinary functions as observers
+type ObserverFunc func(Event)
+
+// OnEvent calls f(e)
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+type jsonObserver struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONObserver returns an observer that writes the events into the writer as newline delimited JSON
+func NewJSONObserver(w io.Writer) Observer {
+	return &jsonObserver{enc: json.NewEncoder(w)}
+}
+
+// OnEvent encodes the event as a single line of JSON
+func (o *jsonObserver) OnEvent(e Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_ = o.enc.Encode(e)
+}
+
+// AddObserver adds an observer that receives the events emitted during Run
+func (m *Manager) AddObserver(o Observer) {
+	m.observers = append(m.observers, o)
+}
+
+// Emit sends an event to the observers. The time and the current phase are filled in when not set.
+func (m *Manager) Emit(e Event) {
+	if m == nil || len(m.observers) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Phase == "" {
+		m.phaseMu.Lock()
+		e.Phase = m.currentPhase
+		m.phaseMu.Unlock()
+	}
+	for _, o := range m.observers {
+		o.OnEvent(e)
+	}
+}
+
+// withHostEvents wraps the per-host functions given to the Hosts iterators so that host
+// started and finished events are emitted around them.
+func (p *GenericPhase) withHostEvents(funcs []func(context.Context, *cluster.Host) error) []func(context.Context, *cluster.Host) error {
+	if p.manager == nil || len(p.manager.observers) == 0 {
+		return funcs
+	}
+
+	var started sync.Map
+	wrapped := make([]func(context.Context, *cluster.Host) error, len(funcs))
+	for i, fn := range funcs {
+		last := i == len(funcs)-1
+		first := i == 0
+		wrapped[i] = func(ctx context.Context, h *cluster.Host) error {
+			if first {
+				started.Store(h, time.Now())
+				p.manager.Emit(Event{Type: EventHostStarted, Host: h.String()})
+			}
+			err := fn(ctx, h)
+			if err != nil || last {
+				e := Event{Type: EventHostFinished, Host: h.String()}
+				if start, ok := started.Load(h); ok {
+					e.Duration = time.Since(start.(time.Time)).Seconds()
+				}
+				if err != nil {
+					e.Error = err.Error()
+				}
+				p.manager.Emit(e)
+			}
+			return err
+		}
+	}
+	return wrapped
+}
@@ -0,0 +1,127 @@
+package phase
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) OnEvent(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) types() []EventType {
+	var types []EventType
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+type hostsPhase struct {
+	GenericPhase
+}
+
+func (p *hostsPhase) Title() string {
+	return "hosts phase"
+}
+
+func (p *hostsPhase) Run(ctx context.Context) error {
+	return p.parallelDo(ctx, p.Config.Spec.Hosts, func(_ context.Context, h *cluster.Host) error {
+		p.DryMsg(h, "do something")
+		return nil
+	})
+}
+
+func TestManagerEvents(t *testing.T) {
+	h := &cluster.Host{Role: "worker"}
+	m := Manager{Config: &v1beta1.Cluster{Spec: &cluster.Spec{Hosts: cluster.Hosts{h}}}, DryRun: true, Writer: &bytes.Buffer{}}
+	rec := &eventRecorder{}
+	m.AddObserver(rec)
+	m.AddPhase(&conditionalPhase{}, &hostsPhase{})
+	require.NoError(t, m.Run(context.Background()))
+
+	require.Equal(t, []EventType{
+		EventPhaseSkipped,
+		EventPhaseStarted,
+		EventHostStarted,
+		EventDryRun,
+		EventHostFinished,
+		EventPhaseFinished,
+	}, rec.types())
+	require.Equal(t, "conditional phase", rec.events[0].Phase)
+	for _, e := range rec.events[1:] {
+		require.Equal(t, "hosts phase", e.Phase)
+		require.False(t, e.Time.IsZero())
+	}
+	require.Equal(t, h.String(), rec.events[2].Host)
+	require.Equal(t, "do something", rec.events[3].Message)
+}
+
+func TestManagerEventsError(t *testing.T) {
+	m := Manager{Config: &v1beta1.Cluster{Spec: &cluster.Spec{}}}
+	rec := &eventRecorder{}
+	m.AddObserver(rec)
+	m.AddPhase(&hookedPhase{})
+	require.Error(t, m.Run(context.Background()))
+
+	require.Equal(t, []EventType{EventPhaseStarted, EventPhaseFinished}, rec.types())
+	require.Equal(t, "run failed", rec.events[1].Error)
+}
+
+func TestManagerEmitWhilePhaseChanges(t *testing.T) {
+	m := &Manager{}
+	rec := &eventRecorder{}
+	m.AddObserver(rec)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			m.Emit(Event{Type: EventDryRun})
+		}
+	}()
+	for range 100 {
+		m.setCurrentPhase("one")
+		m.setCurrentPhase("two")
+	}
+	wg.Wait()
+
+	require.Len(t, rec.events, 100)
+	for _, e := range rec.events {
+		require.Contains(t, []string{"", "one", "two"}, e.Phase)
+	}
+}
+
+func TestJSONObserver(t *testing.T) {
+	var buf bytes.Buffer
+	o := NewJSONObserver(&buf)
+	o.OnEvent(Event{Type: EventPhaseStarted, Phase: "one"})
+	o.OnEvent(Event{Type: EventPhaseFinished, Phase: "one", Duration: 1.5})
+
+	scanner := bufio.NewScanner(&buf)
+	var events []Event
+	for scanner.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	require.Len(t, events, 2)
+	require.Equal(t, EventPhaseFinished, events[1].Type)
+	require.Equal(t, 1.5, events[1].Duration)
+}
@@ -3,6 +3,7 @@ package phase
 import (
     "context"
     "fmt"
+    "strings"
 
     "github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
     "github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
@@ -46,12 +47,26 @@ func (p *GenericPhase) DryMsgf(host fmt.Stringer, msg string, args ...any) {
 	p.manager.DryMsg(host, fmt.Sprintf(msg, args...))
 }
 
+// PlanChange is a shorthand for manager.PlanChange
+func (p *GenericPhase) PlanChange(host fmt.Stringer, action PlanAction) {
+	p.manager.PlanChange(host, action)
+}
+
+// planInstall records the k0s installation on the host into the plan
+func (p *GenericPhase) planInstall(h *cluster.Host) {
+	p.PlanChange(h, PlanAction{Action: PlanActionInstall, Target: "k0s " + h.Role, New: p.Config.Spec.K0s.Version.String()})
+	if flags, err := h.K0sInstallFlags(); err == nil {
+		p.PlanChange(h, PlanAction{Action: PlanActionInstall, Target: "installFlags", New: strings.Join(flags, " ")})
+	}
+}
+
 // SetManager adds a reference to the phase manager
 func (p *GenericPhase) SetManager(m *Manager) {
 	p.manager = m
 }
 
 func (p *GenericPhase) parallelDo(ctx context.Context, hosts cluster.Hosts, funcs ...func(context.Context, *cluster.Host) error) error {
+	funcs = p.withHostEvents(funcs)
 	if p.manager.Concurrency == 0 {
 		return hosts.ParallelEach(ctx, funcs...)
 	}
@@ -59,6 +74,7 @@ func (p *GenericPhase) parallelDo(ctx context.Context, hosts cluster.Hosts, func
 }
 
 func (p *GenericPhase) parallelDoUpload(ctx context.Context, hosts cluster.Hosts, funcs ...func(context.Context, *cluster.Host) error) error {
+	funcs = p.withHostEvents(funcs)
 	if p.manager.Concurrency == 0 {
 		return hosts.ParallelEach(ctx, funcs...)
 	}
@@ -85,7 +101,13 @@ func (p *GenericPhase) runHooks(ctx context.Context, action, stage string, hosts
             return nil
         }
 
-        if err := h.RunHooks(ctx, action, stage); err != nil {
+        var output func(cmd, out string)
+        if len(p.manager.observers) > 0 {
+            output = func(cmd, out string) {
+                p.manager.Emit(Event{Type: EventHookOutput, Host: h.String(), Command: cmd, Output: out})
+            }
+        }
+        if err := h.RunHooksWithOutput(ctx, action, stage, output); err != nil {
             return fmt.Errorf("running hooks failed: %w", err)
         }
 
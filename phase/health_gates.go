@@ -0,0 +1,179 @@
+package phase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/k0sctl/pkg/retry"
+	"github.com/k0sproject/rig/v2/cmd"
+	log "github.com/sirupsen/logrus"
+)
+
+// kubeNodeStatusList represents the parts of the output of `kubectl get nodes -o json` needed for
+// checking the node readiness
+type kubeNodeStatusList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Status struct {
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type kubeContainerStatus struct {
+	Name  string `json:"name"`
+	State struct {
+		Waiting *struct {
+			Reason string `json:"reason"`
+		} `json:"waiting"`
+	} `json:"state"`
+}
+
+// kubePodList represents the parts of the output of `kubectl get pods -o json` needed for
+// finding crash looping containers
+type kubePodList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Status struct {
+			InitContainerStatuses []kubeContainerStatus `json:"initContainerStatuses"`
+			ContainerStatuses     []kubeContainerStatus `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// notReadyNodes returns the names of the nodes in the `kubectl get nodes -o json` output that are not ready
+func notReadyNodes(out string) ([]string, error) {
+	nodes := &kubeNodeStatusList{}
+	if err := json.Unmarshal([]byte(out), nodes); err != nil {
+		return nil, fmt.Errorf("decode kubectl get nodes output: %w", err)
+	}
+	var notReady []string
+	for _, n := range nodes.Items {
+		ready := false
+		for _, c := range n.Status.Conditions {
+			if c.Type == "Ready" {
+				ready = c.Status == "True"
+				break
+			}
+		}
+		if !ready {
+			notReady = append(notReady, n.Metadata.Name)
+		}
+	}
+	return notReady, nil
+}
+
+// crashLoopingPods returns the namespace/name of the pods in the `kubectl get pods -o json` output
+// that have a container in CrashLoopBackOff
+func crashLoopingPods(out string) ([]string, error) {
+	pods := &kubePodList{}
+	if err := json.Unmarshal([]byte(out), pods); err != nil {
+		return nil, fmt.Errorf("decode kubectl get pods output: %w", err)
+	}
+	var crashing []string
+	for _, p := range pods.Items {
+		for _, s := range append(p.Status.InitContainerStatuses, p.Status.ContainerStatuses...) {
+			if s.State.Waiting != nil && s.State.Waiting.Reason == "CrashLoopBackOff" {
+				crashing = append(crashing, p.Metadata.Namespace+"/"+p.Metadata.Name)
+				break
+			}
+		}
+	}
+	return crashing, nil
+}
+
+// healthGates checks the cluster health between the batches of an upgrade
+type healthGates struct {
+	gates  cluster.HealthGatesOption
+	leader *cluster.Host
+	hosts  cluster.Hosts
+}
+
+// check runs the health gates until they all pass or the gate timeout is reached
+func (g *healthGates) check(ctx context.Context) error {
+	return retry.Timeout(ctx, g.gates.Timeout, func(ctx context.Context) error {
+		var errs []error
+		if g.gates.NodesReady {
+			errs = append(errs, g.checkNodesReady())
+		}
+		for _, ns := range g.gates.CrashLoopNamespaces {
+			errs = append(errs, g.checkCrashLoops(ns))
+		}
+		for _, c := range g.gates.HTTP {
+			errs = append(errs, g.checkHTTP(ctx, c))
+		}
+		return errors.Join(errs...)
+	})
+}
+
+func (g *healthGates) checkNodesReady() error {
+	out, err := g.leader.Sudo().ExecOutput(g.leader.Configurer.KubectlCmdf(g.leader, g.leader.K0sDataDir(), "get nodes -o json"), cmd.HideOutput())
+	if err != nil {
+		return fmt.Errorf("get nodes: %w", err)
+	}
+	notReady, err := notReadyNodes(out)
+	if err != nil {
+		return err
+	}
+	if len(notReady) > 0 {
+		return fmt.Errorf("nodes not ready: %s", strings.Join(notReady, ", "))
+	}
+	log.Debugf("%s: health gate: all nodes are ready", g.leader)
+	return nil
+}
+
+func (g *healthGates) checkCrashLoops(namespace string) error {
+	out, err := g.leader.Sudo().ExecOutput(g.leader.Configurer.KubectlCmdf(g.leader, g.leader.K0sDataDir(), "-n %s get pods -o json", g.leader.FS().ShellQuote(namespace)), cmd.HideOutput())
+	if err != nil {
+		return fmt.Errorf("get pods in namespace %s: %w", namespace, err)
+	}
+	crashing, err := crashLoopingPods(out)
+	if err != nil {
+		return err
+	}
+	if len(crashing) > 0 {
+		return fmt.Errorf("pods in CrashLoopBackOff: %s", strings.Join(crashing, ", "))
+	}
+	log.Debugf("%s: health gate: no pods in CrashLoopBackOff in namespace %s", g.leader, namespace)
+	return nil
+}
+
+func (g *healthGates) checkHTTP(ctx context.Context, c cluster.HTTPHealthCheck) error {
+	h := g.leader
+	if c.Host != "" {
+		h = g.hosts.Find(func(h *cluster.Host) bool { return h.Address() == c.Host })
+		if h == nil {
+			return fmt.Errorf("http check %s: host %s not found", c.URL, c.Host)
+		}
+	}
+	if err := h.CheckHTTPStatus(ctx, c.URL, c.ExpectedStatus()...); err != nil {
+		return fmt.Errorf("http check %s from %s: %w", c.URL, h, err)
+	}
+	log.Debugf("%s: health gate: %s is healthy", h, c.URL)
+	return nil
+}
+
+// soak waits for the given duration unless the context is canceled
+func soak(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
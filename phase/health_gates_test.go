@@ -0,0 +1,65 @@
+package phase
+
+import (
+	"testing"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotReadyNodes(t *testing.T) {
+	out := `{"items": [
+		{"metadata": {"name": "worker-1"}, "status": {"conditions": [{"type": "MemoryPressure", "status": "False"}, {"type": "Ready", "status": "True"}]}},
+		{"metadata": {"name": "worker-2"}, "status": {"conditions": [{"type": "Ready", "status": "Unknown"}]}},
+		{"metadata": {"name": "worker-3"}, "status": {"conditions": []}}
+	]}`
+	notReady, err := notReadyNodes(out)
+	require.NoError(t, err)
+	require.Equal(t, []string{"worker-2", "worker-3"}, notReady)
+
+	_, err = notReadyNodes("not json")
+	require.Error(t, err)
+}
+
+func TestCrashLoopingPods(t *testing.T) {
+	out := `{"items": [
+		{"metadata": {"name": "coredns-1", "namespace": "kube-system"}, "status": {"containerStatuses": [{"name": "coredns", "state": {"running": {}}}]}},
+		{"metadata": {"name": "app-1", "namespace": "default"}, "status": {"containerStatuses": [
+			{"name": "app", "state": {"waiting": {"reason": "CrashLoopBackOff"}}},
+			{"name": "sidecar", "state": {"waiting": {"reason": "CrashLoopBackOff"}}}
+		]}},
+		{"metadata": {"name": "app-2", "namespace": "default"}, "status": {"initContainerStatuses": [{"name": "init", "state": {"waiting": {"reason": "CrashLoopBackOff"}}}]}},
+		{"metadata": {"name": "app-3", "namespace": "default"}, "status": {"containerStatuses": [{"name": "app", "state": {"waiting": {"reason": "ContainerCreating"}}}]}}
+	]}`
+	crashing, err := crashLoopingPods(out)
+	require.NoError(t, err)
+	require.Equal(t, []string{"default/app-1", "default/app-2"}, crashing)
+}
+
+func TestUpgradeWorkersBatches(t *testing.T) {
+	hosts := make(cluster.Hosts, 7)
+	for i := range hosts {
+		hosts[i] = &cluster.Host{Role: "worker"}
+	}
+	p := &UpgradeWorkers{hosts: hosts}
+	p.Config = &v1beta1.Cluster{Spec: &cluster.Spec{Options: cluster.Options{Concurrency: cluster.ConcurrencyOption{Limit: 30, WorkerDisruptionPercent: 50}}}}
+	groups := []cluster.Hosts{hosts}
+
+	batches := p.batches(groups, 0)
+	require.Len(t, batches, 3)
+	require.Equal(t, hosts[:3], batches[0])
+	require.Equal(t, hosts[6:], batches[2])
+
+	batches = p.batches(groups, 2)
+	require.Len(t, batches, 3)
+	require.Equal(t, hosts[:2], batches[0])
+	require.Equal(t, hosts[2:5], batches[1])
+	require.Equal(t, hosts[5:], batches[2])
+
+	batches = p.batches(groups, 7)
+	require.Len(t, batches, 1)
+
+	p.Config.Spec.Options.Concurrency.WorkerDisruptionPercent = 10
+	require.Len(t, p.batches(groups, 0), 7)
+}
@@ -232,6 +232,9 @@ func (p *InstallControllers) installK0s(ctx context.Context, h *cluster.Host) er
 		return err
 	}
 	log.Infof("%s: installing k0s controller", h)
+	if !p.IsWet() {
+		p.planInstall(h)
+	}
 
 	err = p.Wet(h, fmt.Sprintf("install k0s controller using `%s", strings.ReplaceAll(cmd, h.K0sInstallLocation(), "k0s")), func() error {
 		var stdout, stderr bytes.Buffer
@@ -232,6 +232,9 @@ func (p *InstallWorkers) Run(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
+		if !p.IsWet() {
+			p.planInstall(h)
+		}
 		err = p.Wet(h, fmt.Sprintf("install k0s worker with `%s`", strings.ReplaceAll(installCmd, h.K0sInstallLocation(), "k0s")), func() error {
 			sudo := h.Sudo()
 			if h.IsWindows() {
@@ -2,9 +2,14 @@ package phase
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,23 +19,121 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// LockLeaseDuration is the duration of a host lock lease. The lock holder renews the lease
+// periodically, a lease that has not been renewed within the duration is considered abandoned.
+var LockLeaseDuration = time.Minute
+
+// ErrLocked is returned when a host is locked by another k0sctl instance
+var ErrLocked = errors.New("host is locked by another k0sctl instance")
+
+// ErrLockLost is returned when renewing a lock lease finds that the lock has been broken or taken
+// over by another k0sctl instance
+var ErrLockLost = errors.New("the lock has been broken or taken over by another k0sctl instance")
+
+// LockInfo describes the owner of a host lock. It is stored as JSON in the lock file.
+type LockInfo struct {
+	ID       string    `json:"id"`
+	User     string    `json:"user"`
+	Hostname string    `json:"hostname"`
+	PID      int       `json:"pid"`
+	Command  string    `json:"command"`
+	Started  time.Time `json:"started"`
+	// Renewed is the time of the last lease renewal as reported by the locked host
+	Renewed time.Time `json:"renewed"`
+	// Lease is the lease duration in seconds
+	Lease int `json:"lease"`
+}
+
+// NewLockInfo returns a LockInfo describing the current process
+func NewLockInfo() *LockInfo {
+	hn, err := os.Hostname()
+	if err != nil {
+		hn = "unknown"
+	}
+	username := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	if username == "" {
+		username = "unknown"
+	}
+	command := filepath.Base(os.Args[0])
+	if len(os.Args) > 1 {
+		command += " " + strings.Join(os.Args[1:], " ")
+	}
+	return &LockInfo{
+		ID:       fmt.Sprintf("%s-%d", hn, os.Getpid()),
+		User:     username,
+		Hostname: hn,
+		PID:      os.Getpid(),
+		Command:  command,
+		Started:  time.Now(),
+		Lease:    int(LockLeaseDuration.Seconds()),
+	}
+}
+
+// Expires returns the time when the lease expires if it is not renewed
+func (l *LockInfo) Expires() time.Time {
+	return l.Renewed.Add(time.Duration(l.Lease) * time.Second)
+}
+
+// Expired returns true when the lease has not been renewed in time
+func (l *LockInfo) Expired(now time.Time) bool {
+	return now.After(l.Expires())
+}
+
+// String returns a description of the lock owner
+func (l *LockInfo) String() string {
+	return fmt.Sprintf("%s@%s (pid %d) running %q since %s", l.User, l.Hostname, l.PID, l.Command, l.Started.Format(time.RFC3339))
+}
+
+// ReadLockInfo reads the lock file from the host. Nil is returned when the host is not locked.
+func ReadLockInfo(h *cluster.Host) (*LockInfo, error) {
+	lfp := h.Configurer.K0sctlLockFilePath(h)
+	data, err := h.Sudo().FS().ReadFile(lfp)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || !h.Sudo().FS().FileExist(lfp) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read lock file %s: %w", lfp, err)
+	}
+
+	info := &LockInfo{}
+	if err := json.Unmarshal(data, info); err != nil {
+		// a lock file written by an older k0sctl version only contains the instance id,
+		// the lease is based on the file modification time.
+		stat, statErr := h.Sudo().FS().Stat(lfp)
+		if statErr != nil {
+			return nil, fmt.Errorf("lock file disappeared: %w", statErr)
+		}
+		info = &LockInfo{ID: strings.TrimSpace(string(data)), User: "unknown", Hostname: "unknown", Command: "k0sctl", Started: stat.ModTime(), Renewed: stat.ModTime(), Lease: 30}
+	}
+	return info, nil
+}
+
+// lockHostTime returns the current time on the host, falling back to the local time
+func lockHostTime(h *cluster.Host) time.Time {
+	now, err := h.Sudo().FS().SystemTime()
+	if err != nil {
+		log.Debugf("%s: failed to get system time, using local time for the lock lease: %s", h, err)
+		return time.Now()
+	}
+	return now
+}
+
 // Lock acquires an exclusive k0sctl lock on hosts
 type Lock struct {
 	GenericPhase
-	cfs        []func()
-	instanceID string
-	m          sync.Mutex
-	wg         sync.WaitGroup
+	cfs  []func()
+	info *LockInfo
+	m    sync.Mutex
+	wg   sync.WaitGroup
 }
 
 // Prepare the phase
 func (p *Lock) Prepare(c *v1beta1.Cluster) error {
 	p.Config = c
-	hn, err := os.Hostname()
-	if err != nil {
-		hn = "unknown"
-	}
-	p.instanceID = fmt.Sprintf("%s-%d", hn, os.Getpid())
+	p.info = NewLockInfo()
 	return nil
 }
 
@@ -46,6 +149,7 @@ func (p *Lock) Cancel() {
 	for _, f := range p.cfs {
 		f()
 	}
+	p.cfs = nil
 	p.wg.Wait()
 }
 
@@ -70,24 +174,29 @@ func (p *Lock) Run(ctx context.Context) error {
 func (p *Lock) startTicker(ctx context.Context, h *cluster.Host) error {
 	p.wg.Add(1)
 	lfp := h.Configurer.K0sctlLockFilePath(h)
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(time.Duration(p.info.Lease) * time.Second / 3)
 	ctx, cancel := context.WithCancel(ctx)
 	p.m.Lock()
 	p.cfs = append(p.cfs, cancel)
 	p.m.Unlock()
 
 	go func() {
-		log.Tracef("%s: started periodic update of lock file %s timestamp", h, lfp)
+		defer ticker.Stop()
+		log.Tracef("%s: started periodic renewal of lock lease %s", h, lfp)
 		for {
 			select {
 			case <-ticker.C:
-				if err := h.Sudo().FS().Touch(lfp, time.Now()); err != nil {
-					log.Debugf("%s: failed to touch lock file: %s", h, err)
+				if err := p.renew(h); err != nil {
+					if errors.Is(err, ErrLockLost) {
+						p.manager.Abort(fmt.Errorf("%s: %w", h, err))
+						continue
+					}
+					log.Warnf("%s: failed to renew lock lease: %s", h, err)
 				}
 			case <-ctx.Done():
 				log.Tracef("%s: stopped lock cycle, removing file", h)
-				if err := h.Sudo().FS().Remove(lfp); err != nil {
-					log.Debugf("%s: failed to remove host lock file, k0sctl may have been previously aborted or crashed. the start of next invocation may be delayed until it expires: %s", h, err)
+				if err := p.release(h); err != nil {
+					log.Debugf("%s: failed to remove host lock file, k0sctl may have been previously aborted or crashed. the start of next invocation may be delayed until the lease expires: %s", h, err)
 				}
 				p.wg.Done()
 				return
@@ -104,6 +213,44 @@ func (p *Lock) startLock(ctx context.Context, h *cluster.Host) error {
 	})
 }
 
+// renew extends the lease if the lock is still held by this instance
+func (p *Lock) renew(h *cluster.Host) error {
+	current, err := ReadLockInfo(h)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.ID != p.info.ID {
+		return ErrLockLost
+	}
+	return p.write(h)
+}
+
+// release removes the lock file if it is still held by this instance
+func (p *Lock) release(h *cluster.Host) error {
+	current, err := ReadLockInfo(h)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.ID != p.info.ID {
+		log.Debugf("%s: lock file is not held by this instance, not removing", h)
+		return nil
+	}
+	return h.Sudo().FS().Remove(h.Configurer.K0sctlLockFilePath(h))
+}
+
+func (p *Lock) write(h *cluster.Host) error {
+	info := *p.info
+	info.Renewed = lockHostTime(h)
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to encode lock info: %w", err)
+	}
+	if err := h.Sudo().FS().WriteFile(h.Configurer.K0sctlLockFilePath(h), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
 func (p *Lock) tryLock(h *cluster.Host) error {
 	lfp := h.Configurer.K0sctlLockFilePath(h)
 
@@ -112,27 +259,35 @@ func (p *Lock) tryLock(h *cluster.Host) error {
 		if !errors.Is(err, os.ErrExist) {
 			return fmt.Errorf("failed to create lock file %s: %w", lfp, err)
 		}
-		// File already exists — check if it belongs to us or is stale.
-		stat, statErr := h.Sudo().FS().Stat(lfp)
-		if statErr != nil {
-			return fmt.Errorf("lock file disappeared: %w", statErr)
-		}
-		data, readErr := h.Sudo().FS().ReadFile(lfp)
+		// File already exists — check if it belongs to us or is abandoned.
+		current, readErr := ReadLockInfo(h)
 		if readErr != nil {
-			return fmt.Errorf("failed to read lock file: %w", readErr)
+			return readErr
+		}
+		if current == nil {
+			return fmt.Errorf("lock file disappeared, will retry")
 		}
-		if string(data) == p.instanceID {
+		if current.ID == p.info.ID {
 			// We already hold the lock.
 			return nil
 		}
-		if time.Since(stat.ModTime()) < 30*time.Second {
-			return fmt.Errorf("another instance of k0sctl is currently operating on the host, delete %s or wait 30 seconds for it to expire", lfp)
+		if !current.Expired(lockHostTime(h)) {
+			return fmt.Errorf("%w: locked by %s, the lease expires at %s unless renewed. use `k0sctl lock status` to inspect and `k0sctl lock break --force` to remove a stale lock", ErrLocked, current, current.Expires().Format(time.RFC3339))
 		}
+		log.Warnf("%s: removing an expired lock held by %s", h, current)
 		_ = h.Sudo().FS().Remove(lfp)
 		return fmt.Errorf("removed existing expired lock file, will retry")
 	}
 
-	if _, writeErr := f.Write([]byte(p.instanceID)); writeErr != nil {
+	info := *p.info
+	info.Renewed = lockHostTime(h)
+	data, err := json.Marshal(info)
+	if err != nil {
+		_ = f.Close()
+		_ = h.Sudo().FS().Remove(lfp)
+		return fmt.Errorf("failed to encode lock info: %w", err)
+	}
+	if _, writeErr := f.Write(data); writeErr != nil {
 		_ = f.Close()
 		_ = h.Sudo().FS().Remove(lfp)
 		return fmt.Errorf("failed to write lock file: %w", writeErr)
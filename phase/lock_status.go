@@ -0,0 +1,59 @@
+package phase
+
+import (
+	"context"
+	"sync"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+)
+
+// HostLock is the lock state of a host
+type HostLock struct {
+	Host *cluster.Host
+	// Info is nil when the host is not locked
+	Info *LockInfo
+	// Expired is true when the lock lease has not been renewed in time
+	Expired bool
+}
+
+// LockStatus reads the k0sctl lock state of the hosts
+type LockStatus struct {
+	GenericPhase
+
+	// Locks is populated with the lock state of each host in the order of the configuration
+	Locks []HostLock
+
+	mu sync.Mutex
+}
+
+// Title for the phase
+func (p *LockStatus) Title() string {
+	return "Read host lock status"
+}
+
+// Run the phase
+func (p *LockStatus) Run(ctx context.Context) error {
+	locks := make(map[*cluster.Host]HostLock, len(p.Config.Spec.Hosts))
+	err := p.parallelDo(ctx, p.Config.Spec.Hosts, func(_ context.Context, h *cluster.Host) error {
+		info, err := ReadLockInfo(h)
+		if err != nil {
+			return err
+		}
+		lock := HostLock{Host: h, Info: info}
+		if info != nil {
+			lock.Expired = info.Expired(lockHostTime(h))
+		}
+		p.mu.Lock()
+		locks[h] = lock
+		p.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, h := range p.Config.Spec.Hosts {
+		p.Locks = append(p.Locks, locks[h])
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package phase
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockInfoLease(t *testing.T) {
+	info := NewLockInfo()
+	require.Equal(t, os.Getpid(), info.PID)
+	require.NotEmpty(t, info.User)
+	require.NotEmpty(t, info.Command)
+	require.Equal(t, int(LockLeaseDuration.Seconds()), info.Lease)
+
+	now := time.Now()
+	info.Renewed = now
+	require.Equal(t, now.Add(LockLeaseDuration), info.Expires())
+	require.False(t, info.Expired(now.Add(LockLeaseDuration/2)))
+	require.True(t, info.Expired(now.Add(LockLeaseDuration+time.Second)))
+}
+
+func TestLockInfoJSON(t *testing.T) {
+	info := NewLockInfo()
+	info.Renewed = time.Now()
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+
+	decoded := &LockInfo{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	require.Equal(t, info.ID, decoded.ID)
+	require.Equal(t, info.Command, decoded.Command)
+	require.True(t, info.Renewed.Equal(decoded.Renewed))
+	require.Contains(t, decoded.String(), info.User+"@"+info.Hostname)
+}
@@ -2,13 +2,16 @@ package phase
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/creasty/defaults"
 	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/secret"
 	"github.com/logrusorgru/aurora"
 	log "github.com/sirupsen/logrus"
 )
@@ -116,9 +119,19 @@ type Manager struct {
 	ConcurrentUploads int
 	DryRun            bool
 	Writer            io.Writer
+	// Checkpoint, when set, is used to record the progress of the run and to skip already completed work
+	Checkpoint *Checkpoint
 
 	dryMessages map[string][]string
 	dryMu       sync.Mutex
+	planActions []PlanAction
+	planFacts   map[string]HostFacts
+	configHash  string
+
+	observers    []Observer
+	currentPhase string
+	phaseMu      sync.Mutex
+	abort        context.CancelCauseFunc
 }
 
 // NewManager creates a new Manager
@@ -142,20 +155,35 @@ func (m *Manager) SetPhases(p Phases) {
 
 type errorfunc func() error
 
+// HostDone returns true when the checkpoint says the host has already completed its part of the phase
+func (m *Manager) HostDone(title string, host fmt.Stringer) bool {
+	if m == nil || m.Checkpoint == nil || m.DryRun {
+		return false
+	}
+	return m.Checkpoint.HostDone(title, host)
+}
+
+// MarkHostDone records the host as completed for the phase in the checkpoint
+func (m *Manager) MarkHostDone(title string, host fmt.Stringer) {
+	if m == nil || m.Checkpoint == nil || m.DryRun {
+		return
+	}
+	if err := m.Checkpoint.MarkHost(title, host); err != nil {
+		log.Warnf("%s: failed to update checkpoint: %s", host, err.Error())
+	}
+}
+
 // DryMsg prints a message in dry-run mode
 func (m *Manager) DryMsg(host fmt.Stringer, msg string) {
+	key := hostKey(host)
 	m.dryMu.Lock()
-	defer m.dryMu.Unlock()
 	if m.dryMessages == nil {
 		m.dryMessages = make(map[string][]string)
 	}
-	var key string
-	if host == nil {
-		key = "local"
-	} else {
-		key = host.String()
-	}
 	m.dryMessages[key] = append(m.dryMessages[key], msg)
+	m.dryMu.Unlock()
+
+	m.Emit(Event{Type: EventDryRun, Host: key, Message: msg})
 }
 
 // Wet runs the first given function when not in dry-run mode. The second function will be
@@ -178,11 +206,21 @@ func (m *Manager) Wet(host fmt.Stringer, msg string, funcs ...errorfunc) error {
 	return nil
 }
 
-// Run executes all the added Phases in order
-func (m *Manager) Run(ctx context.Context) error {
-	var ran []Phase
-	var result error
+// SaveCheckpoint writes the progress recorded so far to the checkpoint state file. It is called when
+// a run fails and it can be called from another goroutine when the process is about to exit.
+func (m *Manager) SaveCheckpoint() {
+	if m == nil || m.Checkpoint == nil || m.DryRun {
+		return
+	}
+	if err := m.Checkpoint.Save(); err != nil {
+		log.Warnf("failed to save checkpoint: %s", err.Error())
+		return
+	}
+	log.Infof("progress saved to %s, the apply can be continued with --resume", m.Checkpoint.Path())
+}
 
+// Run executes all the added Phases in order
+func (m *Manager) Run(ctx context.Context) (result error) {
 	if m.Config == nil {
 		return fmt.Errorf("cannot run phases: config is nil")
 	}
@@ -192,16 +230,23 @@ func (m *Manager) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to set defaults: %w", err)
 	}
 	log.Debug("final configuration:")
-	log.Debug(m.Config.String())
+	log.Debug(secret.Redact(m.Config.String()))
+
+	m.configHash = configHash(m.Config)
+
+	if m.Checkpoint != nil && !m.DryRun {
+		if err := m.Checkpoint.Bind(m.configHash); err != nil {
+			return fmt.Errorf("can not resume from %s: %w", m.Checkpoint.Path(), err)
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	m.abort = cancel
 
 	defer func() {
 		if result != nil {
-			for _, p := range ran {
-				if c, ok := p.(withcleanup); ok {
-					log.Infof(Colorize.Red("* Running clean-up for phase: %s").String(), p.Title())
-					c.CleanUp()
-				}
-			}
+			m.SaveCheckpoint()
 		}
 		if m.DryRun {
 			if len(m.dryMessages) == 0 {
@@ -219,39 +264,89 @@ func (m *Manager) Run(ctx context.Context) error {
 		}
 	}()
 
-	for _, p := range m.phases {
+	if err := m.runPhases(ctx, m.phases); err != nil {
+		return err
+	}
+
+	if m.Checkpoint != nil && !m.DryRun {
+		if err := m.Checkpoint.Remove(); err != nil {
+			log.Warnf("failed to remove checkpoint: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// setCurrentPhase sets the title of the running phase and returns the previous one. The title
+// is guarded because the events emitted from the per-host goroutines read it.
+func (m *Manager) setCurrentPhase(title string) string {
+	m.phaseMu.Lock()
+	defer m.phaseMu.Unlock()
+	previous := m.currentPhase
+	m.currentPhase = title
+	return previous
+}
+
+// runPhases runs the given phases in order and runs the clean-up of the phases that ran when
+// one of them fails. It is also used by phases that run phases of their own, such as UpgradePath.
+func (m *Manager) runPhases(ctx context.Context, phases Phases) (result error) {
+	var ran []Phase
+
+	previousPhase := m.setCurrentPhase("")
+	defer func() {
+		m.setCurrentPhase(previousPhase)
+		if result != nil {
+			for _, p := range ran {
+				if c, ok := p.(withcleanup); ok {
+					log.Infof(Colorize.Red("* Running clean-up for phase: %s").String(), p.Title())
+					c.CleanUp()
+				}
+			}
+		}
+	}()
+
+	for _, p := range phases {
 		title := p.Title()
+		m.setCurrentPhase(title)
 
 		if err := ctx.Err(); err != nil {
-			result = fmt.Errorf("context canceled before entering phase %q: %w", title, err)
-			return result
+			return fmt.Errorf("context canceled before entering phase %q: %w", title, context.Cause(ctx))
 		}
 
 		if p, ok := p.(withmanager); ok {
 			p.SetManager(m)
 		}
 
+		if rp, ok := p.(resumable); ok && rp.Resumable() && m.Checkpoint != nil && !m.DryRun && m.Checkpoint.PhaseDone(title) {
+			log.Infof(Colorize.Green("==> Skipping phase: %s (completed in a previous run)").String(), title)
+			m.Emit(Event{Type: EventPhaseSkipped, Message: "completed in a previous run"})
+			continue
+		}
+
 		if p, ok := p.(withconfig); ok {
 			log.Debugf("Preparing phase '%s'", p.Title())
 			if err := p.Prepare(m.Config); err != nil {
-				result = err
-				return result
+				return err
 			}
 		}
 
 		if p, ok := p.(conditional); ok {
 			if !p.ShouldRun() {
+				m.Emit(Event{Type: EventPhaseSkipped, Message: "nothing to do"})
 				continue
 			}
 		}
 
+		start := time.Now()
+		m.Emit(Event{Type: EventPhaseStarted})
+
 		// Run in-phase before hook if implemented.
 		if bp, ok := p.(withBefore); ok {
 			log.Debugf("running before for phase '%s'", p.Title())
 			if err := bp.Before(); err != nil {
 				log.Debugf("before failed '%s'", err.Error())
-				result = err
-				return result
+				m.emitPhaseFinished(start, err)
+				return err
 			}
 		}
 
@@ -260,32 +355,68 @@ func (m *Manager) Run(ctx context.Context) error {
 
 		if dp, ok := p.(withDryRun); ok && m.DryRun {
 			ran = append(ran, p)
-			if err := dp.DryRun(); err != nil {
-				result = err
-				return result
+			err := dp.DryRun()
+			m.emitPhaseFinished(start, err)
+			if err != nil {
+				return err
 			}
 			continue
 		}
 
-		result = p.Run(ctx)
+		err := p.Run(ctx)
 		ran = append(ran, p)
 
 		// Only run in-phase After hook if Run() succeeded.
 		// If After() fails after a successful Run(), return the After() error.
-		if result == nil {
+		if err == nil {
 			if ap, ok := p.(withAfter); ok {
 				log.Debugf("running after for phase '%s'", p.Title())
 				if herr := ap.After(); herr != nil {
-					result = herr
-					return result
+					err = herr
 				}
 			}
 		}
 
-		if result != nil {
-			return result
+		// a run that was aborted while the phase was running fails even if the phase itself succeeded
+		if cause := context.Cause(ctx); cause != nil {
+			switch {
+			case err == nil:
+				err = cause
+			case !errors.Is(err, cause):
+				err = fmt.Errorf("%w: %w", cause, err)
+			}
+		}
+
+		m.emitPhaseFinished(start, err)
+
+		if err != nil {
+			return err
+		}
+
+		if m.Checkpoint != nil && !m.DryRun {
+			if err := m.Checkpoint.MarkPhase(title); err != nil {
+				log.Warnf("failed to update checkpoint: %s", err.Error())
+			}
 		}
 	}
 
 	return nil
 }
+
+// Abort stops the run because of the error. The context of the running phase is canceled and no
+// further phases are run. It can be called from the background tasks of the phases.
+func (m *Manager) Abort(err error) {
+	if m == nil || m.abort == nil {
+		return
+	}
+	log.Errorf("aborting: %s", err)
+	m.abort(err)
+}
+
+func (m *Manager) emitPhaseFinished(start time.Time, err error) {
+	e := Event{Type: EventPhaseFinished, Duration: time.Since(start).Seconds()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	m.Emit(e)
+}
@@ -159,3 +159,62 @@ func TestContextCancel(t *testing.T) {
 	require.False(t, p2.runCalled, "2nd run was called")
 	require.False(t, p2.cleanupCalled, "2nd cleanup was called")
 }
+
+type nestingPhase struct {
+	GenericPhase
+	phases Phases
+	phase  string
+}
+
+func (p *nestingPhase) Title() string {
+	return "nesting phase"
+}
+
+func (p *nestingPhase) Run(ctx context.Context) error {
+	err := p.manager.runPhases(ctx, p.phases)
+	p.phase = p.manager.currentPhase
+	return err
+}
+
+func TestNestedPhases(t *testing.T) {
+	m := Manager{Config: &v1beta1.Cluster{Spec: &cluster.Spec{}}}
+	inner1 := &hookedPhase{fn: func() error { return nil }}
+	inner2 := &hookedPhase{}
+	outer := &nestingPhase{phases: Phases{inner1, inner2}}
+	m.AddPhase(outer)
+	require.ErrorContains(t, m.Run(context.Background()), "run failed")
+
+	require.Equal(t, "nesting phase", outer.phase)
+	require.True(t, inner1.runCalled, "1st inner run was not called")
+	require.True(t, inner1.cleanupCalled, "1st inner cleanup was not called")
+	require.True(t, inner2.cleanupCalled, "2nd inner cleanup was not called")
+}
+
+type abortingPhase struct {
+	GenericPhase
+	err error
+}
+
+func (p *abortingPhase) Title() string {
+	return "aborting phase"
+}
+
+func (p *abortingPhase) Run(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.manager.Abort(p.err)
+	}()
+	<-done
+	<-ctx.Done()
+	return nil
+}
+
+func TestAbort(t *testing.T) {
+	m := Manager{Config: &v1beta1.Cluster{Spec: &cluster.Spec{}}}
+	lost := fmt.Errorf("host: %w", ErrLockLost)
+	next := &hookedPhase{}
+	m.AddPhase(&abortingPhase{err: lost}, next)
+	require.ErrorIs(t, m.Run(context.Background()), ErrLockLost)
+	require.False(t, next.runCalled, "the phase after the abort was run")
+}
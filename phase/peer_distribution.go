@@ -0,0 +1,288 @@
+package phase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	k0s "github.com/k0sproject/k0sctl/pkg/k0s"
+	"github.com/k0sproject/k0sctl/pkg/retry"
+	log "github.com/sirupsen/logrus"
+)
+
+// peerServer is a short-lived http server on a seed host that serves the staged k0s binary
+type peerServer struct {
+	host *cluster.Host
+	dir  string
+	pid  string
+	url  string
+}
+
+// peerGroup is a set of hosts that need the same locally cached binary
+type peerGroup struct {
+	key   string
+	hosts cluster.Hosts
+	seed  *cluster.Host
+}
+
+// distributePeer stages the binary on the upload hosts that share the same locally cached
+// binary by uploading it to a single seed host and letting the rest of the hosts download it
+// from an http server started on the seed. It returns the hosts that still need a direct upload.
+func (p *StageBinaries) distributePeer(ctx context.Context, hosts cluster.Hosts) (cluster.Hosts, error) {
+	var direct cluster.Hosts
+	var groups []*peerGroup
+	byKey := make(map[string]*peerGroup)
+	for _, h := range hosts {
+		key, ok := p.peerKey(h)
+		if !ok {
+			direct = append(direct, h)
+			continue
+		}
+		g, ok := byKey[key]
+		if !ok {
+			g = &peerGroup{key: key}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.hosts = append(g.hosts, h)
+	}
+
+	seedFunc, serveFunc := canSeed, startPeerServer
+	if p.peerSeed != nil {
+		seedFunc = p.peerSeed
+	}
+	if p.peerServe != nil {
+		serveFunc = p.peerServe
+	}
+
+	var seeds cluster.Hosts
+	for _, g := range groups {
+		if len(g.hosts) > 1 {
+			g.seed = g.hosts.Find(seedFunc)
+		}
+		if g.seed == nil {
+			log.Debugf("no seed host for peer-to-peer distribution of %s, uploading directly", g.key)
+			direct = append(direct, g.hosts...)
+			continue
+		}
+		seeds = append(seeds, g.seed)
+	}
+	if len(seeds) == 0 {
+		return direct, nil
+	}
+
+	// Serving the binary from the seeds would open ports on the hosts, in dry-run mode
+	// every host gets the binary uploaded directly instead.
+	if !p.IsWet() {
+		for _, g := range groups {
+			if g.seed == nil {
+				continue
+			}
+			for _, h := range g.hosts {
+				if h != g.seed {
+					p.DryMsgf(h, "distribute the k0s binary via seed %s", g.seed)
+				}
+			}
+		}
+		return hosts, nil
+	}
+
+	if err := p.parallelDoUpload(ctx, seeds, p.stageForHost); err != nil {
+		return nil, err
+	}
+
+	var servers []*peerServer
+	defer func() {
+		for _, s := range servers {
+			s.stop()
+		}
+	}()
+
+	urls := make(map[*cluster.Host]string)
+	var peers cluster.Hosts
+	for _, g := range groups {
+		if g.seed == nil {
+			continue
+		}
+		others := g.hosts.Filter(func(h *cluster.Host) bool { return h != g.seed })
+		server, err := serveFunc(ctx, g.seed, p.Config.Spec.Options.BinaryDistribution.PortValue())
+		if err != nil {
+			log.Warnf("%s: failed to start the peer-to-peer distribution server, uploading to %d hosts directly: %s", g.seed, len(others), err)
+			direct = append(direct, others...)
+			continue
+		}
+		servers = append(servers, server)
+		log.Infof("%s: serving the k0s binary to %d hosts", g.seed, len(others))
+		for _, h := range others {
+			urls[h] = server.url
+		}
+		peers = append(peers, others...)
+	}
+
+	var mu sync.Mutex
+	err := p.parallelDo(ctx, peers, func(ctx context.Context, h *cluster.Host) error {
+		if err := p.stageFromPeer(ctx, h, urls[h]); err != nil {
+			log.Warnf("%s: failed to get the k0s binary from a peer, uploading it directly: %s", h, err)
+			mu.Lock()
+			direct = append(direct, h)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return direct, nil
+}
+
+// peerKey returns the binary cache key of the host when its binary can be distributed host-to-host
+func (p *StageBinaries) peerKey(h *cluster.Host) (string, bool) {
+	provider, err := h.K0sBinaryProvider(p.Config.Spec.K0s.Version)
+	if err != nil {
+		return "", false
+	}
+	bc, ok := provider.(k0s.BinaryCacher)
+	if !ok {
+		return "", false
+	}
+	if _, ok := provider.(k0s.PeerStager); !ok {
+		return "", false
+	}
+	if h.IsWindows() {
+		return "", false
+	}
+	key, err := bc.BinaryCacheKey()
+	if err != nil || key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+func (p *StageBinaries) stageFromPeer(ctx context.Context, h *cluster.Host, url string) error {
+	provider, err := h.K0sBinaryProvider(p.Config.Spec.K0s.Version)
+	if err != nil {
+		return err
+	}
+	stager, ok := provider.(k0s.PeerStager)
+	if !ok {
+		return fmt.Errorf("%T does not support peer-to-peer distribution", provider)
+	}
+	log.Debugf("%s: downloading the k0s binary from %s", h, url)
+	tmp, err := stager.StageFromPeer(ctx, url)
+	if err != nil {
+		return err
+	}
+	log.Debugf("%s: staged k0s binary to %s", h, tmp)
+	h.Metadata.K0sBinaryTempFile = tmp
+	return nil
+}
+
+// canSeed returns true when the host has a tool to run a static http server
+func canSeed(h *cluster.Host) bool {
+	return !h.IsWindows() && peerServerCommand(h) != ""
+}
+
+// peerServerCommand returns the command to serve the current directory over http or an
+// empty string when neither python3 nor busybox is available on the host. python3 lists the
+// contents of a directory that has no index.html, busybox httpd does not list directories.
+func peerServerCommand(h *cluster.Host) string {
+	switch {
+	case h.FS().CommandExist("python3"):
+		return "python3 -m http.server --bind %[1]s %[2]d"
+	case h.FS().CommandExist("busybox"):
+		return "busybox httpd -f -p %[1]s:%[2]d -h ."
+	default:
+		return ""
+	}
+}
+
+// peerAddress returns the address other hosts use to reach the host
+func peerAddress(h *cluster.Host) string {
+	if h.PrivateAddress != "" {
+		return h.PrivateAddress
+	}
+	return h.Address()
+}
+
+// startPeerServer serves the binary staged on the seed host from a temporary directory. The
+// binary is linked into a subdirectory with a random name and the root of the server has an
+// empty index.html, so that the server does not list the subdirectory and the binary can not
+// be fetched without knowing the url.
+func startPeerServer(ctx context.Context, h *cluster.Host, port int) (*peerServer, error) {
+	if h.Metadata.K0sBinaryTempFile == "" {
+		return nil, fmt.Errorf("no staged binary")
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("generate directory name: %w", err)
+	}
+	name := hex.EncodeToString(token)
+
+	dir, err := h.ExecOutput("mktemp -d")
+	if err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+	s := &peerServer{host: h, dir: strings.TrimSpace(dir)}
+
+	fs := h.FS()
+	sub := path.Join(s.dir, name)
+	link := fmt.Sprintf("mkdir %[1]s && : > %[2]s && : > %[3]s && ln -s %[4]s %[5]s",
+		fs.ShellQuote(sub),
+		fs.ShellQuote(path.Join(s.dir, "index.html")),
+		fs.ShellQuote(path.Join(sub, "index.html")),
+		fs.ShellQuote(h.Metadata.K0sBinaryTempFile),
+		fs.ShellQuote(path.Join(sub, "k0s")),
+	)
+	if err := h.Exec(link); err != nil {
+		s.stop()
+		return nil, fmt.Errorf("link the staged binary: %w", err)
+	}
+
+	addr := peerAddress(h)
+	server := fmt.Sprintf(peerServerCommand(h), fs.ShellQuote(addr), port)
+	pid, err := h.ExecOutput(fmt.Sprintf("cd %s && (nohup %s >/dev/null 2>&1 & echo $!)", fs.ShellQuote(s.dir), server))
+	if err != nil {
+		s.stop()
+		return nil, fmt.Errorf("start http server: %w", err)
+	}
+	s.pid = strings.TrimSpace(pid)
+	if _, err := strconv.Atoi(s.pid); err != nil {
+		s.pid = ""
+		s.stop()
+		return nil, fmt.Errorf("start http server: unexpected output %q", pid)
+	}
+	s.url = "http://" + net.JoinHostPort(addr, strconv.Itoa(port)) + "/" + name + "/k0s"
+
+	err = retry.Times(ctx, 5, func(_ context.Context) error {
+		return h.Exec(fmt.Sprintf("curl -sSf -o /dev/null -r 0-0 %s", fs.ShellQuote(s.url)))
+	})
+	if err != nil {
+		s.stop()
+		return nil, fmt.Errorf("http server did not become ready: %w", err)
+	}
+	return s, nil
+}
+
+// stop stops the http server and removes the temporary directory, the staged binary is left in place
+func (s *peerServer) stop() {
+	if s.pid != "" {
+		if err := s.host.Exec("kill " + s.pid); err != nil {
+			log.Debugf("%s: failed to stop the peer-to-peer distribution server: %s", s.host, err)
+		}
+		s.pid = ""
+	}
+	if s.dir != "" {
+		if err := s.host.Exec("rm -rf " + s.host.FS().ShellQuote(s.dir)); err != nil {
+			log.Debugf("%s: failed to remove %s: %s", s.host, s.dir, err)
+		}
+		s.dir = ""
+	}
+}
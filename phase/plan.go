@@ -0,0 +1,218 @@
+package phase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+)
+
+// PlanFormatVersion is the version of the plan file format
+const PlanFormatVersion = 1
+
+// ErrPlanMismatch is returned when the gathered facts no longer match the plan
+var ErrPlanMismatch = errors.New("cluster state does not match the plan")
+
+// Plan action types
+const (
+	PlanActionInstall   = "install"
+	PlanActionUpgrade   = "upgrade"
+	PlanActionReinstall = "reinstall"
+	PlanActionConfigure = "configure"
+	PlanActionUpload    = "upload"
+	PlanActionReset     = "reset"
+)
+
+// PlanAction is a structured description of a single cluster state altering action
+type PlanAction struct {
+	Host    string `json:"host"`
+	Action  string `json:"action"`
+	Target  string `json:"target,omitempty"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// String returns a human readable description of the action
+func (a PlanAction) String() string {
+	if a.Message != "" {
+		return a.Message
+	}
+	var sb strings.Builder
+	sb.WriteString(a.Action)
+	if a.Target != "" {
+		sb.WriteString(" ")
+		sb.WriteString(a.Target)
+	}
+	switch {
+	case a.Old != "" && a.New != "":
+		fmt.Fprintf(&sb, " (%s => %s)", a.Old, a.New)
+	case a.New != "":
+		fmt.Fprintf(&sb, " (%s)", a.New)
+	}
+	return sb.String()
+}
+
+// HostFacts is a snapshot of the facts gathered from a host that a plan was computed from
+type HostFacts struct {
+	Role              string `json:"role"`
+	Hostname          string `json:"hostname,omitempty"`
+	Arch              string `json:"arch,omitempty"`
+	K0sRunningVersion string `json:"k0sRunningVersion,omitempty"`
+	K0sBinaryVersion  string `json:"k0sBinaryVersion,omitempty"`
+	NeedsUpgrade      bool   `json:"needsUpgrade"`
+	Reset             bool   `json:"reset"`
+}
+
+// NewHostFacts returns a snapshot of the facts gathered from the host
+func NewHostFacts(h *cluster.Host) HostFacts {
+	return HostFacts{
+		Role:              h.Role,
+		Hostname:          h.Metadata.Hostname,
+		Arch:              h.Metadata.Arch,
+		K0sRunningVersion: h.Metadata.K0sRunningVersion.String(),
+		K0sBinaryVersion:  h.Metadata.K0sBinaryVersion.String(),
+		NeedsUpgrade:      h.Metadata.NeedsUpgrade,
+		Reset:             h.Reset,
+	}
+}
+
+// Plan is a machine-readable description of the actions an apply would perform
+type Plan struct {
+	FormatVersion int                  `json:"formatVersion"`
+	Created       time.Time            `json:"created"`
+	ConfigHash    string               `json:"configHash"`
+	K0sVersion    string               `json:"k0sVersion"`
+	Hosts         map[string]HostFacts `json:"hosts"`
+	Actions       []PlanAction         `json:"actions"`
+}
+
+// ReadPlan decodes a plan from the reader
+func ReadPlan(r io.Reader) (*Plan, error) {
+	plan := &Plan{}
+	if err := json.NewDecoder(r).Decode(plan); err != nil {
+		return nil, fmt.Errorf("decode plan: %w", err)
+	}
+	if plan.FormatVersion != PlanFormatVersion {
+		return nil, fmt.Errorf("unsupported plan format version %d (expected %d)", plan.FormatVersion, PlanFormatVersion)
+	}
+	return plan, nil
+}
+
+// ReadPlanFile decodes a plan from the file at the given path
+func ReadPlanFile(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read plan: %w", err)
+	}
+	return ReadPlan(bytes.NewReader(data))
+}
+
+// Write encodes the plan as JSON into the writer
+func (p *Plan) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		return fmt.Errorf("encode plan: %w", err)
+	}
+	return nil
+}
+
+// Verify compares the plan to the given configuration and the facts gathered from its hosts.
+// An error wrapping ErrPlanMismatch listing the differences is returned if they don't match.
+func (p *Plan) Verify(configHash string, config *v1beta1.Cluster) error {
+	var diffs []string
+	if p.ConfigHash != configHash {
+		diffs = append(diffs, "the configuration has changed")
+	}
+	if v := config.Spec.K0s.Version.String(); p.K0sVersion != v {
+		diffs = append(diffs, fmt.Sprintf("k0s version has changed from %s to %s", p.K0sVersion, v))
+	}
+
+	seen := make(map[string]struct{}, len(config.Spec.Hosts))
+	for _, h := range config.Spec.Hosts {
+		seen[h.String()] = struct{}{}
+		planned, ok := p.Hosts[h.String()]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: host is not in the plan", h))
+			continue
+		}
+		if current := NewHostFacts(h); current != planned {
+			diffs = append(diffs, fmt.Sprintf("%s: facts have changed: planned %+v, current %+v", h, planned, current))
+		}
+	}
+	for addr := range p.Hosts {
+		if _, ok := seen[addr]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: host is no longer in the configuration", addr))
+		}
+	}
+
+	if len(diffs) == 0 {
+		return nil
+	}
+	sort.Strings(diffs)
+	return fmt.Errorf("%w:\n  - %s", ErrPlanMismatch, strings.Join(diffs, "\n  - "))
+}
+
+// Plan returns the plan collected during a dry-run
+func (m *Manager) Plan() *Plan {
+	m.dryMu.Lock()
+	defer m.dryMu.Unlock()
+
+	plan := &Plan{
+		FormatVersion: PlanFormatVersion,
+		Created:       time.Now().UTC(),
+		ConfigHash:    m.configHash,
+		K0sVersion:    m.Config.Spec.K0s.Version.String(),
+		Hosts:         m.planFacts,
+		Actions:       slices.Clone(m.planActions),
+	}
+	if plan.Hosts == nil {
+		plan.Hosts = make(map[string]HostFacts)
+	}
+	// hosts are processed in parallel, sort for a reproducible output while keeping the order of actions within a host
+	sort.SliceStable(plan.Actions, func(i, j int) bool {
+		return plan.Actions[i].Host < plan.Actions[j].Host
+	})
+	return plan
+}
+
+// PlanChange records a structured action into the plan
+func (m *Manager) PlanChange(host fmt.Stringer, action PlanAction) {
+	m.dryMu.Lock()
+	defer m.dryMu.Unlock()
+	action.Host = hostKey(host)
+	m.planActions = append(m.planActions, action)
+}
+
+func hostKey(host fmt.Stringer) string {
+	if host == nil {
+		return "local"
+	}
+	return host.String()
+}
+
+// contentChecksum returns a "sha256:<hex>" checksum of the data or an empty string when there is no data
+func contentChecksum(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func configHash(config fmt.Stringer) string {
+	sum := sha256.Sum256([]byte(config.String()))
+	return hex.EncodeToString(sum[:])
+}
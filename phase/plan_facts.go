@@ -0,0 +1,37 @@
+package phase
+
+import (
+	"context"
+)
+
+// PlanFacts records the gathered host facts for a plan or, when Verify is set, makes sure
+// the gathered facts still match the ones the given plan was computed from.
+type PlanFacts struct {
+	GenericPhase
+
+	Verify *Plan
+}
+
+// Title for the phase
+func (p *PlanFacts) Title() string {
+	if p.Verify != nil {
+		return "Verify plan"
+	}
+	return "Record facts for plan"
+}
+
+// Run the phase
+func (p *PlanFacts) Run(_ context.Context) error {
+	if p.Verify != nil {
+		return p.Verify.Verify(p.manager.configHash, p.Config)
+	}
+
+	facts := make(map[string]HostFacts, len(p.Config.Spec.Hosts))
+	for _, h := range p.Config.Spec.Hosts {
+		facts[h.String()] = NewHostFacts(h)
+	}
+	p.manager.dryMu.Lock()
+	p.manager.planFacts = facts
+	p.manager.dryMu.Unlock()
+	return nil
+}
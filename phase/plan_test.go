@@ -0,0 +1,75 @@
+package phase
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/version"
+	"github.com/stretchr/testify/require"
+)
+
+func planTestConfig() *v1beta1.Cluster {
+	h := &cluster.Host{Role: "worker"}
+	h.Metadata.Hostname = "worker1"
+	h.Metadata.K0sRunningVersion = version.MustParse("v1.33.1+k0s.0")
+	h.Metadata.NeedsUpgrade = true
+	return &v1beta1.Cluster{
+		Spec: &cluster.Spec{
+			Hosts: cluster.Hosts{h},
+			K0s:   &cluster.K0s{Version: version.MustParse("v1.34.1+k0s.0")},
+		},
+	}
+}
+
+func TestManagerPlan(t *testing.T) {
+	config := planTestConfig()
+	h := config.Spec.Hosts[0]
+	m := Manager{Config: config, DryRun: true, Writer: &bytes.Buffer{}}
+	m.AddPhase(&PlanFacts{})
+	require.NoError(t, m.Run(context.Background()))
+
+	m.PlanChange(h, PlanAction{Action: PlanActionUpgrade, Target: "k0s", Old: "v1.33.1+k0s.0", New: "v1.34.1+k0s.0"})
+	m.DryMsg(h, "drain node")
+
+	plan := m.Plan()
+	require.Equal(t, PlanFormatVersion, plan.FormatVersion)
+	require.Equal(t, "v1.34.1+k0s.0", plan.K0sVersion)
+	require.NotEmpty(t, plan.ConfigHash)
+	require.Equal(t, NewHostFacts(h), plan.Hosts[h.String()])
+	require.Len(t, plan.Actions, 1, "free-form dry-run messages are not recorded in the plan")
+	require.Equal(t, "upgrade k0s (v1.33.1+k0s.0 => v1.34.1+k0s.0)", plan.Actions[0].String())
+
+	var buf bytes.Buffer
+	require.NoError(t, plan.Write(&buf))
+	decoded, err := ReadPlan(&buf)
+	require.NoError(t, err)
+	require.Equal(t, plan.Actions, decoded.Actions)
+	require.Equal(t, plan.Hosts, decoded.Hosts)
+
+	require.NoError(t, decoded.Verify(plan.ConfigHash, config))
+}
+
+func TestPlanVerifyMismatch(t *testing.T) {
+	config := planTestConfig()
+	h := config.Spec.Hosts[0]
+	plan := &Plan{
+		FormatVersion: PlanFormatVersion,
+		ConfigHash:    "abc",
+		K0sVersion:    config.Spec.K0s.Version.String(),
+		Hosts:         map[string]HostFacts{h.String(): NewHostFacts(h)},
+	}
+	require.NoError(t, plan.Verify("abc", config))
+
+	err := plan.Verify("def", config)
+	require.ErrorIs(t, err, ErrPlanMismatch)
+	require.Contains(t, err.Error(), "configuration has changed")
+
+	h.Metadata.K0sRunningVersion = version.MustParse("v1.34.1+k0s.0")
+	h.Metadata.NeedsUpgrade = false
+	err = plan.Verify("abc", config)
+	require.ErrorIs(t, err, ErrPlanMismatch)
+	require.Contains(t, err.Error(), "facts have changed")
+}
@@ -0,0 +1,144 @@
+package phase
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	log "github.com/sirupsen/logrus"
+)
+
+// PreflightSeverity is the outcome of a preflight check
+type PreflightSeverity string
+
+// Preflight check severities
+const (
+	PreflightOK    PreflightSeverity = "ok"
+	PreflightWarn  PreflightSeverity = "warn"
+	PreflightError PreflightSeverity = "error"
+)
+
+// PreflightCheck is a single check that is run on the hosts before a cluster is installed
+type PreflightCheck struct {
+	// Name of the check
+	Name string
+	// Applies returns true when the check should be run on the host. Nil means all hosts.
+	Applies func(*cluster.Host) bool
+	// Check returns the severity and a message that describes the result
+	Check func(context.Context, *cluster.Host) (PreflightSeverity, string)
+}
+
+// PreflightResult is the result of a preflight check on a host
+type PreflightResult struct {
+	Host     string            `json:"host"`
+	Check    string            `json:"check"`
+	Severity PreflightSeverity `json:"severity"`
+	Message  string            `json:"message"`
+}
+
+var (
+	preflightChecksMu sync.Mutex
+	preflightChecks   []PreflightCheck
+)
+
+// RegisterPreflightCheck adds a check to the preflight checks that are run by default
+func RegisterPreflightCheck(c PreflightCheck) {
+	preflightChecksMu.Lock()
+	defer preflightChecksMu.Unlock()
+	preflightChecks = append(preflightChecks, c)
+}
+
+// PreflightChecks returns the registered preflight checks
+func PreflightChecks() []PreflightCheck {
+	preflightChecksMu.Lock()
+	defer preflightChecksMu.Unlock()
+	return slices.Clone(preflightChecks)
+}
+
+// Preflight runs the preflight checks on the hosts and collects the results
+type Preflight struct {
+	GenericPhase
+	// Checks to run, the registered checks when nil
+	Checks []PreflightCheck
+	// Results of the checks in the order of the hosts and checks
+	Results []PreflightResult
+
+	hosts cluster.Hosts
+}
+
+// Title for the phase
+func (p *Preflight) Title() string {
+	return "Run preflight checks"
+}
+
+// Prepare the phase
+func (p *Preflight) Prepare(config *v1beta1.Cluster) error {
+	p.Config = config
+	if p.Checks == nil {
+		p.Checks = PreflightChecks()
+	}
+	p.hosts = p.Config.Spec.Hosts.Filter(func(h *cluster.Host) bool {
+		return !h.Reset
+	})
+	return nil
+}
+
+// ShouldRun is true when there are hosts to check
+func (p *Preflight) ShouldRun() bool {
+	return len(p.hosts) > 0
+}
+
+// Run the phase
+func (p *Preflight) Run(ctx context.Context) error {
+	results := make(map[*cluster.Host][]PreflightResult, len(p.hosts))
+	var mu sync.Mutex
+	err := p.parallelDo(ctx, p.hosts, func(ctx context.Context, h *cluster.Host) error {
+		hostResults := p.checkHost(ctx, h)
+		mu.Lock()
+		results[h] = hostResults
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.Results = nil
+	for _, h := range p.hosts {
+		p.Results = append(p.Results, results[h]...)
+	}
+	return nil
+}
+
+func (p *Preflight) checkHost(ctx context.Context, h *cluster.Host) []PreflightResult {
+	var results []PreflightResult
+	for _, c := range p.Checks {
+		if c.Applies != nil && !c.Applies(h) {
+			continue
+		}
+		severity, msg := c.Check(ctx, h)
+		switch severity {
+		case PreflightError:
+			log.Errorf("%s: preflight check %s failed: %s", h, c.Name, msg)
+		case PreflightWarn:
+			log.Warnf("%s: preflight check %s: %s", h, c.Name, msg)
+		default:
+			log.Debugf("%s: preflight check %s passed: %s", h, c.Name, msg)
+		}
+		results = append(results, PreflightResult{Host: h.String(), Check: c.Name, Severity: severity, Message: msg})
+	}
+	return results
+}
+
+// Count returns the number of results with the severity
+func (p *Preflight) Count(severity PreflightSeverity) int {
+	var n int
+	for _, r := range p.Results {
+		if r.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,319 @@
+package phase
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+)
+
+// preflightRequirement is the minimum resources of a host with a role
+type preflightRequirement struct {
+	cpus    int
+	memMiB  int
+	diskMiB int
+}
+
+// preflightRequirements are the minimum system requirements of k0s per role
+var preflightRequirements = map[string]preflightRequirement{
+	"controller":        {cpus: 1, memMiB: 1024, diskMiB: 512},
+	"worker":            {cpus: 1, memMiB: 512, diskMiB: 1331},
+	"controller+worker": {cpus: 1, memMiB: 1024, diskMiB: 1741},
+	"single":            {cpus: 1, memMiB: 1024, diskMiB: 1741},
+}
+
+// preflightControllerPorts are the ports used by the k0s controller components
+var preflightControllerPorts = []int{6443, 8132, 9443, 2380}
+
+// preflightWorkerPorts are the ports used by the k0s worker components
+var preflightWorkerPorts = []int{10250}
+
+// preflightKernelModules are the kernel modules required by the k0s worker components
+var preflightKernelModules = []string{"overlay", "br_netfilter"}
+
+// k0sProcessPrefixes are the prefixes of the process names of the k0s components
+var k0sProcessPrefixes = []string{"k0s", "kube", "etcd", "konnectivity"}
+
+func init() {
+	RegisterPreflightCheck(PreflightCheck{Name: "disk-space", Applies: isLinux, Check: checkDiskSpace})
+	RegisterPreflightCheck(PreflightCheck{Name: "memory", Applies: isLinux, Check: checkMemory})
+	RegisterPreflightCheck(PreflightCheck{Name: "cpu", Applies: isLinux, Check: checkCPU})
+	RegisterPreflightCheck(PreflightCheck{Name: "ports", Applies: isLinux, Check: checkPorts})
+	RegisterPreflightCheck(PreflightCheck{Name: "cgroups", Applies: isLinuxWorker, Check: checkCgroups})
+	RegisterPreflightCheck(PreflightCheck{Name: "kernel-modules", Applies: isLinuxWorker, Check: checkKernelModules})
+	RegisterPreflightCheck(PreflightCheck{Name: "swap", Applies: isLinuxWorker, Check: checkSwap})
+	RegisterPreflightCheck(PreflightCheck{Name: "selinux", Applies: isLinux, Check: checkSELinux})
+}
+
+func isLinux(h *cluster.Host) bool {
+	return !h.IsWindows()
+}
+
+func isLinuxWorker(h *cluster.Host) bool {
+	return !h.IsWindows() && h.Role != "controller"
+}
+
+func checkDiskSpace(_ context.Context, h *cluster.Host) (PreflightSeverity, string) {
+	dir := h.K0sDataDir()
+	// the data directory does not exist before k0s is installed, check the nearest existing parent
+	out, err := h.ExecOutput(fmt.Sprintf(`d=%s; while [ ! -d "$d" ]; do d=$(dirname "$d"); done; df -Pk "$d"`, h.FS().ShellQuote(dir)))
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to check free disk space in %s: %s", dir, err)
+	}
+	availKiB, err := parseDfAvailable(out)
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to check free disk space in %s: %s", dir, err)
+	}
+	availMiB := int(availKiB / 1024)
+	req := preflightRequirements[h.Role]
+	if availMiB < req.diskMiB {
+		return PreflightError, fmt.Sprintf("%d MiB free in %s, the %s role requires at least %d MiB", availMiB, dir, h.Role, req.diskMiB)
+	}
+	return PreflightOK, fmt.Sprintf("%d MiB free in %s", availMiB, dir)
+}
+
+func checkMemory(_ context.Context, h *cluster.Host) (PreflightSeverity, string) {
+	out, err := h.ExecOutput("cat /proc/meminfo")
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to read /proc/meminfo: %s", err)
+	}
+	totalKiB, err := parseMemTotal(out)
+	if err != nil {
+		return PreflightWarn, err.Error()
+	}
+	totalMiB := int(totalKiB / 1024)
+	req := preflightRequirements[h.Role]
+	if totalMiB < req.memMiB {
+		return PreflightError, fmt.Sprintf("%d MiB of memory, the %s role requires at least %d MiB", totalMiB, h.Role, req.memMiB)
+	}
+	return PreflightOK, fmt.Sprintf("%d MiB of memory", totalMiB)
+}
+
+func checkCPU(_ context.Context, h *cluster.Host) (PreflightSeverity, string) {
+	out, err := h.ExecOutput("nproc")
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to get the number of CPUs: %s", err)
+	}
+	cpus, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to parse the number of CPUs from %q", out)
+	}
+	req := preflightRequirements[h.Role]
+	if cpus < req.cpus {
+		return PreflightError, fmt.Sprintf("%d CPUs, the %s role requires at least %d", cpus, h.Role, req.cpus)
+	}
+	return PreflightOK, fmt.Sprintf("%d CPUs", cpus)
+}
+
+// preflightPorts returns the ports the k0s components of the host's role listen on
+func preflightPorts(h *cluster.Host) []int {
+	var ports []int
+	if h.IsController() {
+		ports = append(ports, preflightControllerPorts...)
+	}
+	if h.Role != "controller" {
+		ports = append(ports, preflightWorkerPorts...)
+	}
+	return ports
+}
+
+func checkPorts(_ context.Context, h *cluster.Host) (PreflightSeverity, string) {
+	if !h.FS().CommandExist("ss") {
+		return PreflightWarn, "can not check the ports without the ss command"
+	}
+	out, err := h.Sudo().ExecOutput("ss -Htlnp")
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to list the listening ports: %s", err)
+	}
+	listeners := parseListeners(out)
+
+	var inUse, byK0s []string
+	for _, port := range preflightPorts(h) {
+		procs, ok := listeners[port]
+		if !ok {
+			continue
+		}
+		desc := strconv.Itoa(port)
+		if len(procs) > 0 {
+			desc += " (" + strings.Join(procs, ", ") + ")"
+		}
+		if isK0sProcess(procs) {
+			byK0s = append(byK0s, desc)
+			continue
+		}
+		inUse = append(inUse, desc)
+	}
+	if len(inUse) > 0 {
+		return PreflightError, "ports already in use: " + strings.Join(inUse, ", ")
+	}
+	if len(byK0s) > 0 {
+		return PreflightOK, "ports in use by k0s: " + strings.Join(byK0s, ", ")
+	}
+	return PreflightOK, "required ports are free"
+}
+
+func isK0sProcess(procs []string) bool {
+	if len(procs) == 0 {
+		return false
+	}
+	for _, p := range procs {
+		if !slices.ContainsFunc(k0sProcessPrefixes, func(prefix string) bool { return strings.HasPrefix(p, prefix) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkCgroups(_ context.Context, h *cluster.Host) (PreflightSeverity, string) {
+	out, err := h.ExecOutput("stat -fc %T /sys/fs/cgroup")
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to detect the cgroup version: %s", err)
+	}
+	if strings.TrimSpace(out) == "cgroup2fs" {
+		return PreflightOK, "cgroup v2"
+	}
+	return PreflightWarn, "cgroup v2 is not enabled, cgroup v1 support is deprecated in kubernetes"
+}
+
+func checkKernelModules(_ context.Context, h *cluster.Host) (PreflightSeverity, string) {
+	script := fmt.Sprintf(`for m in %s; do if [ -d /sys/module/$m ] || grep -qs "/$m.ko" /lib/modules/$(uname -r)/modules.builtin; then echo "$m loaded"; elif modinfo $m >/dev/null 2>&1; then echo "$m available"; else echo "$m missing"; fi; done`, strings.Join(preflightKernelModules, " "))
+	out, err := h.ExecOutput(script)
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to check the kernel modules: %s", err)
+	}
+	var available, missing []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		module, state, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		switch state {
+		case "available":
+			available = append(available, module)
+		case "missing":
+			missing = append(missing, module)
+		}
+	}
+	if len(missing) > 0 {
+		return PreflightError, "kernel modules not available: " + strings.Join(missing, ", ")
+	}
+	if len(available) > 0 {
+		return PreflightWarn, "kernel modules not loaded: " + strings.Join(available, ", ")
+	}
+	return PreflightOK, "kernel modules loaded: " + strings.Join(preflightKernelModules, ", ")
+}
+
+func checkSwap(_ context.Context, h *cluster.Host) (PreflightSeverity, string) {
+	out, err := h.ExecOutput("cat /proc/swaps")
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to read /proc/swaps: %s", err)
+	}
+	if devices := parseSwaps(out); len(devices) > 0 {
+		return PreflightWarn, "swap is enabled on " + strings.Join(devices, ", ") + ", kubelet does not start with swap enabled unless configured to allow it"
+	}
+	return PreflightOK, "swap is disabled"
+}
+
+func checkSELinux(_ context.Context, h *cluster.Host) (PreflightSeverity, string) {
+	if !h.FS().CommandExist("getenforce") {
+		return PreflightOK, "SELinux is not installed"
+	}
+	out, err := h.ExecOutput("getenforce")
+	if err != nil {
+		return PreflightWarn, fmt.Sprintf("failed to get the SELinux mode: %s", err)
+	}
+	mode := strings.TrimSpace(out)
+	if strings.EqualFold(mode, "enforcing") {
+		return PreflightWarn, "SELinux is enforcing, the container runtime needs to be configured with SELinux support"
+	}
+	return PreflightOK, "SELinux is " + strings.ToLower(mode)
+}
+
+// parseDfAvailable returns the available kilobytes from the output of df -Pk
+func parseDfAvailable(out string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("unexpected df output %q", out)
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("unexpected df output %q", out)
+	}
+	avail, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected df output %q: %w", out, err)
+	}
+	return avail, nil
+}
+
+// parseMemTotal returns the total memory in kilobytes from the contents of /proc/meminfo
+func parseMemTotal(out string) (int64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			total, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse MemTotal from /proc/meminfo: %w", err)
+			}
+			return total, nil
+		}
+	}
+	return 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+}
+
+// ssProcessRe matches the process names in the users:(("name",pid=1,fd=3),...) column of ss -p
+var ssProcessRe = regexp.MustCompile(`\("([^"]+)",pid=`)
+
+// parseListeners returns the listening ports and the names of the processes listening on them from
+// the output of ss -Htlnp
+func parseListeners(out string) map[int][]string {
+	listeners := make(map[int][]string)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		local := fields[3]
+		idx := strings.LastIndex(local, ":")
+		if idx == -1 {
+			continue
+		}
+		port, err := strconv.Atoi(local[idx+1:])
+		if err != nil {
+			continue
+		}
+		procs := listeners[port]
+		if procs == nil {
+			procs = []string{}
+		}
+		if len(fields) > 5 {
+			for _, m := range ssProcessRe.FindAllStringSubmatch(strings.Join(fields[5:], " "), -1) {
+				if !slices.Contains(procs, m[1]) {
+					procs = append(procs, m[1])
+				}
+			}
+		}
+		listeners[port] = procs
+	}
+	return listeners
+}
+
+// parseSwaps returns the swap devices from the contents of /proc/swaps
+func parseSwaps(out string) []string {
+	var devices []string
+	for i, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) == 0 {
+			continue
+		}
+		devices = append(devices, fields[0])
+	}
+	return devices
+}
@@ -0,0 +1,90 @@
+package phase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreflightRunsApplicableChecks(t *testing.T) {
+	controller := &cluster.Host{Role: "controller"}
+	worker := &cluster.Host{Role: "worker"}
+	reset := &cluster.Host{Role: "worker", Reset: true}
+	cfg := &v1beta1.Cluster{Spec: &cluster.Spec{Hosts: cluster.Hosts{controller, worker, reset}}}
+
+	p := &Preflight{Checks: []PreflightCheck{
+		{
+			Name: "always",
+			Check: func(_ context.Context, h *cluster.Host) (PreflightSeverity, string) {
+				return PreflightOK, h.Role
+			},
+		},
+		{
+			Name:    "workers",
+			Applies: func(h *cluster.Host) bool { return h.Role == "worker" },
+			Check: func(_ context.Context, _ *cluster.Host) (PreflightSeverity, string) {
+				return PreflightError, "failed"
+			},
+		},
+	}}
+	require.NoError(t, p.Prepare(cfg))
+	p.SetManager(&Manager{})
+	require.True(t, p.ShouldRun())
+	require.NoError(t, p.Run(context.Background()))
+
+	require.Len(t, p.Results, 3)
+	require.Equal(t, "always", p.Results[0].Check)
+	require.Equal(t, "controller", p.Results[0].Message)
+	require.Equal(t, "worker", p.Results[1].Message)
+	require.Equal(t, "workers", p.Results[2].Check)
+	require.Equal(t, PreflightError, p.Results[2].Severity)
+	require.Equal(t, 1, p.Count(PreflightError))
+	require.Equal(t, 2, p.Count(PreflightOK))
+}
+
+func TestPreflightRegisteredChecks(t *testing.T) {
+	var names []string
+	for _, c := range PreflightChecks() {
+		names = append(names, c.Name)
+	}
+	require.Equal(t, []string{"disk-space", "memory", "cpu", "ports", "cgroups", "kernel-modules", "swap", "selinux"}, names)
+}
+
+func TestPreflightPorts(t *testing.T) {
+	require.Equal(t, []int{6443, 8132, 9443, 2380}, preflightPorts(&cluster.Host{Role: "controller"}))
+	require.Equal(t, []int{10250}, preflightPorts(&cluster.Host{Role: "worker"}))
+	require.Equal(t, []int{6443, 8132, 9443, 2380, 10250}, preflightPorts(&cluster.Host{Role: "single"}))
+}
+
+func TestPreflightParsers(t *testing.T) {
+	avail, err := parseDfAvailable("Filesystem     1024-blocks    Used Available Capacity Mounted on\n/dev/sda1         30830592 9184212  20056724      32% /\n")
+	require.NoError(t, err)
+	require.Equal(t, int64(20056724), avail)
+	_, err = parseDfAvailable("df: /foo: No such file or directory")
+	require.Error(t, err)
+
+	total, err := parseMemTotal("MemTotal:        4030180 kB\nMemFree:          256024 kB\n")
+	require.NoError(t, err)
+	require.Equal(t, int64(4030180), total)
+	_, err = parseMemTotal("MemFree: 1 kB")
+	require.Error(t, err)
+
+	listeners := parseListeners(`LISTEN 0      4096               *:6443             *:*    users:(("kube-apiserver",pid=1234,fd=7))
+LISTEN 0      4096         0.0.0.0:10250       0.0.0.0:*    users:(("kubelet",pid=99,fd=3),("kubelet",pid=99,fd=4))
+LISTEN 0      511             [::]:9443          [::]:*    users:(("nginx",pid=5,fd=6),("k0s",pid=7,fd=8))
+LISTEN 0      128        127.0.0.1:2380        0.0.0.0:*
+`)
+	require.Equal(t, []string{"kube-apiserver"}, listeners[6443])
+	require.Equal(t, []string{"kubelet"}, listeners[10250])
+	require.Equal(t, []string{"nginx", "k0s"}, listeners[9443])
+	require.Equal(t, []string{}, listeners[2380])
+	require.True(t, isK0sProcess(listeners[6443]))
+	require.False(t, isK0sProcess(listeners[9443]))
+	require.False(t, isK0sProcess(listeners[2380]))
+
+	require.Empty(t, parseSwaps("Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n"))
+	require.Equal(t, []string{"/swap.img"}, parseSwaps("Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n/swap.img                               file\t\t4194300\t\t0\t\t-2\n"))
+}
@@ -0,0 +1,315 @@
+package phase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/k0sctl/pkg/retry"
+	"github.com/k0sproject/rig/v2/cmd"
+	log "github.com/sirupsen/logrus"
+)
+
+// kubeNode represents the parts of the output of `kubectl get node -o json` that k0sctl manages
+type kubeNode struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Taints []struct {
+			Key    string `json:"key"`
+			Value  string `json:"value"`
+			Effect string `json:"effect"`
+		} `json:"taints"`
+	} `json:"spec"`
+}
+
+// managedNodeMetadata is the content of the cluster.NodeMetadataAnnotation annotation
+type managedNodeMetadata struct {
+	Labels      []string `json:"labels,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+	Taints      []string `json:"taints,omitempty"`
+}
+
+// nodeMetadataChanges are the changes needed to make the node match the host configuration
+type nodeMetadataChanges struct {
+	setLabels         map[string]string
+	removeLabels      []string
+	setAnnotations    map[string]string
+	removeAnnotations []string
+	addTaints         []cluster.NodeTaint
+	removeTaints      []string
+}
+
+// empty returns true when there is nothing to change
+func (c nodeMetadataChanges) empty() bool {
+	return len(c.setLabels) == 0 && len(c.removeLabels) == 0 && len(c.setAnnotations) == 0 && len(c.removeAnnotations) == 0 && len(c.addTaints) == 0 && len(c.removeTaints) == 0
+}
+
+// ReconcileNodeMetadata makes the labels, annotations and taints of the kubernetes nodes match the
+// host configuration. Only the ones previously set by k0sctl are removed.
+type ReconcileNodeMetadata struct {
+	GenericPhase
+	hosts  cluster.Hosts
+	leader *cluster.Host
+}
+
+// Title for the phase
+func (p *ReconcileNodeMetadata) Title() string {
+	return "Reconcile node labels, annotations and taints"
+}
+
+// Prepare the phase
+func (p *ReconcileNodeMetadata) Prepare(config *v1beta1.Cluster) error {
+	p.Config = config
+	p.leader = p.Config.Spec.K0sLeader()
+	p.hosts = p.Config.Spec.Hosts.Filter(func(h *cluster.Host) bool {
+		return !h.Reset && h.Role != "controller"
+	})
+	return nil
+}
+
+// ShouldRun is true when there are hosts that run a kubelet. The hosts without any labels,
+// annotations or taints are still checked for ones that were previously set by k0sctl.
+func (p *ReconcileNodeMetadata) ShouldRun() bool {
+	return len(p.hosts) > 0 && p.leader != nil
+}
+
+// Run the phase
+func (p *ReconcileNodeMetadata) Run(ctx context.Context) error {
+	return p.parallelDo(ctx, p.hosts, p.reconcile)
+}
+
+func (p *ReconcileNodeMetadata) reconcile(ctx context.Context, h *cluster.Host) error {
+	taints, err := h.ParseNodeTaints()
+	if err != nil {
+		return err
+	}
+
+	if !p.IsWet() && (p.leader.Metadata.K0sRunningVersion == nil || h.Metadata.K0sRunningVersion == nil) {
+		// the node does not exist yet
+		changes := nodeMetadataDiff(&kubeNode{}, h.Labels, h.Annotations, taints)
+		if !changes.empty() {
+			p.DryMsg(h, describeNodeMetadataChanges(changes))
+		}
+		return nil
+	}
+
+	var node *kubeNode
+	err = retry.WithDefaultTimeout(ctx, func(_ context.Context) error {
+		n, err := p.getNode(h)
+		if err != nil {
+			return err
+		}
+		node = n
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("get node %s: %w", h.KubernetesNodeName(), err)
+	}
+
+	changes := nodeMetadataDiff(node, h.Labels, h.Annotations, taints)
+	if changes.empty() {
+		log.Debugf("%s: node labels, annotations and taints are up to date", h)
+		return nil
+	}
+	desc := describeNodeMetadataChanges(changes)
+	return p.Wet(h, desc, func() error {
+		log.Infof("%s: %s", h, desc)
+		return p.apply(h, changes)
+	})
+}
+
+func (p *ReconcileNodeMetadata) getNode(h *cluster.Host) (*kubeNode, error) {
+	out, err := p.leader.Sudo().ExecOutput(p.leader.Configurer.KubectlCmdf(p.leader, p.leader.K0sDataDir(), "get node %s -o json", p.leader.FS().ShellQuote(h.KubernetesNodeName())), cmd.HideOutput())
+	if err != nil {
+		return nil, err
+	}
+	node := &kubeNode{}
+	if err := json.Unmarshal([]byte(out), node); err != nil {
+		return nil, fmt.Errorf("decode kubectl get node output: %w", err)
+	}
+	return node, nil
+}
+
+// apply makes the changes, the annotations are updated last so that the record of the managed
+// metadata is not updated if setting the labels or taints fails
+func (p *ReconcileNodeMetadata) apply(h *cluster.Host, changes nodeMetadataChanges) error {
+	fs := p.leader.FS()
+	name := fs.ShellQuote(h.KubernetesNodeName())
+	kubectl := func(args ...string) error {
+		return p.leader.Sudo().Exec(p.leader.Configurer.KubectlCmdf(p.leader, p.leader.K0sDataDir(), "%s", strings.Join(args, " ")))
+	}
+
+	if len(changes.addTaints) > 0 || len(changes.removeTaints) > 0 {
+		args := []string{"taint", "nodes", "--overwrite", name}
+		for _, t := range changes.addTaints {
+			args = append(args, fs.ShellQuote(t.String()))
+		}
+		for _, id := range changes.removeTaints {
+			args = append(args, fs.ShellQuote(id+"-"))
+		}
+		if err := kubectl(args...); err != nil {
+			return fmt.Errorf("update node taints: %w", err)
+		}
+	}
+
+	if len(changes.setLabels) > 0 || len(changes.removeLabels) > 0 {
+		args := append([]string{"label", "node", "--overwrite", name}, metadataArgs(fs.ShellQuote, changes.setLabels, changes.removeLabels)...)
+		if err := kubectl(args...); err != nil {
+			return fmt.Errorf("update node labels: %w", err)
+		}
+	}
+
+	if len(changes.setAnnotations) > 0 || len(changes.removeAnnotations) > 0 {
+		args := append([]string{"annotate", "node", "--overwrite", name}, metadataArgs(fs.ShellQuote, changes.setAnnotations, changes.removeAnnotations)...)
+		if err := kubectl(args...); err != nil {
+			return fmt.Errorf("update node annotations: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// metadataArgs returns the kubectl label and annotate arguments for setting and removing keys
+func metadataArgs(quote func(string) string, set map[string]string, remove []string) []string {
+	var args []string
+	for _, k := range slices.Sorted(maps.Keys(set)) {
+		args = append(args, quote(k+"="+set[k]))
+	}
+	for _, k := range remove {
+		args = append(args, quote(k+"-"))
+	}
+	return args
+}
+
+// nodeMetadataDiff returns the changes needed to make the node have the given labels, annotations
+// and taints. The keys that were previously set by k0sctl but are no longer configured are removed
+// and the record of the managed keys is updated.
+func nodeMetadataDiff(node *kubeNode, labels, annotations map[string]string, taints []cluster.NodeTaint) nodeMetadataChanges {
+	changes := nodeMetadataChanges{
+		setLabels:      make(map[string]string),
+		setAnnotations: make(map[string]string),
+	}
+
+	var previous managedNodeMetadata
+	record, hasRecord := node.Metadata.Annotations[cluster.NodeMetadataAnnotation]
+	if hasRecord {
+		if err := json.Unmarshal([]byte(record), &previous); err != nil {
+			log.Warnf("node %s: ignoring invalid %s annotation: %v", node.Metadata.Name, cluster.NodeMetadataAnnotation, err)
+		}
+	}
+
+	for k, v := range labels {
+		if current, ok := node.Metadata.Labels[k]; !ok || current != v {
+			changes.setLabels[k] = v
+		}
+	}
+	for _, k := range previous.Labels {
+		if _, ok := labels[k]; ok {
+			continue
+		}
+		if _, ok := node.Metadata.Labels[k]; ok {
+			changes.removeLabels = append(changes.removeLabels, k)
+		}
+	}
+
+	for k, v := range annotations {
+		if current, ok := node.Metadata.Annotations[k]; !ok || current != v {
+			changes.setAnnotations[k] = v
+		}
+	}
+	for _, k := range previous.Annotations {
+		if _, ok := annotations[k]; ok {
+			continue
+		}
+		if _, ok := node.Metadata.Annotations[k]; ok {
+			changes.removeAnnotations = append(changes.removeAnnotations, k)
+		}
+	}
+
+	current := make(map[string]string, len(node.Spec.Taints))
+	for _, t := range node.Spec.Taints {
+		current[t.Key+":"+t.Effect] = t.Value
+	}
+	var taintIDs []string
+	for _, t := range taints {
+		taintIDs = append(taintIDs, t.ID())
+		if v, ok := current[t.ID()]; !ok || v != t.Value {
+			changes.addTaints = append(changes.addTaints, t)
+		}
+	}
+	for _, id := range previous.Taints {
+		if slices.Contains(taintIDs, id) {
+			continue
+		}
+		if _, ok := current[id]; ok {
+			changes.removeTaints = append(changes.removeTaints, id)
+		}
+	}
+
+	managed := managedNodeMetadata{
+		Labels:      slices.Sorted(maps.Keys(labels)),
+		Annotations: slices.Sorted(maps.Keys(annotations)),
+		Taints:      taintIDs,
+	}
+	slices.Sort(managed.Taints)
+	if len(managed.Labels) == 0 && len(managed.Annotations) == 0 && len(managed.Taints) == 0 {
+		if hasRecord {
+			changes.removeAnnotations = append(changes.removeAnnotations, cluster.NodeMetadataAnnotation)
+		}
+		return changes
+	}
+	// marshaling a struct of string slices can not fail
+	data, _ := json.Marshal(managed)
+	if !hasRecord || record != string(data) {
+		changes.setAnnotations[cluster.NodeMetadataAnnotation] = string(data)
+	}
+
+	return changes
+}
+
+// describeNodeMetadataChanges returns a human readable description of the changes
+func describeNodeMetadataChanges(c nodeMetadataChanges) string {
+	var parts []string
+	if len(c.setLabels) > 0 {
+		parts = append(parts, "set labels "+strings.Join(metadataArgs(noQuote, c.setLabels, nil), ", "))
+	}
+	if len(c.removeLabels) > 0 {
+		parts = append(parts, "remove labels "+strings.Join(c.removeLabels, ", "))
+	}
+	if len(c.addTaints) > 0 {
+		taints := make([]string, len(c.addTaints))
+		for i, t := range c.addTaints {
+			taints[i] = t.String()
+		}
+		parts = append(parts, "add taints "+strings.Join(taints, ", "))
+	}
+	if len(c.removeTaints) > 0 {
+		parts = append(parts, "remove taints "+strings.Join(c.removeTaints, ", "))
+	}
+	annotations := slices.Sorted(maps.Keys(c.setAnnotations))
+	annotations = slices.DeleteFunc(annotations, func(k string) bool { return k == cluster.NodeMetadataAnnotation })
+	if len(annotations) > 0 {
+		parts = append(parts, "set annotations "+strings.Join(annotations, ", "))
+	}
+	removeAnnotations := slices.DeleteFunc(slices.Clone(c.removeAnnotations), func(k string) bool { return k == cluster.NodeMetadataAnnotation })
+	if len(removeAnnotations) > 0 {
+		parts = append(parts, "remove annotations "+strings.Join(removeAnnotations, ", "))
+	}
+	if len(parts) == 0 {
+		return "update the record of the node metadata managed by k0sctl"
+	}
+	return strings.Join(parts, "; ")
+}
+
+func noQuote(s string) string {
+	return s
+}
@@ -0,0 +1,49 @@
+package phase
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeMetadataDiff(t *testing.T) {
+	node := &kubeNode{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"metadata": {
+			"name": "worker-1",
+			"labels": {"kubernetes.io/hostname": "worker-1", "zone": "rack-1", "tier": "db", "other": "x"},
+			"annotations": {"k0sctl.k0sproject.io/managed-metadata": "{\"labels\":[\"tier\",\"zone\"],\"taints\":[\"dedicated:NoSchedule\"]}"}
+		},
+		"spec": {"taints": [{"key": "dedicated", "value": "db", "effect": "NoSchedule"}, {"key": "foreign", "effect": "NoExecute"}]}
+	}`), node))
+
+	taint, err := cluster.ParseNodeTaint("gpu=true:NoSchedule")
+	require.NoError(t, err)
+
+	changes := nodeMetadataDiff(node, map[string]string{"zone": "rack-2", "other": "x"}, map[string]string{"example.com/owner": "a"}, []cluster.NodeTaint{taint})
+	require.Equal(t, map[string]string{"zone": "rack-2"}, changes.setLabels)
+	require.Equal(t, []string{"tier"}, changes.removeLabels)
+	require.Equal(t, []cluster.NodeTaint{taint}, changes.addTaints)
+	require.Equal(t, []string{"dedicated:NoSchedule"}, changes.removeTaints)
+	require.Empty(t, changes.removeAnnotations)
+	require.Equal(t, "a", changes.setAnnotations["example.com/owner"])
+
+	var managed managedNodeMetadata
+	require.NoError(t, json.Unmarshal([]byte(changes.setAnnotations[cluster.NodeMetadataAnnotation]), &managed))
+	require.Equal(t, managedNodeMetadata{Labels: []string{"other", "zone"}, Annotations: []string{"example.com/owner"}, Taints: []string{"gpu:NoSchedule"}}, managed)
+
+	t.Run("nothing configured", func(t *testing.T) {
+		changes := nodeMetadataDiff(node, nil, nil, nil)
+		require.Empty(t, changes.setLabels)
+		require.ElementsMatch(t, []string{"tier", "zone"}, changes.removeLabels)
+		require.Equal(t, []string{"dedicated:NoSchedule"}, changes.removeTaints)
+		require.Equal(t, []string{cluster.NodeMetadataAnnotation}, changes.removeAnnotations)
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		changes := nodeMetadataDiff(&kubeNode{}, nil, nil, nil)
+		require.True(t, changes.empty())
+	})
+}
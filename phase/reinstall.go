@@ -82,6 +82,11 @@ func (p *Reinstall) reinstall(ctx context.Context, h *cluster.Host) error {
 		return err
 	}
 	log.Infof("%s: reinstalling k0s", h)
+	if !p.IsWet() {
+		if flags, err := h.K0sInstallFlags(); err == nil {
+			p.PlanChange(h, PlanAction{Action: PlanActionReinstall, Target: "installFlags", Old: strings.Join(h.Metadata.K0sStatusArgs, " "), New: strings.Join(flags, " ")})
+		}
+	}
 	err = p.Wet(h, fmt.Sprintf("reinstall k0s using `%s", strings.ReplaceAll(cmd, h.K0sInstallLocation(), "k0s")), func() error {
 		if err := h.Sudo().Exec(cmd); err != nil {
 			return fmt.Errorf("failed to reinstall k0s: %w", err)
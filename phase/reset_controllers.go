@@ -63,6 +63,7 @@ func (p *ResetControllers) ShouldRun() bool {
 func (p *ResetControllers) DryRun() error {
 	for _, h := range p.hosts {
 		p.DryMsg(h, "reset node")
+		p.PlanChange(h, PlanAction{Action: PlanActionReset, Target: "k0s", Old: h.Metadata.K0sRunningVersion.String()})
 	}
 	return nil
 }
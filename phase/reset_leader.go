@@ -42,6 +42,7 @@ func (p *ResetLeader) Prepare(config *v1beta1.Cluster) error {
 // DryRun reports that the host will be reset
 func (p *ResetLeader) DryRun() error {
 	p.DryMsg(p.leader, "reset node")
+	p.PlanChange(p.leader, PlanAction{Action: PlanActionReset, Target: "k0s", Old: p.leader.Metadata.K0sRunningVersion.String()})
 	return nil
 }
 
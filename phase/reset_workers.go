@@ -62,95 +62,107 @@ func (p *ResetWorkers) ShouldRun() bool {
 func (p *ResetWorkers) DryRun() error {
 	for _, h := range p.hosts {
 		p.DryMsg(h, "node would be reset")
+		p.PlanChange(h, PlanAction{Action: PlanActionReset, Target: "k0s", Old: h.Metadata.K0sRunningVersion.String()})
 	}
 	return nil
 }
 
-// Run the phase
+// Run the phase. With options.concurrency.groupBy the workers are reset one group at a time.
 func (p *ResetWorkers) Run(ctx context.Context) error {
-	return p.parallelDo(ctx, p.hosts, func(_ context.Context, h *cluster.Host) error {
-		if t := p.Config.Spec.Options.EvictTaint; t.Enabled {
-			log.Debugf("%s: add taint: %s", h, t.String())
-			if err := p.leader.AddTaint(h, t.String()); err != nil {
-				return fmt.Errorf("add taint: %w", err)
-			}
-		}
-		if !p.NoDrain {
-			log.Debugf("%s: draining node", h)
-			if err := p.leader.DrainNode(
-				&cluster.Host{
-					Metadata: cluster.HostMetadata{
-						Hostname: h.KubernetesNodeName(),
-					},
-				},
-				p.Config.Spec.Options.Drain,
-			); err != nil {
-				log.Warnf("%s: failed to drain node: %s", h, err.Error())
-			}
+	groups, err := workerGroups(p.Config.Spec, p.leader, p.hosts, !p.IsWet())
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if err := p.parallelDo(ctx, group, p.reset); err != nil {
+			return err
 		}
-		log.Debugf("%s: draining node completed", h)
+	}
+	return nil
+}
 
-		log.Debugf("%s: deleting node...", h)
-		if !p.NoDelete {
-			if err := p.leader.DeleteNode(&cluster.Host{
+func (p *ResetWorkers) reset(ctx context.Context, h *cluster.Host) error {
+	if t := p.Config.Spec.Options.EvictTaint; t.Enabled {
+		log.Debugf("%s: add taint: %s", h, t.String())
+		if err := p.leader.AddTaint(h, t.String()); err != nil {
+			return fmt.Errorf("add taint: %w", err)
+		}
+	}
+	if !p.NoDrain {
+		log.Debugf("%s: draining node", h)
+		if err := p.leader.DrainNode(
+			&cluster.Host{
 				Metadata: cluster.HostMetadata{
 					Hostname: h.KubernetesNodeName(),
 				},
-			}); err != nil {
-				log.Warnf("%s: failed to delete node: %s", h, err.Error())
-			}
+			},
+			p.Config.Spec.Options.Drain,
+		); err != nil {
+			log.Warnf("%s: failed to drain node: %s", h, err.Error())
 		}
-		log.Debugf("%s: deleting node", h)
-
-		if svc, err := h.Sudo().Service(h.K0sServiceName()); err != nil {
-			log.Warnf("%s: failed to get service %s: %v", h, h.K0sServiceName(), err)
-		} else if svc.IsRunning(ctx) {
-			log.Debugf("%s: stopping k0s...", h)
-			if err := svc.Stop(ctx); err != nil {
-				log.Warnf("%s: failed to stop k0s: %s", h, err.Error())
-			}
-			log.Debugf("%s: waiting for k0s to stop", h)
-			if err := retry.WithDefaultTimeout(ctx, node.ServiceStoppedFunc(h, h.K0sServiceName())); err != nil {
-				log.Warnf("%s: failed to wait for k0s to stop: %s", h, err.Error())
-			}
-			log.Debugf("%s: stopping k0s completed", h)
+	}
+	log.Debugf("%s: draining node completed", h)
+
+	log.Debugf("%s: deleting node...", h)
+	if !p.NoDelete {
+		if err := p.leader.DeleteNode(&cluster.Host{
+			Metadata: cluster.HostMetadata{
+				Hostname: h.KubernetesNodeName(),
+			},
+		}); err != nil {
+			log.Warnf("%s: failed to delete node: %s", h, err.Error())
 		}
-
-		log.Debugf("%s: resetting k0s...", h)
-		var stdoutbuf, stderrbuf bytes.Buffer
-		proc := h.Sudo().Proc(h.K0sResetCommand())
-		proc.Stdout = &stdoutbuf
-		proc.Stderr = &stderrbuf
-		waiter, err := proc.Start(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to run k0s reset: %w", err)
+	}
+	log.Debugf("%s: deleting node", h)
+
+	if svc, err := h.Sudo().Service(h.K0sServiceName()); err != nil {
+		log.Warnf("%s: failed to get service %s: %v", h, h.K0sServiceName(), err)
+	} else if svc.IsRunning(ctx) {
+		log.Debugf("%s: stopping k0s...", h)
+		if err := svc.Stop(ctx); err != nil {
+			log.Warnf("%s: failed to stop k0s: %s", h, err.Error())
 		}
-		if err := waiter.Wait(); err != nil {
-			log.Warnf("%s: k0s reset reported failure: %s %s", h, stderrbuf.String(), stdoutbuf.String())
+		log.Debugf("%s: waiting for k0s to stop", h)
+		if err := retry.WithDefaultTimeout(ctx, node.ServiceStoppedFunc(h, h.K0sServiceName())); err != nil {
+			log.Warnf("%s: failed to wait for k0s to stop: %s", h, err.Error())
 		}
-		log.Debugf("%s: resetting k0s completed", h)
+		log.Debugf("%s: stopping k0s completed", h)
+	}
 
-		log.Debugf("%s: removing config...", h)
-		if dErr := h.Sudo().FS().Remove(h.Configurer.K0sConfigPath()); dErr != nil {
-			log.Warnf("%s: failed to remove existing configuration %s: %s", h, h.Configurer.K0sConfigPath(), dErr)
-		}
-		log.Debugf("%s: removing config completed", h)
+	log.Debugf("%s: resetting k0s...", h)
+	var stdoutbuf, stderrbuf bytes.Buffer
+	proc := h.Sudo().Proc(h.K0sResetCommand())
+	proc.Stdout = &stdoutbuf
+	proc.Stderr = &stderrbuf
+	waiter, err := proc.Start(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to run k0s reset: %w", err)
+	}
+	if err := waiter.Wait(); err != nil {
+		log.Warnf("%s: k0s reset reported failure: %s %s", h, stderrbuf.String(), stdoutbuf.String())
+	}
+	log.Debugf("%s: resetting k0s completed", h)
 
-		log.Debugf("%s: removing k0s binary...", h)
-		if dErr := h.Sudo().FS().Remove(h.Configurer.K0sBinaryPath()); dErr != nil {
-			log.Warnf("%s: failed to remove existing binary %s: %s", h, h.Configurer.K0sConfigPath(), dErr)
-		}
-		log.Debugf("%s: removing binary completed", h)
-
-		if len(h.Environment) > 0 {
-			if svc, err := h.Sudo().Service(h.K0sServiceName()); err != nil {
-				log.Warnf("%s: failed to get service %s: %v", h, h.K0sServiceName(), err)
-			} else if err := svc.SetEnvironment(ctx, map[string]string{}); err != nil {
-				log.Warnf("%s: failed to clean up service environment: %s", h, err.Error())
-			}
+	log.Debugf("%s: removing config...", h)
+	if dErr := h.Sudo().FS().Remove(h.Configurer.K0sConfigPath()); dErr != nil {
+		log.Warnf("%s: failed to remove existing configuration %s: %s", h, h.Configurer.K0sConfigPath(), dErr)
+	}
+	log.Debugf("%s: removing config completed", h)
+
+	log.Debugf("%s: removing k0s binary...", h)
+	if dErr := h.Sudo().FS().Remove(h.Configurer.K0sBinaryPath()); dErr != nil {
+		log.Warnf("%s: failed to remove existing binary %s: %s", h, h.Configurer.K0sBinaryPath(), dErr)
+	}
+	log.Debugf("%s: removing binary completed", h)
+
+	if len(h.Environment) > 0 {
+		if svc, err := h.Sudo().Service(h.K0sServiceName()); err != nil {
+			log.Warnf("%s: failed to get service %s: %v", h, h.K0sServiceName(), err)
+		} else if err := svc.SetEnvironment(ctx, map[string]string{}); err != nil {
+			log.Warnf("%s: failed to clean up service environment: %s", h, err.Error())
 		}
+	}
 
-		log.Infof("%s: reset", h)
-		return nil
-	})
+	log.Infof("%s: reset", h)
+	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
 	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
 	k0s "github.com/k0sproject/k0sctl/pkg/k0s"
+	"github.com/k0sproject/version"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -14,10 +15,19 @@ import (
 type StageBinaries struct {
 	GenericPhase
 	hosts cluster.Hosts
+	// hop is the intermediate version when the phase is run by UpgradePath
+	hop *version.Version
+
+	// peerSeed and peerServe default to canSeed and startPeerServer
+	peerSeed  func(*cluster.Host) bool
+	peerServe func(context.Context, *cluster.Host, int) (*peerServer, error)
 }
 
 // Title for the phase
 func (p *StageBinaries) Title() string {
+	if p.hop != nil {
+		return "Stage k0s " + p.hop.String() + " binaries on hosts"
+	}
 	return "Stage k0s binaries on hosts"
 }
 
@@ -30,6 +40,14 @@ func (p *StageBinaries) Prepare(config *v1beta1.Cluster) error {
 			log.Debugf("%s: skipping binary staging (reset)", h)
 			return false
 		}
+		if p.hop != nil && !h.Metadata.NeedsUpgrade {
+			log.Debugf("%s: skipping binary staging (not upgraded to %s)", h, p.hop)
+			return false
+		}
+		if h.Metadata.NeedsUpgrade && p.Config.Spec.Options.UpgradeStrategy.AutopilotEnabled() {
+			log.Debugf("%s: skipping binary staging (upgraded by autopilot)", h)
+			return false
+		}
 		provider, err := h.K0sBinaryProvider(p.Config.Spec.K0s.Version)
 		if err != nil {
 			if prepareErr == nil {
@@ -60,6 +78,8 @@ func (p *StageBinaries) ShouldRun() bool {
 // not a permanent cluster change — on success, the temp file is removed by
 // Disconnect (including its DryRun behavior), while CleanUp is only invoked on
 // failure paths. CleanUp is also called here on error as an extra safety net.
+// Peer-to-peer distribution is not performed in dry-run mode, the binary is uploaded
+// directly to every host and the peer transfers are only reported.
 func (p *StageBinaries) DryRun() error {
 	if err := p.Run(context.Background()); err != nil {
 		p.CleanUp()
@@ -89,6 +109,14 @@ func (p *StageBinaries) Run(ctx context.Context) error {
 		return err
 	}
 
+	if p.Config.Spec.Options.BinaryDistribution.PeerEnabled() {
+		direct, err := p.distributePeer(ctx, uploadHosts)
+		if err != nil {
+			return err
+		}
+		uploadHosts = direct
+	}
+
 	// Stage both groups sequentially so the combined concurrency never exceeds
 	// the global limit, while still respecting the upload concurrency sub-limit.
 	if err := p.parallelDo(ctx, otherHosts, p.stageForHost); err != nil {
@@ -122,29 +150,42 @@ func (p *StageBinaries) stageForHost(ctx context.Context, h *cluster.Host) error
 // Stage can upload the binary to remote hosts, and subsequent phases need it for validation.
 // EnsureCached logs its own progress (info/debug), so no additional DryMsg/Wet wrapper is needed.
 func (p *StageBinaries) populateCaches(ctx context.Context, hosts cluster.Hosts) error {
+	return ensureCached(ctx, hosts, "k0s binary", func(h *cluster.Host) (k0s.BinaryCacher, error) {
+		provider, err := h.K0sBinaryProvider(p.Config.Spec.K0s.Version)
+		if err != nil {
+			return nil, err
+		}
+		bc, _ := provider.(k0s.BinaryCacher)
+		return bc, nil
+	})
+}
+
+// ensureCached calls EnsureCached once per unique cache key of the hosts' cachers so that each
+// file is downloaded at most once regardless of how many hosts need it. Hosts for which get
+// returns nil are skipped.
+func ensureCached(ctx context.Context, hosts cluster.Hosts, what string, get func(*cluster.Host) (k0s.BinaryCacher, error)) error {
 	seen := make(map[string]bool)
 	for _, h := range hosts {
-		provider, err := h.K0sBinaryProvider(p.Config.Spec.K0s.Version)
+		bc, err := get(h)
 		if err != nil {
 			return fmt.Errorf("%s: %w", h, err)
 		}
-		bc, ok := provider.(k0s.BinaryCacher)
-		if !ok {
+		if bc == nil {
 			continue
 		}
 		key, err := bc.BinaryCacheKey()
 		if err != nil {
-			return fmt.Errorf("%s: get binary cache key: %w", h, err)
+			return fmt.Errorf("%s: get %s cache key: %w", h, what, err)
 		}
 		if key == "" {
-			return fmt.Errorf("%s: binary cache key is empty", h)
+			return fmt.Errorf("%s: %s cache key is empty", h, what)
 		}
 		if seen[key] {
 			continue
 		}
 		seen[key] = true
 		if err := bc.EnsureCached(ctx); err != nil {
-			return fmt.Errorf("%s: cache k0s binary: %w", h, err)
+			return fmt.Errorf("%s: cache %s: %w", h, what, err)
 		}
 	}
 	return nil
@@ -40,6 +40,31 @@ func TestStageBinariesPrepareFiltersHosts(t *testing.T) {
 	assert.Same(t, eligible, phase.hosts[0])
 }
 
+func TestStageBinariesPrepareHopOnlyStagesUpgradedHosts(t *testing.T) {
+	hop := version.MustParse("v1.29.4+k0s.0")
+
+	upgraded := &cluster.Host{Metadata: cluster.HostMetadata{NeedsUpgrade: true}}
+	upgraded.SetK0sBinaryProvider(&fakeBinaryProvider{needsUpgrade: true})
+
+	// a new host needs a binary but it is installed with the target version
+	newHost := &cluster.Host{}
+	newHost.SetK0sBinaryProvider(&fakeBinaryProvider{needsUpgrade: true})
+
+	cfg := &v1beta1.Cluster{
+		Spec: &cluster.Spec{
+			Hosts: cluster.Hosts{upgraded, newHost},
+			K0s:   &cluster.K0s{Version: hop},
+		},
+	}
+
+	phase := &StageBinaries{hop: hop}
+	require.Equal(t, "Stage k0s v1.29.4+k0s.0 binaries on hosts", phase.Title())
+	require.NoError(t, phase.Prepare(cfg))
+
+	require.Len(t, phase.hosts, 1)
+	assert.Same(t, upgraded, phase.hosts[0])
+}
+
 func TestStageBinariesRunStagesBinary(t *testing.T) {
 	targetVersion := version.MustParse("v1.30.0+k0s.0")
 
@@ -123,6 +148,188 @@ func TestStageBinariesDeduplicatesCacheByKey(t *testing.T) {
 	assert.Equal(t, 1, p1.cacheCalls+p2.cacheCalls, "expected exactly one EnsureCached call across both hosts")
 }
 
+func TestStageBinariesPeerDistributionFallsBackToDirectUpload(t *testing.T) {
+	targetVersion := version.MustParse("v1.30.1+k0s.0")
+
+	// The providers do not implement k0s.PeerStager, so every host gets a direct upload.
+	makeHost := func() *cluster.Host {
+		h := &cluster.Host{Configurer: &linuxcfg.Ubuntu{}, Metadata: cluster.HostMetadata{Arch: "amd64"}}
+		h.SetK0sBinaryProvider(&fakeCachingBinaryProvider{
+			fakeBinaryProvider: &fakeBinaryProvider{needsUpgrade: true, stagePath: "/tmp/k0s-stage", isUpload: true},
+			cacheKey:           "amd64/linux/v1.30.1+k0s.0",
+		})
+		return h
+	}
+	host1, host2 := makeHost(), makeHost()
+
+	cfg := &v1beta1.Cluster{
+		Spec: &cluster.Spec{
+			Hosts:   cluster.Hosts{host1, host2},
+			K0s:     &cluster.K0s{Version: targetVersion},
+			Options: cluster.Options{BinaryDistribution: cluster.BinaryDistributionOption{Mode: cluster.BinaryDistributionPeer}},
+		},
+	}
+
+	phase := &StageBinaries{}
+	require.NoError(t, phase.Prepare(cfg))
+	phase.SetManager(&Manager{})
+
+	require.NoError(t, phase.Run(context.Background()))
+	for _, h := range cfg.Spec.Hosts {
+		prov, err := h.K0sBinaryProvider(targetVersion)
+		require.NoError(t, err)
+		assert.Equal(t, 1, prov.(*fakeCachingBinaryProvider).stageCalls)
+		assert.Equal(t, "/tmp/k0s-stage", h.Metadata.K0sBinaryTempFile)
+	}
+}
+
+func TestStageBinariesPeerDistributionStagesFromSeed(t *testing.T) {
+	targetVersion := version.MustParse("v1.30.1+k0s.0")
+
+	makeHost := func(peerErr error) (*cluster.Host, *fakePeerStagingProvider) {
+		h := &cluster.Host{Configurer: &linuxcfg.Ubuntu{}, Metadata: cluster.HostMetadata{Arch: "amd64"}}
+		provider := &fakePeerStagingProvider{
+			fakeCachingBinaryProvider: &fakeCachingBinaryProvider{
+				fakeBinaryProvider: &fakeBinaryProvider{needsUpgrade: true, stagePath: "/tmp/k0s-stage", isUpload: true},
+				cacheKey:           "amd64/linux/v1.30.1+k0s.0",
+			},
+			peerPath: "/tmp/k0s-peer",
+			peerErr:  peerErr,
+		}
+		h.SetK0sBinaryProvider(provider)
+		return h, provider
+	}
+	seed, seedProvider := makeHost(nil)
+	peer, peerProvider := makeHost(nil)
+	failing, failingProvider := makeHost(errors.New("checksum mismatch"))
+
+	cfg := &v1beta1.Cluster{
+		Spec: &cluster.Spec{
+			Hosts:   cluster.Hosts{seed, peer, failing},
+			K0s:     &cluster.K0s{Version: targetVersion},
+			Options: cluster.Options{BinaryDistribution: cluster.BinaryDistributionOption{Mode: cluster.BinaryDistributionPeer}},
+		},
+	}
+
+	var servedFrom []*cluster.Host
+	phase := &StageBinaries{
+		peerSeed: func(h *cluster.Host) bool { return h == seed },
+		peerServe: func(_ context.Context, h *cluster.Host, _ int) (*peerServer, error) {
+			// the seed has the binary staged before the server is started
+			assert.Equal(t, "/tmp/k0s-stage", h.Metadata.K0sBinaryTempFile)
+			servedFrom = append(servedFrom, h)
+			return &peerServer{host: h, url: "http://10.0.0.1:8080/abc/k0s"}, nil
+		},
+	}
+	require.NoError(t, phase.Prepare(cfg))
+	phase.SetManager(&Manager{})
+
+	require.NoError(t, phase.Run(context.Background()))
+	assert.Equal(t, []*cluster.Host{seed}, servedFrom)
+
+	assert.Equal(t, 1, seedProvider.stageCalls)
+	assert.Empty(t, seedProvider.peerURLs)
+	assert.Equal(t, "/tmp/k0s-stage", seed.Metadata.K0sBinaryTempFile)
+
+	assert.Equal(t, 0, peerProvider.stageCalls)
+	assert.Equal(t, []string{"http://10.0.0.1:8080/abc/k0s"}, peerProvider.peerURLs)
+	assert.Equal(t, "/tmp/k0s-peer", peer.Metadata.K0sBinaryTempFile)
+
+	// a host that fails to get the binary from the seed gets a direct upload
+	assert.Equal(t, []string{"http://10.0.0.1:8080/abc/k0s"}, failingProvider.peerURLs)
+	assert.Equal(t, 1, failingProvider.stageCalls)
+	assert.Equal(t, "/tmp/k0s-stage", failing.Metadata.K0sBinaryTempFile)
+}
+
+func TestStageBinariesPeerDistributionServerFailure(t *testing.T) {
+	targetVersion := version.MustParse("v1.30.1+k0s.0")
+
+	var providers []*fakePeerStagingProvider
+	var hosts cluster.Hosts
+	for range 2 {
+		h := &cluster.Host{Configurer: &linuxcfg.Ubuntu{}, Metadata: cluster.HostMetadata{Arch: "amd64"}}
+		provider := &fakePeerStagingProvider{
+			fakeCachingBinaryProvider: &fakeCachingBinaryProvider{
+				fakeBinaryProvider: &fakeBinaryProvider{needsUpgrade: true, stagePath: "/tmp/k0s-stage", isUpload: true},
+				cacheKey:           "amd64/linux/v1.30.1+k0s.0",
+			},
+		}
+		h.SetK0sBinaryProvider(provider)
+		hosts = append(hosts, h)
+		providers = append(providers, provider)
+	}
+
+	cfg := &v1beta1.Cluster{
+		Spec: &cluster.Spec{
+			Hosts:   hosts,
+			K0s:     &cluster.K0s{Version: targetVersion},
+			Options: cluster.Options{BinaryDistribution: cluster.BinaryDistributionOption{Mode: cluster.BinaryDistributionPeer}},
+		},
+	}
+
+	phase := &StageBinaries{
+		peerSeed: func(*cluster.Host) bool { return true },
+		peerServe: func(context.Context, *cluster.Host, int) (*peerServer, error) {
+			return nil, errors.New("address already in use")
+		},
+	}
+	require.NoError(t, phase.Prepare(cfg))
+	phase.SetManager(&Manager{})
+
+	require.NoError(t, phase.Run(context.Background()))
+	for i, h := range hosts {
+		assert.Equal(t, 1, providers[i].stageCalls)
+		assert.Empty(t, providers[i].peerURLs)
+		assert.Equal(t, "/tmp/k0s-stage", h.Metadata.K0sBinaryTempFile)
+	}
+}
+
+func TestStageBinariesPeerDistributionDryRun(t *testing.T) {
+	targetVersion := version.MustParse("v1.30.1+k0s.0")
+
+	var providers []*fakePeerStagingProvider
+	var hosts cluster.Hosts
+	for range 2 {
+		h := &cluster.Host{Configurer: &linuxcfg.Ubuntu{}, Metadata: cluster.HostMetadata{Arch: "amd64"}}
+		provider := &fakePeerStagingProvider{
+			fakeCachingBinaryProvider: &fakeCachingBinaryProvider{
+				fakeBinaryProvider: &fakeBinaryProvider{needsUpgrade: true, stagePath: "/tmp/k0s-stage", isUpload: true},
+				cacheKey:           "amd64/linux/v1.30.1+k0s.0",
+			},
+		}
+		h.SetK0sBinaryProvider(provider)
+		hosts = append(hosts, h)
+		providers = append(providers, provider)
+	}
+
+	cfg := &v1beta1.Cluster{
+		Spec: &cluster.Spec{
+			Hosts:   hosts,
+			K0s:     &cluster.K0s{Version: targetVersion},
+			Options: cluster.Options{BinaryDistribution: cluster.BinaryDistributionOption{Mode: cluster.BinaryDistributionPeer}},
+		},
+	}
+
+	phase := &StageBinaries{
+		peerSeed: func(h *cluster.Host) bool { return h == hosts[0] },
+		peerServe: func(context.Context, *cluster.Host, int) (*peerServer, error) {
+			t.Fatal("peer server started in dry-run mode")
+			return nil, nil
+		},
+	}
+	require.NoError(t, phase.Prepare(cfg))
+	manager := &Manager{DryRun: true}
+	phase.SetManager(manager)
+
+	require.NoError(t, phase.DryRun())
+	for i, h := range hosts {
+		assert.Equal(t, 1, providers[i].stageCalls)
+		assert.Empty(t, providers[i].peerURLs)
+		assert.Equal(t, "/tmp/k0s-stage", h.Metadata.K0sBinaryTempFile)
+	}
+	assert.Equal(t, []string{"distribute the k0s binary via seed " + hosts[0].String()}, manager.dryMessages[hosts[1].String()])
+}
+
 func TestStageBinariesPropagatesCacheErrors(t *testing.T) {
 	targetVersion := version.MustParse("v1.30.2+k0s.0")
 
@@ -215,3 +422,18 @@ func (p *fakeCachingBinaryProvider) EnsureCached(_ context.Context) error {
 	p.cacheCalls++
 	return p.cacheErr
 }
+
+type fakePeerStagingProvider struct {
+	*fakeCachingBinaryProvider
+	peerPath string
+	peerErr  error
+	peerURLs []string
+}
+
+func (p *fakePeerStagingProvider) StageFromPeer(_ context.Context, url string) (string, error) {
+	p.peerURLs = append(p.peerURLs, url)
+	if p.peerErr != nil {
+		return "", p.peerErr
+	}
+	return p.peerPath, nil
+}
@@ -0,0 +1,170 @@
+package phase
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/k0sctl/pkg/node"
+	log "github.com/sirupsen/logrus"
+)
+
+// Service states reported in HostStatus
+const (
+	ServiceRunning      = "running"
+	ServiceStopped      = "stopped"
+	ServiceNotInstalled = "not installed"
+)
+
+// HostStatus is the health status of a single host
+type HostStatus struct {
+	Host             string `json:"host" yaml:"host"`
+	Role             string `json:"role" yaml:"role"`
+	InstalledVersion string `json:"installedVersion,omitempty" yaml:"installedVersion,omitempty"`
+	RunningVersion   string `json:"runningVersion,omitempty" yaml:"runningVersion,omitempty"`
+	Service          string `json:"service" yaml:"service"`
+	// NodeReady is the state of the kubernetes node Ready condition, nil for controllers without a worker
+	NodeReady *bool `json:"nodeReady,omitempty" yaml:"nodeReady,omitempty"`
+	// EtcdMember tells if the controller is an etcd member, nil when the cluster does not use the internal etcd
+	EtcdMember *bool `json:"etcdMember,omitempty" yaml:"etcdMember,omitempty"`
+	// ConfigMatches tells if the k0s configuration on the controller matches the configuration, nil for workers
+	ConfigMatches  *bool    `json:"configMatches,omitempty" yaml:"configMatches,omitempty"`
+	UpgradePending bool     `json:"upgradePending" yaml:"upgradePending"`
+	Problems       []string `json:"problems,omitempty" yaml:"problems,omitempty"`
+}
+
+// Degraded returns true when problems were found on the host
+func (s *HostStatus) Degraded() bool {
+	return len(s.Problems) > 0
+}
+
+// ClusterStatus collects the health status of the hosts. It expects the facts to have been
+// gathered by the GatherFacts and GatherK0sFacts phases.
+type ClusterStatus struct {
+	GenericPhase
+
+	// Hosts is populated with the status of each host in the order of the configuration
+	Hosts []HostStatus
+
+	mu sync.Mutex
+}
+
+// Title for the phase
+func (p *ClusterStatus) Title() string {
+	return "Gather cluster status"
+}
+
+// Degraded returns true when any of the hosts is degraded
+func (p *ClusterStatus) Degraded() bool {
+	for _, s := range p.Hosts {
+		if s.Degraded() {
+			return true
+		}
+	}
+	return false
+}
+
+// Run the phase
+func (p *ClusterStatus) Run(ctx context.Context) error {
+	configMatches := p.configMatches()
+
+	statuses := make(map[*cluster.Host]HostStatus, len(p.Config.Spec.Hosts))
+	err := p.parallelDo(ctx, p.Config.Spec.Hosts, func(ctx context.Context, h *cluster.Host) error {
+		s := p.hostStatus(ctx, h)
+		if h.IsController() {
+			if match, ok := configMatches[h]; ok {
+				s.ConfigMatches = &match
+				if !match {
+					s.Problems = append(s.Problems, "k0s configuration on the host differs from the configuration")
+				}
+			}
+		}
+		p.mu.Lock()
+		statuses[h] = s
+		p.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, h := range p.Config.Spec.Hosts {
+		p.Hosts = append(p.Hosts, statuses[h])
+	}
+	return nil
+}
+
+func (p *ClusterStatus) hostStatus(ctx context.Context, h *cluster.Host) HostStatus {
+	s := HostStatus{
+		Host:             h.String(),
+		Role:             h.Role,
+		InstalledVersion: h.Metadata.K0sBinaryVersion.String(),
+		RunningVersion:   h.Metadata.K0sRunningVersion.String(),
+		UpgradePending:   h.Metadata.NeedsUpgrade,
+		Service:          ServiceNotInstalled,
+	}
+
+	if h.Metadata.K0sBinaryVersion == nil {
+		s.Problems = append(s.Problems, "k0s is not installed")
+		return s
+	}
+
+	if svc, err := h.Sudo().Service(h.K0sServiceName()); err == nil {
+		if svc.IsRunning(ctx) {
+			s.Service = ServiceRunning
+		} else {
+			s.Service = ServiceStopped
+			s.Problems = append(s.Problems, "k0s service is not running")
+		}
+	} else {
+		log.Debugf("%s: failed to get k0s service: %s", h, err)
+		s.Problems = append(s.Problems, "k0s service is not installed")
+	}
+
+	if h.Metadata.K0sRunningVersion == nil {
+		s.Problems = append(s.Problems, "k0s is not running")
+		return s
+	}
+
+	if h.Role != "controller" {
+		ready := true
+		if err := node.KubeNodeReadyFunc(h)(ctx); err != nil {
+			log.Debugf("%s: node is not ready: %s", h, err)
+			ready = false
+			s.Problems = append(s.Problems, "node is not ready")
+		}
+		s.NodeReady = &ready
+	}
+
+	if h.IsController() && len(p.Config.Metadata.EtcdMembers) > 0 {
+		member := slices.Contains(p.Config.Metadata.EtcdMembers, h.PrivateAddress) || slices.Contains(p.Config.Metadata.EtcdMembers, h.Address())
+		s.EtcdMember = &member
+		if !member {
+			s.Problems = append(s.Problems, "controller is not an etcd member")
+		}
+	}
+
+	return s
+}
+
+// configMatches reports which of the controllers have a k0s configuration on disk that
+// matches the configuration
+func (p *ClusterStatus) configMatches() map[*cluster.Host]bool {
+	leader := p.Config.Spec.K0sLeader()
+	if leader == nil || leader.Metadata.K0sBinaryVersion == nil {
+		return nil
+	}
+
+	compared, changed, err := k0sConfigChanges(p.Config)
+	if err != nil {
+		log.Warnf("%s: failed to build the k0s configuration for comparison: %s", leader, err)
+		return nil
+	}
+
+	matches := make(map[*cluster.Host]bool, len(compared))
+	for _, h := range compared {
+		matches[h] = !slices.Contains(changed, h)
+	}
+	return matches
+}
@@ -0,0 +1,34 @@
+package phase
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterStatusDegraded(t *testing.T) {
+	ready := true
+	p := &ClusterStatus{Hosts: []HostStatus{
+		{Host: "10.0.0.1", Role: "controller", Service: ServiceRunning},
+		{Host: "10.0.0.2", Role: "worker", Service: ServiceRunning, NodeReady: &ready, UpgradePending: true},
+	}}
+	require.False(t, p.Degraded())
+
+	p.Hosts[1].Problems = append(p.Hosts[1].Problems, "node is not ready")
+	require.True(t, p.Hosts[1].Degraded())
+	require.True(t, p.Degraded())
+}
+
+func TestHostStatusJSON(t *testing.T) {
+	ready := false
+	data, err := json.Marshal(HostStatus{Host: "10.0.0.2", Role: "worker", Service: ServiceStopped, NodeReady: &ready})
+	require.NoError(t, err)
+
+	decoded := map[string]any{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, false, decoded["nodeReady"])
+	require.Equal(t, "stopped", decoded["service"])
+	require.NotContains(t, decoded, "etcdMember")
+	require.NotContains(t, decoded, "configMatches")
+}
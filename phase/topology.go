@@ -0,0 +1,87 @@
+package phase
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/rig/v2/cmd"
+	log "github.com/sirupsen/logrus"
+)
+
+// kubeNodeLabelList represents the parts of the output of `kubectl get nodes -o json` needed for
+// grouping the hosts by a node label
+type kubeNodeLabelList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+// parseNodeLabels returns the labels of the nodes in the `kubectl get nodes -o json` output by node name
+func parseNodeLabels(out string) (map[string]map[string]string, error) {
+	nodes := &kubeNodeLabelList{}
+	if err := json.Unmarshal([]byte(out), nodes); err != nil {
+		return nil, fmt.Errorf("decode kubectl get nodes output: %w", err)
+	}
+	labels := make(map[string]map[string]string, len(nodes.Items))
+	for _, n := range nodes.Items {
+		labels[n.Metadata.Name] = n.Metadata.Labels
+	}
+	return labels, nil
+}
+
+// hostLabelValue returns the value of the label from the host's labels in the configuration or
+// from the node's labels
+func hostLabelValue(h *cluster.Host, key string, nodeLabels map[string]map[string]string) string {
+	if v, ok := h.Labels[key]; ok {
+		return v
+	}
+	return nodeLabels[h.KubernetesNodeName()][key]
+}
+
+// groupHostsByLabel splits the hosts into groups by the value of the label
+func groupHostsByLabel(hosts cluster.Hosts, key string, nodeLabels map[string]map[string]string) []cluster.Hosts {
+	return hosts.GroupBy(func(h *cluster.Host) string {
+		return hostLabelValue(h, key, nodeLabels)
+	})
+}
+
+// workerGroups splits the workers into groups by the label in options.concurrency.groupBy. The
+// workers are in a single group when the option is not set.
+func workerGroups(config *cluster.Spec, leader *cluster.Host, hosts cluster.Hosts, dryRun bool) ([]cluster.Hosts, error) {
+	key := config.Options.Concurrency.GroupBy
+	if key == "" || len(hosts) == 0 {
+		return []cluster.Hosts{hosts}, nil
+	}
+
+	var nodeLabels map[string]map[string]string
+	needNodes := hosts.Find(func(h *cluster.Host) bool {
+		_, ok := h.Labels[key]
+		return !ok
+	}) != nil
+	if needNodes && leader != nil && leader.Metadata.K0sRunningVersion != nil {
+		out, err := leader.Sudo().ExecOutput(leader.Configurer.KubectlCmdf(leader, leader.K0sDataDir(), "get nodes -o json"), cmd.HideOutput())
+		if err == nil {
+			nodeLabels, err = parseNodeLabels(out)
+		}
+		if err != nil {
+			if !dryRun {
+				return nil, fmt.Errorf("get node labels for grouping the workers by %s: %w", key, err)
+			}
+			log.Warnf("%s: failed to get node labels for grouping the workers by %s: %v", leader, key, err)
+		}
+	}
+
+	groups := groupHostsByLabel(hosts, key, nodeLabels)
+	for _, g := range groups {
+		if v := hostLabelValue(g[0], key, nodeLabels); v != "" {
+			log.Infof("%d workers in group %s=%s", len(g), key, v)
+		} else {
+			log.Warnf("%d workers without the %s label are handled as a group of their own", len(g), key)
+		}
+	}
+	return groups, nil
+}
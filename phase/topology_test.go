@@ -0,0 +1,54 @@
+package phase
+
+import (
+	"testing"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+const zoneLabel = "topology.kubernetes.io/zone"
+
+func TestGroupHostsByLabel(t *testing.T) {
+	nodeLabels, err := parseNodeLabels(`{"items": [
+		{"metadata": {"name": "worker-1", "labels": {"topology.kubernetes.io/zone": "a"}}},
+		{"metadata": {"name": "worker-2", "labels": {"topology.kubernetes.io/zone": "b"}}},
+		{"metadata": {"name": "worker-3", "labels": {"topology.kubernetes.io/zone": "a"}}}
+	]}`)
+	require.NoError(t, err)
+
+	hosts := cluster.Hosts{
+		{Role: "worker", Metadata: cluster.HostMetadata{Hostname: "worker-1"}},
+		{Role: "worker", Metadata: cluster.HostMetadata{Hostname: "worker-2"}},
+		{Role: "worker", Metadata: cluster.HostMetadata{Hostname: "worker-3"}},
+		{Role: "worker", Metadata: cluster.HostMetadata{Hostname: "worker-4"}, Labels: map[string]string{zoneLabel: "b"}},
+		{Role: "worker", Metadata: cluster.HostMetadata{Hostname: "worker-5"}},
+		// the configured label takes precedence over the node label
+		{Role: "worker", Metadata: cluster.HostMetadata{Hostname: "worker-1"}, Labels: map[string]string{zoneLabel: "c"}},
+	}
+
+	groups := groupHostsByLabel(hosts, zoneLabel, nodeLabels)
+	require.Len(t, groups, 4)
+	require.Equal(t, cluster.Hosts{hosts[0], hosts[2]}, groups[0])
+	require.Equal(t, cluster.Hosts{hosts[1], hosts[3]}, groups[1])
+	require.Equal(t, cluster.Hosts{hosts[4]}, groups[2])
+	require.Equal(t, cluster.Hosts{hosts[5]}, groups[3])
+}
+
+func TestUpgradeWorkersBatchesByGroup(t *testing.T) {
+	hosts := make(cluster.Hosts, 6)
+	for i := range hosts {
+		hosts[i] = &cluster.Host{Role: "worker"}
+	}
+	p := &UpgradeWorkers{hosts: hosts}
+	p.Config = &v1beta1.Cluster{Spec: &cluster.Spec{Options: cluster.Options{Concurrency: cluster.ConcurrencyOption{Limit: 30, WorkerDisruptionPercent: 50}}}}
+	groups := []cluster.Hosts{hosts[:4], hosts[4:]}
+
+	batches := p.batches(groups, 0)
+	require.Equal(t, []cluster.Hosts{hosts[0:2], hosts[2:4], hosts[4:5], hosts[5:6]}, batches)
+
+	// the canaries are taken from the first group
+	batches = p.batches(groups, 1)
+	require.Equal(t, []cluster.Hosts{hosts[0:1], hosts[1:3], hosts[3:4], hosts[4:5], hosts[5:6]}, batches)
+}
@@ -0,0 +1,380 @@
+package phase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/k0sctl/pkg/k0s"
+	"github.com/k0sproject/k0sctl/pkg/retry"
+	"github.com/k0sproject/rig/v2/cmd"
+	"github.com/k0sproject/version"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// autopilotPlanName is the name of the plan, k0s autopilot only processes a plan with this name
+	autopilotPlanName = "autopilot"
+	// autopilotPlanCompleted is the state of a successfully completed plan
+	autopilotPlanCompleted = "Completed"
+	// autopilotSignalCompleted is the state of a successfully upgraded node
+	autopilotSignalCompleted = "SignalCompleted"
+)
+
+// autopilotPendingStates are the plan states of a plan that is still in progress, the other
+// states than these and autopilotPlanCompleted are errors
+var autopilotPendingStates = []string{"", "Schedulable", "SchedulableWait"}
+
+// autopilotPlan is a k0s autopilot upgrade plan
+type autopilotPlan struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		ID        string                 `json:"id"`
+		Timestamp string                 `json:"timestamp"`
+		Commands  []autopilotPlanCommand `json:"commands"`
+	} `json:"spec"`
+}
+
+type autopilotPlanCommand struct {
+	K0sUpdate *autopilotK0sUpdate `json:"k0supdate,omitempty"`
+}
+
+type autopilotK0sUpdate struct {
+	Version   string                       `json:"version"`
+	Platforms map[string]autopilotPlatform `json:"platforms"`
+	Targets   autopilotTargets             `json:"targets"`
+}
+
+type autopilotPlatform struct {
+	URL    string `json:"url"`
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+type autopilotTargets struct {
+	Controllers *autopilotTarget `json:"controllers,omitempty"`
+	Workers     *autopilotTarget `json:"workers,omitempty"`
+}
+
+type autopilotTarget struct {
+	Discovery struct {
+		Static struct {
+			Nodes []string `json:"nodes"`
+		} `json:"static"`
+	} `json:"discovery"`
+}
+
+func newAutopilotTarget(nodes []string) *autopilotTarget {
+	if len(nodes) == 0 {
+		return nil
+	}
+	t := &autopilotTarget{}
+	t.Discovery.Static.Nodes = nodes
+	return t
+}
+
+// autopilotPlanStatus represents the parts of the output of `kubectl get plan -o json` needed for
+// following the progress of the plan
+type autopilotPlanStatus struct {
+	Spec struct {
+		ID string `json:"id"`
+	} `json:"spec"`
+	Status struct {
+		State    string `json:"state"`
+		Commands []struct {
+			State     string `json:"state"`
+			K0sUpdate *struct {
+				Controllers []autopilotNodeStatus `json:"controllers"`
+				Workers     []autopilotNodeStatus `json:"workers"`
+			} `json:"k0supdate"`
+		} `json:"commands"`
+	} `json:"status"`
+}
+
+type autopilotNodeStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// nodes returns the states of the nodes in the plan by node name
+func (s *autopilotPlanStatus) nodes() map[string]string {
+	states := make(map[string]string)
+	for _, c := range s.Status.Commands {
+		if c.K0sUpdate == nil {
+			continue
+		}
+		for _, n := range slices.Concat(c.K0sUpdate.Controllers, c.K0sUpdate.Workers) {
+			states[n.Name] = n.State
+		}
+	}
+	return states
+}
+
+// buildAutopilotPlan returns an autopilot plan that upgrades the hosts to the version. The binary
+// URLs of the platforms are taken from the hosts' binary providers, which must download the
+// binary from an URL.
+func buildAutopilotPlan(ctx context.Context, id string, hosts cluster.Hosts, target *version.Version) (*autopilotPlan, error) {
+	update := &autopilotK0sUpdate{
+		Version:   target.String(),
+		Platforms: make(map[string]autopilotPlatform),
+	}
+	urlHosts := make(map[string]*cluster.Host)
+	var controllers, workers []string
+	for _, h := range hosts {
+		provider, err := h.K0sBinaryProvider(target)
+		if err != nil {
+			return nil, err
+		}
+		dl, ok := provider.(k0s.DownloadURLProvider)
+		if !ok {
+			return nil, fmt.Errorf("%s: the autopilot upgrade strategy requires the hosts to download k0s from an url, the host's binary provider %T does not", h, provider)
+		}
+		url, err := dl.DownloadURL()
+		if err != nil {
+			return nil, fmt.Errorf("%s: get k0s download url: %w", h, err)
+		}
+		osKind, err := h.OSKind()
+		if err != nil {
+			return nil, err
+		}
+		arch, err := h.Arch()
+		if err != nil {
+			return nil, err
+		}
+		platform := osKind + "-" + arch
+		if existing, ok := update.Platforms[platform]; ok {
+			if existing.URL != url {
+				return nil, fmt.Errorf("%s: the k0s download url %s differs from %s of %s, autopilot can only use one url per platform (%s)", h, url, existing.URL, urlHosts[platform], platform)
+			}
+		} else {
+			sum, err := dl.DownloadSha256(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("%s: get k0s checksum: %w", h, err)
+			}
+			update.Platforms[platform] = autopilotPlatform{URL: url, Sha256: sum}
+			urlHosts[platform] = h
+		}
+
+		if h.IsController() {
+			controllers = append(controllers, h.KubernetesNodeName())
+		} else {
+			workers = append(workers, h.KubernetesNodeName())
+		}
+	}
+	update.Targets.Controllers = newAutopilotTarget(controllers)
+	update.Targets.Workers = newAutopilotTarget(workers)
+
+	plan := &autopilotPlan{APIVersion: "autopilot.k0sproject.io/v1beta2", Kind: "Plan"}
+	plan.Metadata.Name = autopilotPlanName
+	plan.Spec.ID = id
+	plan.Spec.Timestamp = "now"
+	plan.Spec.Commands = []autopilotPlanCommand{{K0sUpdate: update}}
+	return plan, nil
+}
+
+// UpgradeAutopilot upgrades the hosts by applying a k0s autopilot plan and following its
+// progress, the hosts download and install the new k0s version by themselves
+type UpgradeAutopilot struct {
+	GenericPhase
+
+	hosts  cluster.Hosts
+	leader *cluster.Host
+}
+
+// Title for the phase
+func (p *UpgradeAutopilot) Title() string {
+	return "Upgrade hosts with autopilot"
+}
+
+// Prepare the phase
+func (p *UpgradeAutopilot) Prepare(config *v1beta1.Cluster) error {
+	p.Config = config
+	p.leader = p.Config.Spec.K0sLeader()
+	if !p.Config.Spec.Options.UpgradeStrategy.AutopilotEnabled() {
+		return nil
+	}
+	p.hosts = p.Config.Spec.Hosts.Filter(func(h *cluster.Host) bool {
+		return !h.Reset && h.Metadata.NeedsUpgrade
+	})
+	log.Debugf("UpgradeAutopilot phase prepared, %d hosts need upgrade", len(p.hosts))
+	return nil
+}
+
+// ShouldRun is true when the autopilot upgrade strategy is used and there are hosts that need to be upgraded
+func (p *UpgradeAutopilot) ShouldRun() bool {
+	return len(p.hosts) > 0
+}
+
+// Before runs "before upgrade" hooks for the hosts that need upgrade
+func (p *UpgradeAutopilot) Before() error {
+	return p.runHooks(context.Background(), "upgrade", "before", p.hosts...)
+}
+
+// After runs "after upgrade" hooks for the hosts that were upgraded
+func (p *UpgradeAutopilot) After() error {
+	return p.runHooks(context.Background(), "upgrade", "after", p.hosts...)
+}
+
+// Run the phase
+func (p *UpgradeAutopilot) Run(ctx context.Context) error {
+	target := p.Config.Spec.K0s.Version
+	plan, err := buildAutopilotPlan(ctx, fmt.Sprintf("k0sctl-%d", time.Now().Unix()), p.hosts, target)
+	if err != nil {
+		return err
+	}
+
+	if !p.IsWet() {
+		for _, h := range p.hosts {
+			p.PlanChange(h, PlanAction{Action: PlanActionUpgrade, Target: "k0s", Old: h.Metadata.K0sRunningVersion.String(), New: target.String()})
+		}
+		p.DryMsgf(p.leader, "apply an autopilot plan to upgrade %d hosts to k0s %s", len(p.hosts), target)
+		return nil
+	}
+
+	if err := p.applyPlan(ctx, plan); err != nil {
+		return err
+	}
+	log.Infof("%s: applied autopilot plan %s to upgrade %d hosts to k0s %s", p.leader, plan.Spec.ID, len(p.hosts), target)
+
+	if err := p.waitPlan(ctx, plan.Spec.ID); err != nil {
+		return err
+	}
+
+	for _, h := range p.hosts {
+		h.Metadata.K0sRunningVersion = target
+		h.Metadata.K0sBinaryVersion = target
+		h.Metadata.NeedsUpgrade = false
+	}
+	return nil
+}
+
+// applyPlan replaces the previous autopilot plan with the new one, autopilot does not process
+// changes to an existing plan
+func (p *UpgradeAutopilot) applyPlan(ctx context.Context, plan *autopilotPlan) error {
+	if err := p.leader.Sudo().Exec(p.leader.Configurer.KubectlCmdf(p.leader, p.leader.K0sDataDir(), "delete plans.autopilot.k0sproject.io %s --ignore-not-found", autopilotPlanName)); err != nil {
+		return fmt.Errorf("delete previous autopilot plan: %w", err)
+	}
+
+	// marshaling the plan can not fail
+	data, _ := json.Marshal(plan)
+	var stderr bytes.Buffer
+	proc := p.leader.Sudo().Proc(p.leader.Configurer.KubectlCmdf(p.leader, p.leader.K0sDataDir(), "apply -f -"))
+	proc.Stdin = bytes.NewReader(data)
+	proc.Stderr = &stderr
+	waiter, err := proc.Start(ctx)
+	if err != nil {
+		return fmt.Errorf("apply autopilot plan: %w", err)
+	}
+	if err := waiter.Wait(); err != nil {
+		return fmt.Errorf("apply autopilot plan: %w (stderr: %s)", err, stderr.String())
+	}
+	return nil
+}
+
+// waitPlan follows the progress of the plan until it is completed, fails or the plan timeout is
+// reached. The node state changes are logged and reported as host events.
+func (p *UpgradeAutopilot) waitPlan(ctx context.Context, id string) error {
+	hosts := make(map[string]*cluster.Host, len(p.hosts))
+	for _, h := range p.hosts {
+		hosts[h.KubernetesNodeName()] = h
+	}
+	progress := &autopilotProgress{states: make(map[string]string), started: make(map[string]time.Time)}
+
+	return retry.Timeout(ctx, p.Config.Spec.Options.UpgradeStrategy.PlanTimeout, func(_ context.Context) error {
+		out, err := p.leader.Sudo().ExecOutput(p.leader.Configurer.KubectlCmdf(p.leader, p.leader.K0sDataDir(), "get plans.autopilot.k0sproject.io %s -o json", autopilotPlanName), cmd.HideOutput())
+		if err != nil {
+			return fmt.Errorf("get autopilot plan status: %w", err)
+		}
+		status := &autopilotPlanStatus{}
+		if err := json.Unmarshal([]byte(out), status); err != nil {
+			return fmt.Errorf("decode autopilot plan status: %w", err)
+		}
+		if status.Spec.ID != id {
+			return errors.Join(retry.ErrAbort, fmt.Errorf("the autopilot plan was replaced by plan %s", status.Spec.ID))
+		}
+		for _, e := range progress.update(status) {
+			if h, ok := hosts[e.Host]; ok {
+				e.Host = h.String()
+			}
+			if e.Type == EventHostFinished && e.Error == "" {
+				log.Infof("%s: upgraded by autopilot", e.Host)
+			} else if e.Error != "" {
+				log.Errorf("%s: autopilot: %s", e.Host, e.Error)
+			} else {
+				log.Infof("%s: autopilot: %s", e.Host, e.Message)
+			}
+			p.manager.Emit(e)
+		}
+		return planResult(status)
+	})
+}
+
+// planResult returns nil when the plan is completed, an error to retry while the plan is in
+// progress and an error to abort when the plan has failed
+func planResult(status *autopilotPlanStatus) error {
+	state := status.Status.State
+	switch {
+	case state == autopilotPlanCompleted:
+		return nil
+	case slices.Contains(autopilotPendingStates, state):
+		return fmt.Errorf("autopilot plan is in state %q", state)
+	default:
+		var failed []string
+		for name, s := range status.nodes() {
+			if s != autopilotSignalCompleted {
+				failed = append(failed, name+": "+s)
+			}
+		}
+		slices.Sort(failed)
+		msg := fmt.Sprintf("autopilot plan failed with state %s", state)
+		if len(failed) > 0 {
+			msg += " (" + strings.Join(failed, ", ") + ")"
+		}
+		return errors.Join(retry.ErrAbort, errors.New(msg))
+	}
+}
+
+// autopilotProgress tracks the node states of an autopilot plan
+type autopilotProgress struct {
+	states  map[string]string
+	started map[string]time.Time
+}
+
+// update returns the events for the node state changes since the previous status, the events
+// have the node name as the host
+func (a *autopilotProgress) update(status *autopilotPlanStatus) []Event {
+	var events []Event
+	nodes := status.nodes()
+	for _, name := range slices.Sorted(maps.Keys(nodes)) {
+		state := nodes[name]
+		if a.states[name] == state {
+			continue
+		}
+		a.states[name] = state
+		if _, ok := a.started[name]; !ok {
+			a.started[name] = time.Now()
+			events = append(events, Event{Type: EventHostStarted, Host: name, Message: state})
+			continue
+		}
+		switch {
+		case state == autopilotSignalCompleted:
+			events = append(events, Event{Type: EventHostFinished, Host: name, Message: state, Duration: time.Since(a.started[name]).Seconds()})
+		case strings.Contains(state, "Fail"):
+			events = append(events, Event{Type: EventHostFinished, Host: name, Error: state, Duration: time.Since(a.started[name]).Seconds()})
+		default:
+			events = append(events, Event{Type: EventHostStarted, Host: name, Message: state})
+		}
+	}
+	return events
+}
@@ -0,0 +1,136 @@
+package phase
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	linuxcfg "github.com/k0sproject/k0sctl/configurer/linux"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/k0sctl/pkg/k0s/binprovider"
+	"github.com/k0sproject/k0sctl/pkg/retry"
+	"github.com/k0sproject/version"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDownloadProvider struct {
+	fakeBinaryProvider
+	url    string
+	sha256 string
+	err    error
+}
+
+func (p *fakeDownloadProvider) DownloadURL() (string, error) { return p.url, nil }
+
+func (p *fakeDownloadProvider) DownloadSha256(_ context.Context) (string, error) {
+	return p.sha256, p.err
+}
+
+func autopilotHost(role, name, arch, url string) *cluster.Host {
+	h := &cluster.Host{Role: role, Configurer: &linuxcfg.Ubuntu{}, Metadata: cluster.HostMetadata{Hostname: name, Arch: arch}}
+	h.SetK0sBinaryProvider(&fakeDownloadProvider{url: url, sha256: arch + "-sum"})
+	return h
+}
+
+func TestBuildAutopilotPlan(t *testing.T) {
+	target := version.MustParse("v1.34.1+k0s.0")
+	hosts := cluster.Hosts{
+		autopilotHost("controller", "controller-1", "amd64", "https://example.com/k0s-amd64"),
+		autopilotHost("controller+worker", "controller-2", "amd64", "https://example.com/k0s-amd64"),
+		autopilotHost("worker", "worker-1", "arm64", "https://example.com/k0s-arm64"),
+	}
+
+	ctx := context.Background()
+	plan, err := buildAutopilotPlan(ctx, "k0sctl-1", hosts, target)
+	require.NoError(t, err)
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"apiVersion": "autopilot.k0sproject.io/v1beta2",
+		"kind": "Plan",
+		"metadata": {"name": "autopilot"},
+		"spec": {
+			"id": "k0sctl-1",
+			"timestamp": "now",
+			"commands": [{"k0supdate": {
+				"version": "v1.34.1+k0s.0",
+				"platforms": {
+					"linux-amd64": {"url": "https://example.com/k0s-amd64", "sha256": "amd64-sum"},
+					"linux-arm64": {"url": "https://example.com/k0s-arm64", "sha256": "arm64-sum"}
+				},
+				"targets": {
+					"controllers": {"discovery": {"static": {"nodes": ["controller-1", "controller-2"]}}},
+					"workers": {"discovery": {"static": {"nodes": ["worker-1"]}}}
+				}
+			}}]
+		}
+	}`, string(data))
+
+	t.Run("conflicting urls", func(t *testing.T) {
+		hosts := append(hosts, autopilotHost("worker", "worker-2", "arm64", "https://mirror.example.com/k0s-arm64"))
+		_, err := buildAutopilotPlan(ctx, "k0sctl-1", hosts, target)
+		require.ErrorContains(t, err, "one url per platform")
+	})
+
+	t.Run("checksum unavailable", func(t *testing.T) {
+		h := autopilotHost("worker", "worker-2", "amd64", "https://example.com/k0s-amd64")
+		h.SetK0sBinaryProvider(&fakeDownloadProvider{url: "https://example.com/k0s-amd64", err: binprovider.ErrChecksumUnavailable})
+		_, err := buildAutopilotPlan(ctx, "k0sctl-1", cluster.Hosts{h}, target)
+		require.ErrorIs(t, err, binprovider.ErrChecksumUnavailable)
+	})
+
+	t.Run("provider without url", func(t *testing.T) {
+		h := &cluster.Host{Role: "worker", Configurer: &linuxcfg.Ubuntu{}, Metadata: cluster.HostMetadata{Hostname: "worker-3", Arch: "amd64"}}
+		h.SetK0sBinaryProvider(&fakeBinaryProvider{})
+		_, err := buildAutopilotPlan(ctx, "k0sctl-1", cluster.Hosts{h}, target)
+		require.ErrorContains(t, err, "download k0s from an url")
+	})
+}
+
+func autopilotStatus(t *testing.T, data string) *autopilotPlanStatus {
+	t.Helper()
+	status := &autopilotPlanStatus{}
+	require.NoError(t, json.Unmarshal([]byte(data), status))
+	return status
+}
+
+func TestAutopilotPlanProgress(t *testing.T) {
+	progress := &autopilotProgress{states: make(map[string]string), started: make(map[string]time.Time)}
+
+	status := autopilotStatus(t, `{"spec": {"id": "k0sctl-1"}, "status": {"state": "Schedulable", "commands": [{"state": "SchedulableWait", "k0supdate": {
+		"controllers": [{"name": "controller-1", "state": "SignalSent"}],
+		"workers": [{"name": "worker-1", "state": "SignalPending"}]
+	}}]}}`)
+	events := progress.update(status)
+	require.Len(t, events, 2)
+	require.Equal(t, Event{Type: EventHostStarted, Host: "controller-1", Message: "SignalSent"}, events[0])
+	require.Equal(t, EventHostStarted, events[1].Type)
+	require.Empty(t, progress.update(status), "unchanged states do not produce events")
+	err := planResult(status)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, retry.ErrAbort)
+
+	status = autopilotStatus(t, `{"spec": {"id": "k0sctl-1"}, "status": {"state": "Completed", "commands": [{"state": "Completed", "k0supdate": {
+		"controllers": [{"name": "controller-1", "state": "SignalCompleted"}],
+		"workers": [{"name": "worker-1", "state": "SignalCompleted"}]
+	}}]}}`)
+	events = progress.update(status)
+	require.Len(t, events, 2)
+	for _, e := range events {
+		require.Equal(t, EventHostFinished, e.Type)
+		require.Empty(t, e.Error)
+	}
+	require.NoError(t, planResult(status))
+
+	status = autopilotStatus(t, `{"spec": {"id": "k0sctl-1"}, "status": {"state": "ApplyFailed", "commands": [{"state": "ApplyFailed", "k0supdate": {
+		"controllers": [{"name": "controller-1", "state": "SignalCompleted"}],
+		"workers": [{"name": "worker-1", "state": "ApplyFailed"}]
+	}}]}}`)
+	events = progress.update(status)
+	require.Len(t, events, 1)
+	require.Equal(t, "ApplyFailed", events[0].Error)
+	err = planResult(status)
+	require.ErrorIs(t, err, retry.ErrAbort)
+	require.ErrorContains(t, err, "worker-1: ApplyFailed")
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
 	"github.com/k0sproject/k0sctl/pkg/node"
 	"github.com/k0sproject/k0sctl/pkg/retry"
+	"github.com/k0sproject/version"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -16,11 +17,17 @@ import (
 type UpgradeControllers struct {
 	GenericPhase
 
-	hosts cluster.Hosts
+	hosts    cluster.Hosts
+	rollback upgradeRollback
+	// hop is the intermediate version when the phase is run by UpgradePath
+	hop *version.Version
 }
 
 // Title for the phase
 func (p *UpgradeControllers) Title() string {
+	if p.hop != nil {
+		return "Upgrade controllers to " + p.hop.String()
+	}
 	return "Upgrade controllers"
 }
 
@@ -28,15 +35,28 @@ func (p *UpgradeControllers) Title() string {
 func (p *UpgradeControllers) Prepare(config *v1beta1.Cluster) error {
 	log.Debugf("UpgradeControllers phase prep starting")
 	p.Config = config
+	if p.Config.Spec.Options.UpgradeStrategy.AutopilotEnabled() {
+		log.Debugf("UpgradeControllers phase skipped, the controllers are upgraded by autopilot")
+		return nil
+	}
 	controllers := p.Config.Spec.Hosts.Controllers()
 	log.Debugf("%d controllers in total", len(controllers))
 	p.hosts = controllers.Filter(func(h *cluster.Host) bool {
+		if p.manager.HostDone(p.Title(), h) {
+			log.Infof("%s: already upgraded in a previous run", h)
+			return false
+		}
 		return !h.Reset && h.Metadata.NeedsUpgrade
 	})
 	log.Debugf("UpgradeControllers phase prepared, %d controllers needs upgrade", len(p.hosts))
 	return nil
 }
 
+// Resumable is true because the phase can be skipped when resuming an apply that already completed it
+func (p *UpgradeControllers) Resumable() bool {
+	return true
+}
+
 // ShouldRun is true when there are controllers that needs to be upgraded
 func (p *UpgradeControllers) ShouldRun() bool {
 	return len(p.hosts) > 0
@@ -58,8 +78,9 @@ func (p *UpgradeControllers) After() error {
 	return p.runHooks(context.Background(), "upgrade", "after", p.hosts...)
 }
 
-// CleanUp cleans up the environment override files on hosts
+// CleanUp rolls back the controller whose upgrade failed and cleans up the environment override files on hosts
 func (p *UpgradeControllers) CleanUp() {
+	p.rollback.rollback(context.Background(), p.manager)
 	for _, h := range p.hosts {
 		if len(h.Environment) > 0 {
 			if svc, err := h.Sudo().Service(h.K0sServiceName()); err != nil {
@@ -75,6 +96,9 @@ func (p *UpgradeControllers) CleanUp() {
 func (p *UpgradeControllers) Run(ctx context.Context) error {
 	for _, h := range p.hosts {
 		log.Infof("%s: starting upgrade", h)
+		if !p.IsWet() {
+			p.PlanChange(h, PlanAction{Action: PlanActionUpgrade, Target: "k0s", Old: h.Metadata.K0sRunningVersion.String(), New: p.Config.Spec.K0s.Version.String()})
+		}
 
 		if h.Metadata.K0sBinaryTempFile != "" && !h.FS().FileExist(h.Metadata.K0sBinaryTempFile) {
 			return fmt.Errorf("%s: k0s binary tempfile not found: %s", h, h.Metadata.K0sBinaryTempFile)
@@ -123,6 +147,13 @@ func (p *UpgradeControllers) Run(ctx context.Context) error {
 			return err
 		}
 
+		err = p.Wet(h, "keep the previous k0s binary and configuration for a rollback", func() error {
+			return p.rollback.keep(h)
+		})
+		if err != nil {
+			return err
+		}
+
 		if h.Metadata.K0sBinaryTempFile != "" {
 			log.Debugf("%s: update binary", h)
 			err = p.Wet(h, "replace k0s binary", func() error {
@@ -195,6 +226,7 @@ func (p *UpgradeControllers) Run(ctx context.Context) error {
 				return fmt.Errorf("controller did not reach ready state: %w", err)
 			}
 		}
+		p.rollback.done(h)
 
 		if t := p.Config.Spec.Options.EvictTaint; t.Enabled && t.ControllerWorkers && h.Role != "controller" {
 			leader := p.Config.Spec.K0sLeader()
@@ -211,6 +243,7 @@ func (p *UpgradeControllers) Run(ctx context.Context) error {
 		}
 
 		h.Metadata.K0sRunningVersion = p.Config.Spec.K0s.Version
+		p.manager.MarkHostDone(p.Title(), h)
 	}
 
 	return nil
@@ -0,0 +1,196 @@
+package phase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/k0sproject/k0sctl/integration/github"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/version"
+	log "github.com/sirupsen/logrus"
+)
+
+// Upgrade path modes
+const (
+	// UpgradePathStrict refuses upgrades that skip kubernetes minor versions
+	UpgradePathStrict = "strict"
+	// UpgradePathAuto upgrades through the latest release of each skipped minor version
+	UpgradePathAuto = "auto"
+)
+
+// minorVersion returns the major and minor version numbers of the version
+func minorVersion(v *version.Version) (int, int) {
+	s := v.Segments()
+	return s[0], s[1]
+}
+
+// skippedMinors returns the kubernetes minor versions between from and to, for example 29 and 30
+// for an upgrade from v1.28 to v1.31
+func skippedMinors(from, to *version.Version) []int {
+	fromMajor, fromMinor := minorVersion(from)
+	toMajor, toMinor := minorVersion(to)
+	if fromMajor != toMajor {
+		return nil
+	}
+	var minors []int
+	for m := fromMinor + 1; m < toMinor; m++ {
+		minors = append(minors, m)
+	}
+	return minors
+}
+
+// lowestRunningVersion returns the lowest k0s version running on the hosts or nil when k0s is not
+// running on any of them
+func lowestRunningVersion(hosts cluster.Hosts) *version.Version {
+	var lowest *version.Version
+	for _, h := range hosts {
+		if h.Reset || h.Metadata.K0sRunningVersion == nil {
+			continue
+		}
+		if lowest == nil || h.Metadata.K0sRunningVersion.LessThan(lowest) {
+			lowest = h.Metadata.K0sRunningVersion
+		}
+	}
+	return lowest
+}
+
+// intermediateVersions returns the latest release of each minor version between from and to
+func intermediateVersions(from, to *version.Version, releases version.Collection) ([]*version.Version, error) {
+	major, _ := minorVersion(to)
+	var hops []*version.Version
+	for _, minor := range skippedMinors(from, to) {
+		var latest *version.Version
+		for _, r := range releases {
+			if rMajor, rMinor := minorVersion(r); rMajor == major && rMinor == minor && (latest == nil || r.GreaterThan(latest)) {
+				latest = r
+			}
+		}
+		if latest == nil {
+			return nil, fmt.Errorf("no k0s release found for kubernetes %d.%d", major, minor)
+		}
+		hops = append(hops, latest)
+	}
+	return hops, nil
+}
+
+func formatMinors(major int, minors []int) string {
+	s := make([]string, len(minors))
+	for i, m := range minors {
+		s[i] = fmt.Sprintf("%d.%d", major, m)
+	}
+	return strings.Join(s, ", ")
+}
+
+// UpgradePath upgrades the cluster through the latest release of each kubernetes minor version
+// between the running and the configured version, so that the upgrade phases only need to
+// perform the last hop
+type UpgradePath struct {
+	GenericPhase
+	Mode    string
+	NoDrain bool
+	// Releases returns the k0s releases, defaults to the release list from github
+	Releases func() (version.Collection, error)
+
+	hops []*version.Version
+}
+
+// Title for the phase
+func (p *UpgradePath) Title() string {
+	return "Upgrade through intermediate versions"
+}
+
+// Prepare the phase
+func (p *UpgradePath) Prepare(config *v1beta1.Cluster) error {
+	p.Config = config
+	if p.Mode != UpgradePathAuto || p.Config.Spec.K0s.Version == nil {
+		return nil
+	}
+	from := lowestRunningVersion(p.Config.Spec.Hosts)
+	if from == nil || len(skippedMinors(from, p.Config.Spec.K0s.Version)) == 0 {
+		return nil
+	}
+
+	for _, h := range p.Config.Spec.Hosts {
+		if !h.Reset && h.Metadata.NeedsUpgrade && h.K0sBinaryPath != "" {
+			return fmt.Errorf("%s: can not upgrade through intermediate versions when k0sBinaryPath is set", h)
+		}
+	}
+
+	releases := p.Releases
+	if releases == nil {
+		releases = func() (version.Collection, error) {
+			return github.K0sReleases(p.Config.Spec.K0s.Version.IsPrerelease(), from)
+		}
+	}
+	list, err := releases()
+	if err != nil {
+		return fmt.Errorf("resolve the intermediate versions: %w", err)
+	}
+	hops, err := intermediateVersions(from, p.Config.Spec.K0s.Version, list)
+	if err != nil {
+		return err
+	}
+	p.hops = hops
+	return nil
+}
+
+// ShouldRun is true when the upgrade skips kubernetes minor versions and --upgrade-path auto is used
+func (p *UpgradePath) ShouldRun() bool {
+	return len(p.hops) > 0
+}
+
+// Run the phase
+func (p *UpgradePath) Run(ctx context.Context) error {
+	target := p.Config.Spec.K0s.Version
+	// the binaries of the target version have already been staged, they are put back in place
+	// for the upgrade phases that follow once the hops are done
+	type hostState struct {
+		needsUpgrade bool
+		tempFile     string
+	}
+	states := make(map[*cluster.Host]hostState, len(p.Config.Spec.Hosts))
+	for _, h := range p.Config.Spec.Hosts {
+		states[h] = hostState{needsUpgrade: h.Metadata.NeedsUpgrade, tempFile: h.Metadata.K0sBinaryTempFile}
+	}
+	defer func() {
+		p.Config.Spec.K0s.Version = target
+		for h, s := range states {
+			h.Metadata.NeedsUpgrade = s.needsUpgrade
+			h.Metadata.K0sBinaryTempFile = s.tempFile
+		}
+	}()
+
+	for _, hop := range p.hops {
+		log.Infof("upgrading to the intermediate version %s before %s", hop, target)
+		p.Config.Spec.K0s.Version = hop
+		hosts := p.Config.Spec.Hosts.Filter(func(h *cluster.Host) bool {
+			return !h.Reset && h.Metadata.K0sRunningVersion != nil && h.Metadata.K0sRunningVersion.LessThan(hop)
+		})
+		for _, h := range p.Config.Spec.Hosts {
+			h.Metadata.NeedsUpgrade = false
+		}
+		for _, h := range hosts {
+			h.Metadata.NeedsUpgrade = true
+			h.Metadata.K0sBinaryTempFile = ""
+		}
+
+		phases := Phases{
+			&StageBinaries{hop: hop},
+			&UpgradeControllers{hop: hop},
+			&UpgradeWorkers{NoDrain: p.NoDrain, hop: hop},
+			&UpgradeAutopilot{},
+		}
+		if err := p.manager.runPhases(ctx, phases); err != nil {
+			return fmt.Errorf("upgrade to %s: %w", hop, err)
+		}
+
+		if p.IsWet() {
+			for _, h := range hosts {
+				h.Metadata.K0sRunningVersion = hop
+			}
+		}
+	}
+	return nil
+}
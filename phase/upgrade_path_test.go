@@ -0,0 +1,72 @@
+package phase
+
+import (
+	"testing"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/version"
+	"github.com/stretchr/testify/require"
+)
+
+func upgradePathConfig(target string, running ...string) *v1beta1.Cluster {
+	config := &v1beta1.Cluster{Spec: &cluster.Spec{K0s: &cluster.K0s{Version: version.MustParse(target)}}}
+	for _, v := range running {
+		config.Spec.Hosts = append(config.Spec.Hosts, &cluster.Host{Role: "controller", Metadata: cluster.HostMetadata{K0sRunningVersion: version.MustParse(v), NeedsUpgrade: true}})
+	}
+	return config
+}
+
+func TestSkippedMinors(t *testing.T) {
+	require.Equal(t, []int{29, 30}, skippedMinors(version.MustParse("v1.28.3+k0s.0"), version.MustParse("v1.31.1+k0s.0")))
+	require.Empty(t, skippedMinors(version.MustParse("v1.28.3+k0s.0"), version.MustParse("v1.29.1+k0s.0")))
+	require.Empty(t, skippedMinors(version.MustParse("v1.28.3+k0s.0"), version.MustParse("v1.28.4+k0s.0")))
+}
+
+func TestIntermediateVersions(t *testing.T) {
+	releases, err := version.NewCollection("v1.28.3+k0s.0", "v1.29.1+k0s.0", "v1.29.4+k0s.1", "v1.29.4+k0s.0", "v1.30.2+k0s.0", "v1.31.1+k0s.0")
+	require.NoError(t, err)
+
+	hops, err := intermediateVersions(version.MustParse("v1.28.3+k0s.0"), version.MustParse("v1.31.1+k0s.0"), releases)
+	require.NoError(t, err)
+	require.Len(t, hops, 2)
+	require.Equal(t, "v1.29.4+k0s.1", hops[0].String())
+	require.Equal(t, "v1.30.2+k0s.0", hops[1].String())
+
+	_, err = intermediateVersions(version.MustParse("v1.31.1+k0s.0"), version.MustParse("v1.33.1+k0s.0"), releases)
+	require.ErrorContains(t, err, "kubernetes 1.32")
+}
+
+func TestValidateUpgradePath(t *testing.T) {
+	p := &ValidateFacts{GenericPhase: GenericPhase{Config: upgradePathConfig("v1.31.1+k0s.0", "v1.29.2+k0s.0", "v1.28.3+k0s.0")}}
+	require.ErrorContains(t, p.validateUpgradePath(), "(1.29, 1.30)")
+
+	p.UpgradePath = UpgradePathAuto
+	require.NoError(t, p.validateUpgradePath())
+
+	p = &ValidateFacts{GenericPhase: GenericPhase{Config: upgradePathConfig("v1.29.1+k0s.0", "v1.28.3+k0s.0")}}
+	require.NoError(t, p.validateUpgradePath())
+}
+
+func TestUpgradePathPrepare(t *testing.T) {
+	releases := func() (version.Collection, error) {
+		return version.NewCollection("v1.29.4+k0s.0", "v1.30.2+k0s.0", "v1.31.1+k0s.0")
+	}
+
+	p := &UpgradePath{Mode: UpgradePathAuto, Releases: releases}
+	require.NoError(t, p.Prepare(upgradePathConfig("v1.31.1+k0s.0", "v1.28.3+k0s.0")))
+	require.True(t, p.ShouldRun())
+	require.Len(t, p.hops, 2)
+
+	p = &UpgradePath{Mode: UpgradePathStrict, Releases: releases}
+	require.NoError(t, p.Prepare(upgradePathConfig("v1.31.1+k0s.0", "v1.28.3+k0s.0")))
+	require.False(t, p.ShouldRun())
+
+	p = &UpgradePath{Mode: UpgradePathAuto, Releases: releases}
+	require.NoError(t, p.Prepare(upgradePathConfig("v1.31.1+k0s.0", "v1.30.3+k0s.0")))
+	require.False(t, p.ShouldRun())
+
+	config := upgradePathConfig("v1.31.1+k0s.0", "v1.28.3+k0s.0")
+	config.Spec.Hosts[0].K0sBinaryPath = "/tmp/k0s"
+	require.ErrorContains(t, (&UpgradePath{Mode: UpgradePathAuto, Releases: releases}).Prepare(config), "k0sBinaryPath")
+}
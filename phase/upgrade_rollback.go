@@ -0,0 +1,249 @@
+package phase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/k0sctl/pkg/node"
+	"github.com/k0sproject/k0sctl/pkg/retry"
+	"github.com/k0sproject/rig/v2/cmd"
+	"github.com/k0sproject/rig/v2/remotefs"
+	"github.com/k0sproject/version"
+	log "github.com/sirupsen/logrus"
+)
+
+// rollbackSuffix is appended to the paths of the previous k0s binary and configuration that are
+// kept on the host during an upgrade
+const rollbackSuffix = ".k0sctl-rollback"
+
+// rollbackState is what is needed for restoring a host to the state before the upgrade
+type rollbackState struct {
+	// binary is the path of the previous k0s binary, empty when the binary was not replaced
+	binary string
+	// config is the path of the previous k0s configuration, empty when the host had none
+	config string
+	// version is the version of the previous k0s binary
+	version *version.Version
+}
+
+// rollbackHost is the access to a host that the rollback needs
+type rollbackHost interface {
+	// sudoFS returns the file system of the host with elevated privileges
+	sudoFS() remotefs.FS
+	// stopK0s stops the k0s service and waits for it to stop
+	stopK0s(ctx context.Context) error
+	// startK0s starts the k0s service and waits for it to start
+	startK0s(ctx context.Context) error
+	// reinstallK0s reinstalls the k0s service with --force
+	reinstallK0s() error
+}
+
+// remoteRollbackHost accesses the host over its rig connection
+type remoteRollbackHost struct {
+	*cluster.Host
+}
+
+func (h remoteRollbackHost) sudoFS() remotefs.FS {
+	return h.Sudo().FS()
+}
+
+func (h remoteRollbackHost) stopK0s(ctx context.Context) error {
+	svc, err := h.Sudo().Service(h.K0sServiceName())
+	if err != nil {
+		return fmt.Errorf("get service %s: %w", h.K0sServiceName(), err)
+	}
+	if err := svc.Stop(ctx); err != nil {
+		log.Debugf("%s: failed to stop k0s service: %v", h, err)
+	}
+	if err := retry.WithDefaultTimeout(ctx, node.ServiceStoppedFunc(h.Host, h.K0sServiceName())); err != nil {
+		return fmt.Errorf("wait for k0s service stop: %w", err)
+	}
+	return nil
+}
+
+func (h remoteRollbackHost) startK0s(ctx context.Context) error {
+	svc, err := h.Sudo().Service(h.K0sServiceName())
+	if err != nil {
+		return fmt.Errorf("get service %s: %w", h.K0sServiceName(), err)
+	}
+	if err := svc.Start(ctx); err != nil {
+		return fmt.Errorf("start k0s service: %w", err)
+	}
+	if err := retry.WithDefaultTimeout(ctx, node.ServiceRunningFunc(h.Host, h.K0sServiceName())); err != nil {
+		return fmt.Errorf("wait for k0s service start: %w", err)
+	}
+	return nil
+}
+
+func (h remoteRollbackHost) reinstallK0s() error {
+	installFlags, err := h.K0sInstallFlags()
+	if err != nil {
+		return err
+	}
+	// the --force is added to a copy to leave the install flags of the host as they are
+	flags := slices.Clone(installFlags)
+	if h.Metadata.K0sBinaryVersion == nil || !h.Metadata.K0sBinaryVersion.LessThan(cluster.K0sForceFlagSince) {
+		flags.AddOrReplace("--force")
+	}
+	var opts []cmd.ExecOption
+	if h.IsWindows() {
+		opts = append(opts, cmd.AllowWinStderr())
+	}
+	if err := h.Sudo().Exec(h.Configurer.K0sCmdf("install %s %s", h.K0sRole(), flags.Join(h.FS())), opts...); err != nil {
+		return fmt.Errorf("reinstall k0s service: %w", err)
+	}
+	return nil
+}
+
+// upgradeRollback keeps the previous k0s binary and configuration on the hosts during an upgrade
+// so that a host whose upgrade fails can be restored to the previous version. The hosts that are
+// still tracked when the phase fails are rolled back in the phase's CleanUp.
+type upgradeRollback struct {
+	mu    sync.Mutex
+	hosts map[*cluster.Host]*rollbackState
+	// remote returns the access to a host, defaults to the rig connection of the host
+	remote func(*cluster.Host) rollbackHost
+}
+
+func (r *upgradeRollback) host(h *cluster.Host) rollbackHost {
+	if r.remote != nil {
+		return r.remote(h)
+	}
+	return remoteRollbackHost{h}
+}
+
+// keep moves the current k0s binary aside when it is going to be replaced and writes the
+// previous k0s configuration next to the current one when a new configuration is going to be
+// written
+func (r *upgradeRollback) keep(h *cluster.Host) error {
+	fsys := r.host(h).sudoFS()
+	state := &rollbackState{version: h.Metadata.K0sBinaryVersion}
+
+	if h.Metadata.K0sBinaryTempFile != "" && fsys.FileExist(h.K0sInstallLocation()) {
+		state.binary = h.K0sInstallLocation() + rollbackSuffix
+		log.Debugf("%s: keeping the previous k0s binary as %s", h, state.binary)
+		if err := fsys.Rename(h.K0sInstallLocation(), state.binary); err != nil {
+			return fmt.Errorf("keep the previous k0s binary: %w", err)
+		}
+	}
+
+	if h.Metadata.K0sExistingConfig != "" && h.Metadata.K0sNewConfig != "" && h.Metadata.K0sExistingConfig != h.Metadata.K0sNewConfig {
+		state.config = h.K0sConfigPath() + rollbackSuffix
+		log.Debugf("%s: keeping the previous k0s configuration as %s", h, state.config)
+		if err := fsys.WriteFile(state.config, []byte(h.Metadata.K0sExistingConfig), 0o600); err != nil {
+			return errors.Join(fmt.Errorf("keep the previous k0s configuration: %w", err), r.restoreBinary(h, state))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.hosts == nil {
+		r.hosts = make(map[*cluster.Host]*rollbackState)
+	}
+	r.hosts[h] = state
+	return nil
+}
+
+// done removes the kept files of a successfully upgraded host
+func (r *upgradeRollback) done(h *cluster.Host) {
+	r.mu.Lock()
+	state, ok := r.hosts[h]
+	delete(r.hosts, h)
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	for _, path := range []string{state.binary, state.config} {
+		if path == "" {
+			continue
+		}
+		if err := r.host(h).sudoFS().Remove(path); err != nil {
+			log.Warnf("%s: failed to remove %s: %v", h, path, err)
+		}
+	}
+}
+
+// pending returns the hosts that have not been marked done
+func (r *upgradeRollback) pending() cluster.Hosts {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hosts := make(cluster.Hosts, 0, len(r.hosts))
+	for h := range r.hosts {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// rollback restores the previous k0s binary and configuration on the hosts whose upgrade did not
+// finish, reinstalls and restarts the k0s service and reports the outcome
+func (r *upgradeRollback) rollback(ctx context.Context, manager *Manager) {
+	_ = r.pending().ParallelEach(ctx, func(ctx context.Context, h *cluster.Host) error {
+		r.mu.Lock()
+		state := r.hosts[h]
+		delete(r.hosts, h)
+		r.mu.Unlock()
+
+		e := Event{Type: EventHostRolledBack, Host: h.String()}
+		log.Warnf("%s: rolling back the failed upgrade to k0s %s", h, state.version)
+		if err := r.restore(ctx, h, state); err != nil {
+			log.Errorf("%s: rollback failed, the host needs manual recovery: %v", h, err)
+			e.Error = err.Error()
+		} else {
+			log.Warnf("%s: rolled back to k0s %s", h, state.version)
+			e.Message = fmt.Sprintf("rolled back to k0s %s", state.version)
+		}
+		manager.Emit(e)
+		return nil
+	})
+}
+
+// restore puts the previous binary and configuration back in place and starts the previous k0s
+func (r *upgradeRollback) restore(ctx context.Context, h *cluster.Host, state *rollbackState) error {
+	remote := r.host(h)
+	if err := remote.stopK0s(ctx); err != nil {
+		return err
+	}
+
+	if err := r.restoreBinary(h, state); err != nil {
+		return err
+	}
+	if state.config != "" {
+		log.Debugf("%s: restoring the previous k0s configuration", h)
+		if err := remote.sudoFS().Rename(state.config, h.K0sConfigPath()); err != nil {
+			return fmt.Errorf("restore the previous k0s configuration: %w", err)
+		}
+		h.Metadata.K0sNewConfig = h.Metadata.K0sExistingConfig
+	}
+
+	if err := remote.reinstallK0s(); err != nil {
+		return err
+	}
+	if err := remote.startK0s(ctx); err != nil {
+		return err
+	}
+	h.Metadata.K0sRunningVersion = state.version
+	return nil
+}
+
+// restoreBinary moves the previous k0s binary back in place
+func (r *upgradeRollback) restoreBinary(h *cluster.Host, state *rollbackState) error {
+	if state.binary == "" {
+		return nil
+	}
+	log.Debugf("%s: restoring the previous k0s binary", h)
+	fsys := r.host(h).sudoFS()
+	if fsys.FileExist(h.K0sInstallLocation()) {
+		if err := fsys.Remove(h.K0sInstallLocation()); err != nil {
+			return fmt.Errorf("remove the new k0s binary: %w", err)
+		}
+	}
+	if err := fsys.Rename(state.binary, h.K0sInstallLocation()); err != nil {
+		return fmt.Errorf("restore the previous k0s binary: %w", err)
+	}
+	h.Metadata.K0sBinaryVersion = state.version
+	return nil
+}
@@ -0,0 +1,183 @@
+package phase
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	linuxcfg "github.com/k0sproject/k0sctl/configurer/linux"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	"github.com/k0sproject/rig/v2/remotefs"
+	"github.com/k0sproject/version"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRollbackFS struct {
+	remotefs.FS
+	files map[string]string
+}
+
+func (f *fakeRollbackFS) FileExist(path string) bool {
+	_, ok := f.files[path]
+	return ok
+}
+
+func (f *fakeRollbackFS) Rename(oldpath, newpath string) error {
+	data, ok := f.files[oldpath]
+	if !ok {
+		return fs.ErrNotExist
+	}
+	delete(f.files, oldpath)
+	f.files[newpath] = data
+	return nil
+}
+
+func (f *fakeRollbackFS) Remove(path string) error {
+	if _, ok := f.files[path]; !ok {
+		return fs.ErrNotExist
+	}
+	delete(f.files, path)
+	return nil
+}
+
+func (f *fakeRollbackFS) WriteFile(path string, data []byte, _ fs.FileMode) error {
+	f.files[path] = string(data)
+	return nil
+}
+
+type fakeRollbackHost struct {
+	fs      *fakeRollbackFS
+	calls   []string
+	running bool
+}
+
+func (h *fakeRollbackHost) sudoFS() remotefs.FS { return h.fs }
+
+func (h *fakeRollbackHost) stopK0s(_ context.Context) error {
+	h.calls = append(h.calls, "stop")
+	h.running = false
+	return nil
+}
+
+func (h *fakeRollbackHost) startK0s(_ context.Context) error {
+	h.calls = append(h.calls, "start")
+	h.running = true
+	return nil
+}
+
+func (h *fakeRollbackHost) reinstallK0s() error {
+	h.calls = append(h.calls, "install")
+	return nil
+}
+
+func newFakeRollback(files map[string]string) (*upgradeRollback, *fakeRollbackHost) {
+	remote := &fakeRollbackHost{fs: &fakeRollbackFS{files: files}, running: true}
+	return &upgradeRollback{remote: func(*cluster.Host) rollbackHost { return remote }}, remote
+}
+
+func TestUpgradeRollbackTracksUnfinishedHosts(t *testing.T) {
+	previous := version.MustParse("v1.33.1+k0s.0")
+	// the hosts have no binary to replace and no configuration change, so nothing is written to them
+	h1 := &cluster.Host{Role: "worker", Metadata: cluster.HostMetadata{K0sBinaryVersion: previous}}
+	h2 := &cluster.Host{Role: "worker", Metadata: cluster.HostMetadata{K0sBinaryVersion: previous, K0sExistingConfig: "a", K0sNewConfig: "a"}}
+
+	r, remote := newFakeRollback(map[string]string{})
+	require.Empty(t, r.pending())
+	require.NoError(t, r.keep(h1))
+	require.NoError(t, r.keep(h2))
+	require.ElementsMatch(t, cluster.Hosts{h1, h2}, r.pending())
+	require.Equal(t, previous, r.hosts[h1].version)
+	require.Empty(t, r.hosts[h2].binary)
+	require.Empty(t, r.hosts[h2].config)
+	require.Empty(t, remote.fs.files)
+
+	r.done(h1)
+	require.Equal(t, cluster.Hosts{h2}, r.pending())
+	// marking an untracked host done is a no-op
+	r.done(h1)
+	require.Equal(t, cluster.Hosts{h2}, r.pending())
+
+	r.done(h2)
+	require.Empty(t, r.pending())
+	// nothing to roll back
+	r.rollback(context.Background(), nil)
+	require.Empty(t, remote.calls)
+}
+
+func TestUpgradeRollbackKeepsNoConfigWithoutNewConfig(t *testing.T) {
+	h := &cluster.Host{Role: "controller", Configurer: &linuxcfg.Ubuntu{}, Metadata: cluster.HostMetadata{K0sExistingConfig: "old"}}
+	r, remote := newFakeRollback(map[string]string{})
+
+	require.NoError(t, r.keep(h))
+	require.Empty(t, r.hosts[h].config)
+	require.Empty(t, remote.fs.files)
+}
+
+func TestUpgradeRollbackRestore(t *testing.T) {
+	previous := version.MustParse("v1.33.1+k0s.0")
+	h := &cluster.Host{
+		Role:         "controller",
+		Configurer:   &linuxcfg.Ubuntu{},
+		InstallFlags: cluster.Flags{"--debug"},
+		Metadata: cluster.HostMetadata{
+			K0sBinaryVersion:  previous,
+			K0sRunningVersion: previous,
+			K0sBinaryTempFile: "/tmp/k0s-new",
+			K0sExistingConfig: "old",
+			K0sNewConfig:      "new",
+		},
+	}
+	binary := h.K0sInstallLocation()
+	config := h.K0sConfigPath()
+	r, remote := newFakeRollback(map[string]string{binary: "k0s v1.33.1", config: "old"})
+
+	require.NoError(t, r.keep(h))
+	require.Equal(t, map[string]string{
+		binary + rollbackSuffix: "k0s v1.33.1",
+		config:                  "old",
+		config + rollbackSuffix: "old",
+	}, remote.fs.files)
+
+	// the upgrade replaces the binary and the configuration and then fails
+	remote.fs.files[binary] = "k0s v1.34.1"
+	remote.fs.files[config] = "new"
+	h.Metadata.K0sBinaryVersion = version.MustParse("v1.34.1+k0s.0")
+	h.Metadata.K0sRunningVersion = nil
+
+	recorder := &eventRecorder{}
+	manager := &Manager{}
+	manager.AddObserver(recorder)
+	r.rollback(context.Background(), manager)
+
+	require.Equal(t, []string{"stop", "install", "start"}, remote.calls)
+	require.True(t, remote.running)
+	require.Equal(t, map[string]string{binary: "k0s v1.33.1", config: "old"}, remote.fs.files)
+	require.Equal(t, previous, h.Metadata.K0sBinaryVersion)
+	require.Equal(t, previous, h.Metadata.K0sRunningVersion)
+	require.Equal(t, "old", h.Metadata.K0sNewConfig)
+	require.Equal(t, cluster.Flags{"--debug"}, h.InstallFlags)
+	require.Empty(t, r.pending())
+
+	require.Len(t, recorder.events, 1)
+	require.Equal(t, EventHostRolledBack, recorder.events[0].Type)
+	require.Empty(t, recorder.events[0].Error)
+	require.Equal(t, "rolled back to k0s v1.33.1+k0s.0", recorder.events[0].Message)
+}
+
+func TestUpgradeRollbackRestoreFailure(t *testing.T) {
+	h := &cluster.Host{Role: "worker", Configurer: &linuxcfg.Ubuntu{}, Metadata: cluster.HostMetadata{K0sBinaryTempFile: "/tmp/k0s-new"}}
+	r, remote := newFakeRollback(map[string]string{h.K0sInstallLocation(): "k0s"})
+	require.NoError(t, r.keep(h))
+
+	// the kept binary has gone missing
+	delete(remote.fs.files, h.K0sInstallLocation()+rollbackSuffix)
+
+	recorder := &eventRecorder{}
+	manager := &Manager{}
+	manager.AddObserver(recorder)
+	r.rollback(context.Background(), manager)
+
+	require.Equal(t, []string{"stop"}, remote.calls)
+	require.Len(t, recorder.events, 1)
+	require.Contains(t, recorder.events[0].Error, fs.ErrNotExist.Error())
+}
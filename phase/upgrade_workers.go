@@ -10,6 +10,7 @@ import (
 	"github.com/k0sproject/k0sctl/pkg/node"
 	"github.com/k0sproject/k0sctl/pkg/retry"
 	"github.com/k0sproject/rig/v2/cmd"
+	"github.com/k0sproject/version"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,12 +20,18 @@ type UpgradeWorkers struct {
 
 	NoDrain bool
 
-	hosts  cluster.Hosts
-	leader *cluster.Host
+	hosts    cluster.Hosts
+	leader   *cluster.Host
+	rollback upgradeRollback
+	// hop is the intermediate version when the phase is run by UpgradePath
+	hop *version.Version
 }
 
 // Title for the phase
 func (p *UpgradeWorkers) Title() string {
+	if p.hop != nil {
+		return "Upgrade workers to " + p.hop.String()
+	}
 	return "Upgrade workers"
 }
 
@@ -32,9 +39,17 @@ func (p *UpgradeWorkers) Title() string {
 func (p *UpgradeWorkers) Prepare(config *v1beta1.Cluster) error {
 	p.Config = config
 	p.leader = p.Config.Spec.K0sLeader()
+	if p.Config.Spec.Options.UpgradeStrategy.AutopilotEnabled() {
+		log.Debugf("UpgradeWorkers phase skipped, the workers are upgraded by autopilot")
+		return nil
+	}
 	workers := p.Config.Spec.Hosts.Workers()
 	log.Debugf("%d workers in total", len(workers))
 	p.hosts = workers.Filter(func(h *cluster.Host) bool {
+		if p.manager.HostDone(p.Title(), h) {
+			log.Infof("%s: already upgraded in a previous run", h)
+			return false
+		}
 		return !h.Reset && h.Metadata.NeedsUpgrade
 	})
 	err := p.parallelDo(context.Background(), p.hosts, func(_ context.Context, h *cluster.Host) error {
@@ -51,6 +66,11 @@ func (p *UpgradeWorkers) Prepare(config *v1beta1.Cluster) error {
 	return nil
 }
 
+// Resumable is true because the phase can be skipped when resuming an apply that already completed it
+func (p *UpgradeWorkers) Resumable() bool {
+	return true
+}
+
 // ShouldRun is true when there are workers that needs to be upgraded
 func (p *UpgradeWorkers) ShouldRun() bool {
 	return len(p.hosts) > 0
@@ -72,11 +92,12 @@ func (p *UpgradeWorkers) After() error {
 	return p.runHooks(context.Background(), "upgrade", "after", p.hosts...)
 }
 
-// CleanUp cleans up the environment override files on hosts
+// CleanUp rolls back the workers whose upgrade failed and cleans up the environment override files on hosts
 func (p *UpgradeWorkers) CleanUp() {
 	if !p.IsWet() {
 		return
 	}
+	p.rollback.rollback(context.Background(), p.manager)
 	_ = p.parallelDo(context.Background(), p.hosts, func(_ context.Context, h *cluster.Host) error {
 		if len(h.Environment) > 0 {
 			if svc, err := h.Sudo().Service(h.K0sServiceName()); err != nil {
@@ -92,22 +113,115 @@ func (p *UpgradeWorkers) CleanUp() {
 
 // Run the phase
 func (p *UpgradeWorkers) Run(ctx context.Context) error {
-	// Upgrade worker hosts parallelly in 10% chunks
-	concurrentUpgrades := int(math.Floor(float64(len(p.hosts)) * float64(p.Config.Spec.Options.Concurrency.WorkerDisruptionPercent/100)))
-	if concurrentUpgrades == 0 {
-		concurrentUpgrades = 1
+	groups, err := workerGroups(p.Config.Spec, p.leader, p.hosts, !p.IsWet())
+	if err != nil {
+		return err
 	}
-	concurrentUpgrades = min(concurrentUpgrades, p.Config.Spec.Options.Concurrency.Limit)
 
-	log.Infof("Upgrading max %d workers in parallel", concurrentUpgrades)
-	return p.hosts.BatchedParallelEach(ctx, concurrentUpgrades,
+	strategy := p.Config.Spec.Options.UpgradeStrategy
+	canary := min(strategy.Canary, len(p.hosts))
+	batches := p.batches(groups, canary)
+	steps := p.withHostEvents([]func(context.Context, *cluster.Host) error{
 		p.start,
 		p.cordonWorker,
 		p.drainWorker,
 		p.upgradeWorker,
 		p.uncordonWorker,
 		p.finish,
-	)
+	})
+
+	if canary > 0 {
+		log.Infof("Upgrading %d canary workers before the others", canary)
+	}
+	for _, group := range groups {
+		log.Infof("Upgrading max %d workers in parallel", p.batchSize(len(group)))
+	}
+	for i, batch := range batches {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("error from context: %w", err)
+		}
+		if i > 0 {
+			if err := p.gate(ctx, i == 1 && canary > 0); err != nil {
+				return err
+			}
+		}
+		if err := batch.ParallelEach(ctx, steps...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchSize returns the number of workers in a group of the given size to upgrade in parallel
+func (p *UpgradeWorkers) batchSize(groupSize int) int {
+	// Upgrade worker hosts parallelly in chunks of workerDisruptionPercent of the group
+	size := int(math.Floor(float64(groupSize) * float64(p.Config.Spec.Options.Concurrency.WorkerDisruptionPercent) / 100))
+	if size == 0 {
+		size = 1
+	}
+	if limit := p.Config.Spec.Options.Concurrency.Limit; limit > 0 {
+		size = min(size, limit)
+	}
+	return size
+}
+
+// batches splits the groups of hosts into the upgrade batches. The canaries are the first hosts
+// of the first groups and they are in a batch of their own. The batches of the rest of the hosts
+// do not span multiple groups and their size is relative to the size of the group.
+func (p *UpgradeWorkers) batches(groups []cluster.Hosts, canary int) []cluster.Hosts {
+	var batches []cluster.Hosts
+	if canary > 0 {
+		batches = append(batches, cluster.Hosts{})
+	}
+	for _, group := range groups {
+		size := p.batchSize(len(group))
+		hosts := group
+		if taken := min(canary, len(hosts)); taken > 0 {
+			batches[0] = append(batches[0], hosts[:taken]...)
+			hosts = hosts[taken:]
+			canary -= taken
+		}
+		for i := 0; i < len(hosts); i += size {
+			batches = append(batches, hosts[i:min(i+size, len(hosts))])
+		}
+	}
+	return batches
+}
+
+// gate waits for the soak period after the canaries and checks the health gates before
+// upgrading the next batch
+func (p *UpgradeWorkers) gate(ctx context.Context, afterCanary bool) error {
+	strategy := p.Config.Spec.Options.UpgradeStrategy
+	if afterCanary && strategy.SoakPeriod > 0 {
+		if !p.IsWet() {
+			p.DryMsgf(nil, "wait %s after upgrading the canary workers", strategy.SoakPeriod)
+		} else {
+			log.Infof("Waiting %s after upgrading the canary workers", strategy.SoakPeriod)
+			if err := soak(ctx, strategy.SoakPeriod); err != nil {
+				return fmt.Errorf("soak period: %w", err)
+			}
+		}
+	}
+
+	if !strategy.HealthGates.Enabled() {
+		return nil
+	}
+	if !p.IsWet() {
+		p.DryMsg(p.leader, "check the health gates before upgrading the next batch of workers")
+		return nil
+	}
+
+	log.Infof("Checking the health gates before upgrading the next batch of workers")
+	gates := &healthGates{gates: strategy.HealthGates, leader: p.leader, hosts: p.Config.Spec.Hosts}
+	if err := gates.check(ctx); err != nil {
+		if strategy.AbortOnFailureValue() {
+			return fmt.Errorf("health gates failed, aborting the worker upgrade: %w", err)
+		}
+		log.Warnf("health gates failed, continuing the worker upgrade because abortOnFailure is disabled: %v", err)
+		return nil
+	}
+	log.Infof("Health gates passed")
+	return nil
 }
 
 func (p *UpgradeWorkers) cordonWorker(_ context.Context, h *cluster.Host) error {
@@ -182,10 +296,15 @@ func (p *UpgradeWorkers) start(_ context.Context, h *cluster.Host) error {
 
 func (p *UpgradeWorkers) finish(_ context.Context, h *cluster.Host) error {
 	log.Infof("%s: upgrade finished", h)
+	p.manager.MarkHostDone(p.Title(), h)
 	return nil
 }
 
 func (p *UpgradeWorkers) upgradeWorker(ctx context.Context, h *cluster.Host) error {
+	if !p.IsWet() {
+		p.PlanChange(h, PlanAction{Action: PlanActionUpgrade, Target: "k0s", Old: h.Metadata.K0sRunningVersion.String(), New: p.Config.Spec.K0s.Version.String()})
+	}
+
 	svc, svcErr := h.Sudo().Service(h.K0sServiceName())
 	if svcErr != nil {
 		return fmt.Errorf("get service %s: %w", h.K0sServiceName(), svcErr)
@@ -207,6 +326,13 @@ func (p *UpgradeWorkers) upgradeWorker(ctx context.Context, h *cluster.Host) err
 		return err
 	}
 
+	err = p.Wet(h, "keep the previous k0s binary and configuration for a rollback", func() error {
+		return p.rollback.keep(h)
+	})
+	if err != nil {
+		return err
+	}
+
 	if h.Metadata.K0sBinaryTempFile != "" {
 		log.Debugf("%s: update binary", h)
 		err = p.Wet(h, "replace k0s binary", func() error {
@@ -274,6 +400,7 @@ func (p *UpgradeWorkers) upgradeWorker(ctx context.Context, h *cluster.Host) err
 	if err != nil {
 		return err
 	}
+	p.rollback.done(h)
 
 	h.Metadata.Ready = true
 	return nil
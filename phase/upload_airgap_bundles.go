@@ -0,0 +1,76 @@
+package phase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1"
+	"github.com/k0sproject/k0sctl/pkg/apis/k0sctl.k0sproject.io/v1beta1/cluster"
+	k0s "github.com/k0sproject/k0sctl/pkg/k0s"
+	"github.com/k0sproject/k0sctl/pkg/k0s/binprovider"
+	log "github.com/sirupsen/logrus"
+)
+
+// UploadAirgapBundles uploads the k0s airgap image bundles configured in spec.k0s.airgap to the
+// images directory of the hosts that run workloads
+type UploadAirgapBundles struct {
+	GenericPhase
+
+	hosts   cluster.Hosts
+	bundles map[*cluster.Host]*binprovider.AirgapBundle
+}
+
+// Title for the phase
+func (p *UploadAirgapBundles) Title() string {
+	return "Upload airgap image bundles"
+}
+
+// Prepare the phase
+func (p *UploadAirgapBundles) Prepare(config *v1beta1.Cluster) error {
+	p.Config = config
+	p.bundles = make(map[*cluster.Host]*binprovider.AirgapBundle)
+	if p.Config.Spec.K0s == nil || p.Config.Spec.K0s.Airgap == nil {
+		return nil
+	}
+	for _, h := range p.Config.Spec.Hosts {
+		b, err := h.K0sAirgapBundle(p.Config.Spec.K0s.Version)
+		if err != nil {
+			return fmt.Errorf("%s: %w", h, err)
+		}
+		if b == nil {
+			continue
+		}
+		p.bundles[h] = b
+		p.hosts = append(p.hosts, h)
+	}
+	return nil
+}
+
+// ShouldRun is true when there are hosts that need an airgap bundle
+func (p *UploadAirgapBundles) ShouldRun() bool {
+	return len(p.hosts) > 0
+}
+
+// Run the phase
+func (p *UploadAirgapBundles) Run(ctx context.Context) error {
+	err := ensureCached(ctx, p.hosts, "k0s airgap bundle", func(h *cluster.Host) (k0s.BinaryCacher, error) {
+		return p.bundles[h], nil
+	})
+	if err != nil {
+		return err
+	}
+	return p.parallelDoUpload(ctx, p.hosts, p.uploadBundle)
+}
+
+func (p *UploadAirgapBundles) uploadBundle(_ context.Context, h *cluster.Host) error {
+	b := p.bundles[h]
+	needsUpload, err := b.NeedsUpload()
+	if err != nil {
+		return err
+	}
+	if !needsUpload {
+		log.Infof("%s: k0s airgap bundle %s is up to date", h, b.Destination())
+		return nil
+	}
+	return p.Wet(h, fmt.Sprintf("upload k0s airgap bundle %s to %s", b.Source(), b.Destination()), b.Upload)
+}
@@ -132,6 +132,9 @@ func (p *UploadFiles) uploadFile(h *cluster.Host, f *cluster.UploadFile) error {
 			if err != nil {
 				return fmt.Errorf("failed to stat local file %s: %w", src, err)
 			}
+			if !p.IsWet() {
+				p.planUpload(h, src, dest)
+			}
 			err := p.Wet(h, fmt.Sprintf("upload file %s => %s", src, dest), func() error {
 				stat, err := os.Stat(src)
 				if err != nil {
@@ -167,6 +170,17 @@ func (p *UploadFiles) uploadFile(h *cluster.Host, f *cluster.UploadFile) error {
 	return nil
 }
 
+// planUpload records the upload of a local file into the plan along with its checksum
+func (p *UploadFiles) planUpload(h *cluster.Host, src, dest string) {
+	action := PlanAction{Action: PlanActionUpload, Target: dest}
+	if data, err := os.ReadFile(src); err == nil {
+		action.New = contentChecksum(data)
+	} else {
+		log.Warnf("%s: failed to read %s for checksum: %v", h, src, err)
+	}
+	p.PlanChange(h, action)
+}
+
 func (p *UploadFiles) uploadData(h *cluster.Host, f *cluster.UploadFile) error {
 	log.Infof("%s: uploading inline data", h)
 	dest := f.DestinationFile
@@ -184,6 +198,9 @@ func (p *UploadFiles) uploadData(h *cluster.Host, f *cluster.UploadFile) error {
 		return err
 	}
 
+	if !p.IsWet() {
+		p.PlanChange(h, PlanAction{Action: PlanActionUpload, Target: dest, New: contentChecksum([]byte(f.Data))})
+	}
 	err := p.Wet(h, fmt.Sprintf("upload inline data => %s", dest), func() error {
 		fileMode, _ := strconv.ParseUint(f.PermString, 8, 32)
 		remoteFile, err := h.Sudo().FS().OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(fileMode))
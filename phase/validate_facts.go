@@ -12,6 +12,9 @@ import (
 type ValidateFacts struct {
 	GenericPhase
 	SkipDowngradeCheck bool
+	// UpgradePath is the --upgrade-path mode, upgrades that skip kubernetes minor versions are
+	// refused unless it is UpgradePathAuto
+	UpgradePath string
 }
 
 // Title for the phase
@@ -29,6 +32,10 @@ func (p *ValidateFacts) Run(_ context.Context) error {
 		return err
 	}
 
+	if err := p.validateUpgradePath(); err != nil {
+		return err
+	}
+
 	if err := p.validateNodeLocalLoadBalancing(); err != nil {
 		return err
 	}
@@ -52,6 +59,24 @@ func (p *ValidateFacts) validateDowngrade() error {
 	return nil
 }
 
+func (p *ValidateFacts) validateUpgradePath() error {
+	if p.UpgradePath == UpgradePathAuto || p.Config.Spec.K0s.Version == nil {
+		return nil
+	}
+
+	from := lowestRunningVersion(p.Config.Spec.Hosts)
+	if from == nil {
+		return nil
+	}
+
+	if minors := skippedMinors(from, p.Config.Spec.K0s.Version); len(minors) > 0 {
+		major, _ := minorVersion(from)
+		return fmt.Errorf("can't upgrade from %s to %s because kubernetes does not support skipping minor versions (%s), upgrade one minor version at a time or use --upgrade-path auto", from, p.Config.Spec.K0s.Version, formatMinors(major, minors))
+	}
+
+	return nil
+}
+
 func (p *ValidateFacts) validateDefaultVersion() error {
 	// Only check when running with a defaulted version
 	if !p.Config.Spec.K0s.Metadata.VersionDefaulted {
@@ -14,6 +14,13 @@ import (
 // APIVersion is the current api version
 const APIVersion = "k0sctl.k0sproject.io/v1beta1"
 
+// ClusterAnnotation is the annotation that associates a ClusterConfig or another resource in the
+// configuration with the Cluster of the same metadata.name
+const ClusterAnnotation = "k0sctl.k0sproject.io/cluster"
+
+// DefaultClusterName is the name of a cluster that does not have metadata.name set
+const DefaultClusterName = "k0s-cluster"
+
 // ClusterMetadata defines cluster metadata
 type ClusterMetadata struct {
 	Name        string            `yaml:"name" validate:"required" default:"k0s-cluster"`
@@ -0,0 +1,62 @@
+package cluster
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/k0sproject/k0sctl/pkg/k0s/binprovider"
+	"github.com/k0sproject/version"
+)
+
+// K0sAirgap configures the k0s airgap image bundles that are uploaded to the hosts that run workloads
+type K0sAirgap struct {
+	// Path is a local path to the bundle. It supports the same tokens as k0sDownloadURL.
+	Path string `yaml:"path,omitempty"`
+	// URL to download the bundle from. It supports the same tokens as k0sDownloadURL. When neither
+	// the path nor the url are set, the bundle is downloaded from the k0s GitHub release.
+	URL string `yaml:"url,omitempty"`
+	// Checksums are pinned sha256 checksums of the bundles per architecture
+	Checksums map[string]string `yaml:"checksums,omitempty"`
+
+	baseDir string
+}
+
+// Validate the airgap configuration
+func (a *K0sAirgap) Validate() error {
+	if a.Path != "" && a.URL != "" {
+		return fmt.Errorf("path and url can not be set at the same time")
+	}
+	for arch, sum := range a.Checksums {
+		if arch == "" {
+			return fmt.Errorf("checksums: architecture can not be empty")
+		}
+		if b, err := hex.DecodeString(binprovider.NormalizeChecksum(sum)); err != nil || len(b) != 32 {
+			return fmt.Errorf("checksums: %s: not a valid sha256 checksum: %q", arch, sum)
+		}
+	}
+	return nil
+}
+
+// Resolve stores the directory relative paths are resolved against
+func (a *K0sAirgap) Resolve(baseDir string) {
+	a.baseDir = baseDir
+}
+
+// Bundle returns the airgap bundle for the host
+func (a *K0sAirgap) Bundle(h *Host, target *version.Version) (*binprovider.AirgapBundle, error) {
+	// the version token is url escaped, which is not wanted in local file names
+	localPath := h.ExpandTokens(a.Path, target)
+	if unescaped, err := url.PathUnescape(localPath); err == nil {
+		localPath = unescaped
+	}
+	if localPath != "" && !filepath.IsAbs(localPath) && a.baseDir != "" {
+		localPath = filepath.Join(a.baseDir, localPath)
+	}
+	arch, err := h.Arch()
+	if err != nil {
+		return nil, fmt.Errorf("get host arch: %w", err)
+	}
+	return binprovider.NewAirgapBundle(h, target, localPath, h.ExpandTokens(a.URL, target), a.Checksums[arch], h.K0sDataDir())
+}
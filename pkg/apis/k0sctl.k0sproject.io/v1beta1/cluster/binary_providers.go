@@ -1,13 +1,16 @@
 package cluster
 
 import (
+	"net/url"
+	"strings"
+
 	k0s "github.com/k0sproject/k0sctl/pkg/k0s"
 	"github.com/k0sproject/k0sctl/pkg/k0s/binprovider"
 	"github.com/k0sproject/version"
 )
 
 // defaultBinaryProvider returns the appropriate built-in BinaryProvider for the host
-// based on its configuration fields (UseExistingK0s, K0sBinaryPath, UploadBinary, K0sDownloadURLOverride, etc.).
+// based on its configuration fields (UseExistingK0s, K0sBinaryPath, UploadBinary, K0sDownloadURLOverride, K0sBinarySource, etc.).
 func (h *Host) defaultBinaryProvider(target *version.Version) k0s.BinaryProvider {
 	if h.UseExistingK0s {
 		return binprovider.NewExisting(h)
@@ -23,6 +26,9 @@ func (h *Host) defaultBinaryProvider(target *version.Version) k0s.BinaryProvider
 			return h.FileChanged(h.K0sBinaryPath, installPath)
 		})
 	}
+	if h.K0sBinarySource != "" {
+		return binprovider.NewOCI(h, h.K0sInstallLocation(), h.ociReference, target, h.UploadBinary)
+	}
 	if h.UploadBinary {
 		return binprovider.NewLocalUpload(h, h.K0sInstallLocation(), target)
 	}
@@ -33,3 +39,9 @@ func (h *Host) defaultBinaryProvider(target *version.Version) k0s.BinaryProvider
 	}
 	return binprovider.NewGitHub(h, h.K0sInstallLocation(), target)
 }
+
+// ociReference expands the tokens in K0sBinarySource. OCI tags can not contain a "+", so the
+// escaped "+" of the version token is replaced with "-" (v1.30.0+k0s.0 becomes v1.30.0-k0s.0).
+func (h *Host) ociReference(v *version.Version) string {
+	return strings.ReplaceAll(h.ExpandTokens(h.K0sBinarySource, v), url.QueryEscape("+"), "-")
+}
@@ -0,0 +1,93 @@
+package cluster
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jellydator/validation"
+	"github.com/k0sproject/k0sctl/pkg/k0s/binprovider"
+	"github.com/k0sproject/version"
+)
+
+// K0sBinaryVerification configures the integrity verification of the k0s binaries
+type K0sBinaryVerification struct {
+	// Checksums are pinned sha256 checksums of the k0s binary of spec.k0s.version per architecture.
+	// They are required for verifying binaries downloaded from a k0sDownloadURL.
+	Checksums map[string]string   `yaml:"checksums,omitempty"`
+	Signature *K0sBinarySignature `yaml:"signature,omitempty"`
+}
+
+// K0sBinarySignature configures the signature verification of the k0s binaries
+type K0sBinarySignature struct {
+	// Type is either cosign or minisign
+	Type string `yaml:"type"`
+	// PublicKey is the path to the public key file
+	PublicKey string `yaml:"publicKey"`
+	// URL is the signature URL. It supports the same tokens as k0sDownloadURL. When not set, the
+	// signature is expected next to the binary with a .sig (cosign) or .minisig (minisign) suffix.
+	URL string `yaml:"url,omitempty"`
+
+	publicKeyData []byte
+}
+
+// Validate the verification configuration
+func (v *K0sBinaryVerification) Validate() error {
+	for arch, sum := range v.Checksums {
+		if arch == "" {
+			return fmt.Errorf("checksums: architecture can not be empty")
+		}
+		if b, err := hex.DecodeString(binprovider.NormalizeChecksum(sum)); err != nil || len(b) != 32 {
+			return fmt.Errorf("checksums: %s: not a valid sha256 checksum: %q", arch, sum)
+		}
+	}
+	return validation.ValidateStruct(v,
+		validation.Field(&v.Signature),
+	)
+}
+
+// Validate the signature configuration
+func (s *K0sBinarySignature) Validate() error {
+	return validation.ValidateStruct(s,
+		validation.Field(&s.Type, validation.Required, validation.In(binprovider.SignatureCosign, binprovider.SignatureMinisign)),
+		validation.Field(&s.PublicKey, validation.Required),
+	)
+}
+
+// PublicKeyData returns the contents of the public key file read by Resolve
+func (s *K0sBinarySignature) PublicKeyData() []byte {
+	return s.publicKeyData
+}
+
+// Resolve reads the public key file, relative paths are resolved against baseDir
+func (v *K0sBinaryVerification) Resolve(baseDir string) error {
+	if v.Signature == nil || v.Signature.PublicKey == "" {
+		return nil
+	}
+	keyPath := v.Signature.PublicKey
+	if !filepath.IsAbs(keyPath) && baseDir != "" {
+		keyPath = filepath.Join(baseDir, keyPath)
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read k0s binary signature public key: %w", err)
+	}
+	v.Signature.publicKeyData = data
+	return nil
+}
+
+// verifier returns a binprovider.Verifier for the host
+func (v *K0sBinaryVerification) verifier(h *Host) *binprovider.Verifier {
+	verifier := &binprovider.Verifier{Checksums: v.Checksums}
+	if s := v.Signature; s != nil {
+		verifier.SignatureType = s.Type
+		verifier.PublicKey = s.publicKeyData
+		if s.URL != "" {
+			verifier.SignatureURL = func(_ string, target *version.Version) string {
+				return h.ExpandTokens(s.URL, target)
+			}
+		}
+	}
+	return verifier
+}
@@ -19,8 +19,10 @@ import (
 	"github.com/k0sproject/k0sctl/configurer"
 	k0s "github.com/k0sproject/k0sctl/pkg/k0s"
 	"github.com/k0sproject/k0sctl/pkg/k0s/binprovider"
+	"github.com/k0sproject/k0sctl/pkg/oci"
 	rig "github.com/k0sproject/rig/v2"
 	rigos "github.com/k0sproject/rig/v2/os"
+	ps "github.com/k0sproject/rig/v2/powershell"
 	"github.com/k0sproject/rig/v2/remotefs"
 	"github.com/k0sproject/version"
 	sloglogrus "github.com/samber/slog-logrus/v2"
@@ -71,6 +73,7 @@ type Host struct {
 	*rig.Client         `yaml:"-"`
 
 	Role                   string            `yaml:"role"`
+	Groups                 []string          `yaml:"groups,omitempty"`
 	Reset                  bool              `yaml:"reset,omitempty"`
 	PrivateInterface       string            `yaml:"privateInterface,omitempty"`
 	PrivateAddress         string            `yaml:"privateAddress,omitempty"`
@@ -82,27 +85,34 @@ type Host struct {
 	K0sBinaryPath          string            `yaml:"k0sBinaryPath,omitempty"`
 	K0sInstallPath         string            `yaml:"k0sInstallPath,omitempty"`
 	K0sDownloadURLOverride string            `yaml:"k0sDownloadURL,omitempty"`
+	K0sBinarySource        string            `yaml:"k0sBinarySource,omitempty"`
 	InstallFlags           Flags             `yaml:"installFlags,omitempty"`
 	Files                  []*UploadFile     `yaml:"files,omitempty"`
 	OSIDOverride           string            `yaml:"os,omitempty"`
 	HostnameOverride       string            `yaml:"hostname,omitempty"`
 	NoTaints               bool              `yaml:"noTaints,omitempty"`
+	Labels                 map[string]string `yaml:"labels,omitempty"`
+	Annotations            map[string]string `yaml:"annotations,omitempty"`
+	NodeTaints             []string          `yaml:"taints,omitempty"`
 	Hooks                  Hooks             `yaml:"hooks,omitempty"`
 
 	Metadata   HostMetadata          `yaml:"-"`
 	Configurer configurer.Configurer `yaml:"-"`
 	OSRelease  *rigos.Release        `yaml:"-"`
 
-	binaryProvider              k0s.BinaryProvider // user-set override via SetK0sBinaryProvider
-	cachedDefaultProvider       k0s.BinaryProvider // auto-built default; rebuilt when target changes
-	cachedDefaultProviderTarget *version.Version   // target used to build cachedDefaultProvider
+	binaryProvider              k0s.BinaryProvider     // user-set override via SetK0sBinaryProvider
+	cachedDefaultProvider       k0s.BinaryProvider     // auto-built default; rebuilt when target changes
+	cachedDefaultProviderTarget *version.Version       // target used to build cachedDefaultProvider
+	binaryVerification          *K0sBinaryVerification // spec.k0s.verify, set by Spec.Resolve
+	airgap                      *K0sAirgap             // spec.k0s.airgap, set by Spec.Resolve
+	connection                  *hostConnection        // connection blocks as written in the configuration
 }
 
 // SetK0sBinaryProvider overrides the binary acquisition strategy for this host.
 // When set, it takes precedence over the strategy inferred from the host's
-// configuration fields (uploadBinary, useExistingK0s, k0sDownloadURL, etc.).
+// configuration fields (uploadBinary, useExistingK0s, k0sDownloadURL, k0sBinarySource, etc.).
 // This is the primary extension point for library users — for example, to pull
-// k0s from a private OCI registry rather than from GitHub.
+// k0s from an internal artifact store rather than from GitHub.
 func (h *Host) SetK0sBinaryProvider(p k0s.BinaryProvider) {
 	h.binaryProvider = p
 }
@@ -178,6 +188,42 @@ func (h *Host) DeleteFile(path string) error {
 	return h.Sudo().FS().Remove(path)
 }
 
+// FileSha256 returns the hex encoded sha256 checksum of a file on the host.
+func (h *Host) FileSha256(path string) (string, error) {
+	var c string
+	if h.IsWindows() {
+		c = ps.Cmd(fmt.Sprintf("(Get-FileHash -Algorithm SHA256 -LiteralPath %s).Hash", ps.SingleQuote(path)))
+	} else {
+		c = "sha256sum -b " + quote(h.FS(), path)
+	}
+	out, err := h.Sudo().ExecOutput(c)
+	if err != nil {
+		return "", fmt.Errorf("calculate sha256 checksum of %s: %w", path, err)
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("calculate sha256 checksum of %s: empty output", path)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// K0sBinaryVerifier returns the k0s binary verification configured in spec.k0s.verify or nil when not set.
+func (h *Host) K0sBinaryVerifier() *binprovider.Verifier {
+	if h.binaryVerification == nil {
+		return nil
+	}
+	return h.binaryVerification.verifier(h)
+}
+
+// K0sAirgapBundle returns the airgap image bundle configured in spec.k0s.airgap or nil when not
+// set or when the host does not run workloads.
+func (h *Host) K0sAirgapBundle(target *version.Version) (*binprovider.AirgapBundle, error) {
+	if h.airgap == nil || h.Reset || h.Role == "controller" || h.IsWindows() {
+		return nil, nil
+	}
+	return h.airgap.Bundle(h, target)
+}
+
 func (h *Host) SetDefaults() {
 	if h.OSIDOverride != "" {
 		h.OSRelease = &rigos.Release{ID: h.OSIDOverride}
@@ -257,6 +303,9 @@ func (h *Host) Validate() error {
 		validation.Field(&h.Files),
 		validation.Field(&h.NoTaints, validation.When(h.Role != "controller+worker", validation.NotIn(true).Error("noTaints can only be true for controller+worker role"))),
 		validation.Field(&h.InstallFlags, validation.Each(validation.By(validateBalancedQuotes))),
+		validation.Field(&h.Labels, validation.When(h.Role == "controller", validation.Empty.Error("labels can not be set on hosts without a kubelet")), validation.By(validateNodeLabels)),
+		validation.Field(&h.Annotations, validation.When(h.Role == "controller", validation.Empty.Error("annotations can not be set on hosts without a kubelet")), validation.By(validateNodeAnnotations)),
+		validation.Field(&h.NodeTaints, validation.When(h.Role == "controller", validation.Empty.Error("taints can not be set on hosts without a kubelet")), validation.Each(validation.By(validateNodeTaint))),
 	); err != nil {
 		return err
 	}
@@ -272,6 +321,25 @@ func (h *Host) Validate() error {
 		if h.K0sDownloadURLOverride != "" {
 			errs["k0sDownloadURL"] = fmt.Errorf("k0sDownloadURL cannot be set when useExistingK0s is true")
 		}
+		if h.K0sBinarySource != "" {
+			errs["k0sBinarySource"] = fmt.Errorf("k0sBinarySource cannot be set when useExistingK0s is true")
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+	}
+
+	if h.K0sBinarySource != "" {
+		errs := validation.Errors{}
+		if !oci.IsReference(h.K0sBinarySource) {
+			errs["k0sBinarySource"] = fmt.Errorf("k0sBinarySource must start with %s or %s", oci.Scheme, oci.PlainHTTPScheme)
+		}
+		if h.K0sBinaryPath != "" {
+			errs["k0sBinaryPath"] = fmt.Errorf("k0sBinaryPath cannot be set when k0sBinarySource is set")
+		}
+		if h.K0sDownloadURLOverride != "" {
+			errs["k0sDownloadURL"] = fmt.Errorf("k0sDownloadURL cannot be set when k0sBinarySource is set")
+		}
 		if len(errs) > 0 {
 			return errs
 		}
@@ -331,6 +399,13 @@ func (h *Host) UnmarshalYAML(unmarshal func(any) error) error {
 		return err
 	}
 
+	// keep the connection settings without the protocol defaults for merging spec.hostDefaults
+	conn := &hostConnection{}
+	if err := unmarshal(conn); err != nil {
+		return err
+	}
+	h.connection = conn
+
 	if h.Client != nil {
 		h.Disconnect()
 		h.Client = nil
@@ -932,6 +1007,12 @@ func (h *Host) HasHooks(action, stage string) bool {
 // RunHooks runs the hooks for the given action and stage (such as "apply", "before" would run the "before apply" hooks).
 // It respects context cancellation between hook executions.
 func (h *Host) RunHooks(ctx context.Context, action, stage string) error {
+	return h.RunHooksWithOutput(ctx, action, stage, nil)
+}
+
+// RunHooksWithOutput runs the hooks like RunHooks and passes the output of each executed hook command to
+// the output function when one is given.
+func (h *Host) RunHooksWithOutput(ctx context.Context, action, stage string, output func(cmd, out string)) error {
 	commands := h.Hooks.ForActionAndStage(action, stage)
 	if len(commands) == 0 {
 		return nil
@@ -943,7 +1024,15 @@ func (h *Host) RunHooks(ctx context.Context, action, stage string) error {
 		}
 
 		log.Infof("%s: running %s %s hook: %q", h, stage, action, cmd)
-		if err := h.Exec(cmd); err != nil {
+		if output == nil {
+			if err := h.Exec(cmd); err != nil {
+				return fmt.Errorf("failed to execute hook %q for action %q stage %q on host %s: %w", cmd, action, stage, h.Address(), err)
+			}
+			continue
+		}
+		out, err := h.ExecOutput(cmd)
+		output(cmd, out)
+		if err != nil {
 			return fmt.Errorf("failed to execute hook %q for action %q stage %q on host %s: %w", cmd, action, stage, h.Address(), err)
 		}
 	}
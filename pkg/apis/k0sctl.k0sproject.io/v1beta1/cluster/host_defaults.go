@@ -0,0 +1,250 @@
+package cluster
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/creasty/defaults"
+	"gopkg.in/yaml.v2"
+)
+
+// HostDefaults are settings shared by multiple hosts, used in spec.hostDefaults and spec.hostGroups
+type HostDefaults struct {
+	SSH              map[string]any    `yaml:"ssh,omitempty"`
+	WinRM            map[string]any    `yaml:"winRM,omitempty"`
+	OpenSSH          map[string]any    `yaml:"openSSH,omitempty"`
+	PrivateInterface string            `yaml:"privateInterface,omitempty"`
+	DataDir          string            `yaml:"dataDir,omitempty"`
+	KubeletRootDir   string            `yaml:"kubeletRootDir,omitempty"`
+	Environment      map[string]string `yaml:"environment,flow,omitempty"`
+	InstallFlags     Flags             `yaml:"installFlags,omitempty"`
+	Files            []*UploadFile     `yaml:"files,omitempty"`
+	Hooks            Hooks             `yaml:"hooks,omitempty"`
+	Labels           map[string]string `yaml:"labels,omitempty"`
+	Annotations      map[string]string `yaml:"annotations,omitempty"`
+	NodeTaints       []string          `yaml:"taints,omitempty"`
+}
+
+// hostConnection is the connection configuration of a host as written in the configuration,
+// before the defaults of the connection protocols are applied
+type hostConnection struct {
+	SSH     map[string]any `yaml:"ssh,omitempty"`
+	WinRM   map[string]any `yaml:"winRM,omitempty"`
+	OpenSSH map[string]any `yaml:"openSSH,omitempty"`
+	// the other host fields
+	Rest map[string]any `yaml:",inline"`
+}
+
+// ApplyDefaults merges the settings from the layers into the host, the later layers take
+// precedence over the earlier ones and the host's own settings take precedence over all of them:
+//
+//   - scalar values are only set when the host does not have a value
+//   - connection settings are deep merged into the host's connection block of the same protocol
+//   - environment, labels and annotations are merged by key
+//   - installFlags are merged by flag name with Flags.MergeOverwrite, except for --data-dir and
+//     --kubelet-root-dir which are handled like the dataDir and kubeletRootDir scalar values
+//   - files are concatenated and hooks are concatenated per action and stage
+//   - taints are concatenated, a later taint replaces an earlier one with the same key and effect
+func (h *Host) ApplyDefaults(layers ...*HostDefaults) error {
+	if len(layers) == 0 {
+		return nil
+	}
+
+	if err := h.applyConnectionDefaults(layers); err != nil {
+		return err
+	}
+
+	// the host's own --data-dir and --kubelet-root-dir installFlags are its explicit values,
+	// the ones from the layers are treated as the layers' dataDir and kubeletRootDir so that
+	// they can not replace the host's own values
+	h.DataDir = firstNonEmpty(h.InstallFlags.GetValue("--data-dir"), h.DataDir)
+	h.KubeletRootDir = firstNonEmpty(h.InstallFlags.GetValue("--kubelet-root-dir"), h.KubeletRootDir)
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		h.PrivateInterface = firstNonEmpty(h.PrivateInterface, l.PrivateInterface)
+		h.DataDir = firstNonEmpty(h.DataDir, l.InstallFlags.GetValue("--data-dir"), l.DataDir)
+		h.KubeletRootDir = firstNonEmpty(h.KubeletRootDir, l.InstallFlags.GetValue("--kubelet-root-dir"), l.KubeletRootDir)
+	}
+
+	env := make(map[string]string)
+	labels := make(map[string]string)
+	annotations := make(map[string]string)
+	var flags Flags
+	var files []*UploadFile
+	var taints []string
+	hooks := make(Hooks)
+	for _, l := range append(slices.Clone(layers), h.defaultsLayer()) {
+		maps.Copy(env, l.Environment)
+		maps.Copy(labels, l.Labels)
+		maps.Copy(annotations, l.Annotations)
+		flags.MergeOverwrite(withoutDirFlags(l.InstallFlags))
+		for _, f := range l.Files {
+			// the files are resolved per host, each host needs its own copy
+			c := *f
+			c.Sources = nil
+			files = append(files, &c)
+		}
+		taints = mergeTaints(taints, l.NodeTaints)
+		for action, stages := range l.Hooks {
+			if hooks[action] == nil {
+				hooks[action] = make(map[string][]string)
+			}
+			for stage, cmds := range stages {
+				hooks[action][stage] = append(hooks[action][stage], cmds...)
+			}
+		}
+	}
+
+	h.Environment = env
+	h.InstallFlags = flags
+	h.Files = files
+	h.NodeTaints = taints
+	h.Labels = nilIfEmpty(labels)
+	h.Annotations = nilIfEmpty(annotations)
+	if len(hooks) > 0 {
+		h.Hooks = hooks
+	}
+
+	// installFlags from the layers can change the role
+	h.SetDefaults()
+
+	return nil
+}
+
+// defaultsLayer returns the host's own mergeable settings as a layer
+func (h *Host) defaultsLayer() *HostDefaults {
+	return &HostDefaults{
+		Environment:  h.Environment,
+		InstallFlags: h.InstallFlags,
+		Files:        h.Files,
+		Hooks:        h.Hooks,
+		Labels:       h.Labels,
+		Annotations:  h.Annotations,
+		NodeTaints:   h.NodeTaints,
+	}
+}
+
+// applyConnectionDefaults deep merges the connection settings of the layers into the host's
+// connection blocks and decodes the result over the host's connection configuration
+func (h *Host) applyConnectionDefaults(layers []*HostDefaults) error {
+	if h.connection == nil {
+		return nil
+	}
+	merged := make(map[string]any)
+	var changed bool
+	merge := func(key string, own map[string]any, get func(*HostDefaults) map[string]any) {
+		if own == nil {
+			return
+		}
+		m := make(map[string]any)
+		for _, l := range layers {
+			if d := get(l); d != nil {
+				deepMerge(m, d)
+				changed = true
+			}
+		}
+		deepMerge(m, own)
+		merged[key] = m
+	}
+	merge("ssh", h.connection.SSH, func(d *HostDefaults) map[string]any { return d.SSH })
+	merge("winRM", h.connection.WinRM, func(d *HostDefaults) map[string]any { return d.WinRM })
+	merge("openSSH", h.connection.OpenSSH, func(d *HostDefaults) map[string]any { return d.OpenSSH })
+	if !changed {
+		return nil
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("encode connection configuration: %w", err)
+	}
+	h.CompositeConfig.SSH = nil
+	h.CompositeConfig.WinRM = nil
+	h.CompositeConfig.OpenSSH = nil
+	if err := yaml.Unmarshal(data, &h.CompositeConfig); err != nil {
+		return fmt.Errorf("decode connection configuration: %w", err)
+	}
+	return defaults.Set(&h.CompositeConfig)
+}
+
+// deepMerge merges src into dst, nested mappings are merged recursively and other values replaced
+func deepMerge(dst map[string]any, src map[string]any) {
+	for k, v := range src {
+		if sm, ok := stringMap(v); ok {
+			if dm, ok := stringMap(dst[k]); ok {
+				deepMerge(dm, sm)
+				dst[k] = dm
+				continue
+			}
+			c := make(map[string]any, len(sm))
+			deepMerge(c, sm)
+			dst[k] = c
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// stringMap converts the mappings decoded by yaml.v2 into a map with string keys
+func stringMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, true
+	case map[any]any:
+		c := make(map[string]any, len(m))
+		for k, v := range m {
+			c[fmt.Sprint(k)] = v
+		}
+		return c, true
+	default:
+		return nil, false
+	}
+}
+
+// mergeTaints appends the taints to the list, replacing the ones with the same key and effect
+func mergeTaints(taints, add []string) []string {
+	for _, s := range add {
+		t, err := ParseNodeTaint(s)
+		if err != nil {
+			// invalid taints are reported by the host validation
+			taints = append(taints, s)
+			continue
+		}
+		replaced := false
+		for i, existing := range taints {
+			if e, err := ParseNodeTaint(existing); err == nil && e.ID() == t.ID() {
+				taints[i] = s
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			taints = append(taints, s)
+		}
+	}
+	return taints
+}
+
+// withoutDirFlags returns a copy of the flags without --data-dir and --kubelet-root-dir
+func withoutDirFlags(f Flags) Flags {
+	f = slices.Clone(f)
+	f.Delete("--data-dir")
+	f.Delete("--kubelet-root-dir")
+	return f
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func nilIfEmpty(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
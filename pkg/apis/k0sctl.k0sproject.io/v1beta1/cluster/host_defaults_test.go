@@ -0,0 +1,158 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSpecExpandHosts(t *testing.T) {
+	data := []byte(`
+hostDefaults:
+  ssh:
+    user: ubuntu
+    port: 2222
+  environment:
+    HTTP_PROXY: http://proxy:3128
+    TZ: UTC
+  installFlags:
+    - --debug
+    - --labels=tier=default
+  files:
+    - name: ca
+      src: /etc/ssl/ca.pem
+      dstDir: /etc/ssl
+  hooks:
+    apply:
+      before:
+        - echo defaults
+  taints:
+    - dedicated=default:NoSchedule
+hostGroups:
+  rack-1:
+    ssh:
+      user: admin
+    dataDir: /data/k0s
+    environment:
+      TZ: Europe/Helsinki
+    installFlags:
+      - --labels=tier=rack
+    labels:
+      topology.kubernetes.io/zone: rack-1
+    hooks:
+      apply:
+        before:
+          - echo group
+    taints:
+      - dedicated=rack:NoSchedule
+hosts:
+  - role: controller
+    ssh:
+      address: 10.0.0.1
+  - role: worker
+    groups: [rack-1]
+    ssh:
+      address: 10.0.0.2
+      port: 22
+    environment:
+      TZ: Europe/Stockholm
+    installFlags:
+      - --labels=tier=host
+    hooks:
+      apply:
+        before:
+          - echo host
+`)
+	spec := &Spec{}
+	require.NoError(t, yaml.Unmarshal(data, spec))
+	require.NoError(t, spec.ExpandHosts())
+
+	c := spec.Hosts[0]
+	require.Equal(t, "10.0.0.1", c.SSH.Address)
+	require.Equal(t, "ubuntu", c.SSH.User)
+	require.Equal(t, 2222, c.SSH.Port)
+	require.Equal(t, map[string]string{"HTTP_PROXY": "http://proxy:3128", "TZ": "UTC"}, c.Environment)
+	require.Equal(t, Flags{"--debug", "--labels=tier=default"}, c.InstallFlags)
+	require.Len(t, c.Files, 1)
+	require.Equal(t, []string{"echo defaults"}, c.Hooks.ForActionAndStage("apply", "before"))
+	require.Empty(t, c.DataDir)
+
+	w := spec.Hosts[1]
+	require.Equal(t, "10.0.0.2", w.SSH.Address)
+	require.Equal(t, "admin", w.SSH.User)
+	require.Equal(t, 22, w.SSH.Port)
+	require.Equal(t, "/data/k0s", w.DataDir)
+	require.Equal(t, map[string]string{"HTTP_PROXY": "http://proxy:3128", "TZ": "Europe/Stockholm"}, w.Environment)
+	require.Equal(t, Flags{"--debug", "--labels=tier=host"}, w.InstallFlags)
+	require.Equal(t, map[string]string{"topology.kubernetes.io/zone": "rack-1"}, w.Labels)
+	require.Equal(t, []string{"dedicated=rack:NoSchedule"}, w.NodeTaints)
+	require.Equal(t, []string{"echo defaults", "echo group", "echo host"}, w.Hooks.ForActionAndStage("apply", "before"))
+	require.Len(t, w.Files, 1)
+	require.NotSame(t, c.Files[0], w.Files[0])
+
+	out, err := yaml.Marshal(spec)
+	require.NoError(t, err)
+	require.NotContains(t, string(out), "hostDefaults")
+	require.NotContains(t, string(out), "groups")
+
+	rendered := &Spec{}
+	require.NoError(t, yaml.Unmarshal(out, rendered))
+	require.NoError(t, rendered.ExpandHosts())
+	require.Equal(t, w.InstallFlags, rendered.Hosts[1].InstallFlags)
+	require.Equal(t, w.Hooks, rendered.Hosts[1].Hooks)
+	require.Equal(t, "admin", rendered.Hosts[1].SSH.User)
+}
+
+func TestSpecExpandHostsUnknownGroup(t *testing.T) {
+	spec := &Spec{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+hosts:
+  - role: controller
+    groups: [missing]
+    ssh:
+      address: 10.0.0.1
+`), spec))
+	require.ErrorContains(t, spec.ExpandHosts(), `unknown host group "missing"`)
+}
+
+func TestSpecExpandHostsDirFlags(t *testing.T) {
+	spec := &Spec{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+hostGroups:
+  storage:
+    installFlags:
+      - --data-dir=/group/k0s
+      - --kubelet-root-dir=/group/kubelet
+      - --debug
+hosts:
+  - role: worker
+    groups: [storage]
+    dataDir: /host/k0s
+    ssh:
+      address: 10.0.0.1
+  - role: worker
+    groups: [storage]
+    installFlags:
+      - --kubelet-root-dir=/host/kubelet
+    ssh:
+      address: 10.0.0.2
+  - role: worker
+    groups: [storage]
+    ssh:
+      address: 10.0.0.3
+`), spec))
+	require.NoError(t, spec.ExpandHosts())
+
+	require.Equal(t, "/host/k0s", spec.Hosts[0].DataDir)
+	require.Equal(t, "/group/kubelet", spec.Hosts[0].KubeletRootDir)
+	require.Equal(t, Flags{"--debug"}, spec.Hosts[0].InstallFlags)
+
+	require.Equal(t, "/group/k0s", spec.Hosts[1].DataDir)
+	require.Equal(t, "/host/kubelet", spec.Hosts[1].KubeletRootDir)
+	require.Equal(t, Flags{"--debug"}, spec.Hosts[1].InstallFlags)
+
+	require.Equal(t, "/group/k0s", spec.Hosts[2].DataDir)
+	require.Equal(t, "/group/kubelet", spec.Hosts[2].KubeletRootDir)
+	require.Equal(t, Flags{"--debug"}, spec.Hosts[2].InstallFlags)
+}
@@ -88,6 +88,65 @@ func TestValidation(t *testing.T) {
 		h.K0sDownloadURLOverride = ""
 		require.NoError(t, h.Validate())
 	})
+	t.Run("k0sBinarySource", func(t *testing.T) {
+		h := Host{Role: "worker", K0sBinarySource: "https://harbor.example.com/k0s"}
+		require.ErrorContains(t, h.Validate(), "must start with oci://")
+		h.K0sBinarySource = "oci://harbor.example.com/k0s/k0s:%v-%p"
+		require.NoError(t, h.Validate())
+		h.UploadBinary = true
+		require.NoError(t, h.Validate())
+		h.K0sDownloadURLOverride = "https://example.test/k0s"
+		require.ErrorContains(t, h.Validate(), "k0sDownloadURL cannot be set when k0sBinarySource is set")
+		h.K0sDownloadURLOverride = ""
+		h.UploadBinary = false
+		h.UseExistingK0s = true
+		require.ErrorContains(t, h.Validate(), "k0sBinarySource cannot be set")
+	})
+	t.Run("node metadata", func(t *testing.T) {
+		h := Host{
+			Role:        "worker",
+			Labels:      map[string]string{"topology.kubernetes.io/zone": "rack-1", "tier": ""},
+			Annotations: map[string]string{"example.com/owner": "team a"},
+			NodeTaints:  []string{"dedicated=db:NoSchedule", "example.com/maintenance:NoExecute"},
+		}
+		require.NoError(t, h.Validate())
+
+		h.NodeTaints = []string{"dedicated=db"}
+		require.ErrorContains(t, h.Validate(), "expected <key>[=<value>]:<effect>")
+		h.NodeTaints = []string{"dedicated=db:Sometimes"}
+		require.ErrorContains(t, h.Validate(), "effect must be one of")
+		h.NodeTaints = nil
+
+		h.Labels = map[string]string{"zone": "rack 1"}
+		require.ErrorContains(t, h.Validate(), "invalid value")
+		h.Labels = map[string]string{"-zone": "rack-1"}
+		require.ErrorContains(t, h.Validate(), "invalid key")
+		h.Labels = nil
+
+		h.Annotations = map[string]string{NodeMetadataAnnotation: "{}"}
+		require.ErrorContains(t, h.Validate(), "reserved")
+
+		h = Host{Role: "controller", Labels: map[string]string{"zone": "rack-1"}}
+		require.ErrorContains(t, h.Validate(), "without a kubelet")
+	})
+}
+
+func TestParseNodeTaint(t *testing.T) {
+	taint, err := ParseNodeTaint("example.com/dedicated=db:NoSchedule")
+	require.NoError(t, err)
+	require.Equal(t, NodeTaint{Key: "example.com/dedicated", Value: "db", Effect: "NoSchedule"}, taint)
+	require.Equal(t, "example.com/dedicated=db:NoSchedule", taint.String())
+	require.Equal(t, "example.com/dedicated:NoSchedule", taint.ID())
+
+	taint, err = ParseNodeTaint("maintenance:NoExecute")
+	require.NoError(t, err)
+	require.Equal(t, "maintenance:NoExecute", taint.String())
+}
+
+func TestOCIReference(t *testing.T) {
+	h := Host{K0sBinarySource: "oci://harbor.example.com/k0s/k0s:%v-%p"}
+	h.Metadata.Arch = "arm64"
+	require.Equal(t, "oci://harbor.example.com/k0s/k0s:v1.30.0-k0s.0-arm64", h.ociReference(version.MustParse("v1.30.0+k0s.0")))
 }
 
 func TestBinaryPath(t *testing.T) {
@@ -130,3 +189,28 @@ func TestExpandTokens(t *testing.T) {
 	require.Equal(t, "test%20expand/k0s-v1.0.0%2Bk0s.0-amd64", h.ExpandTokens("test%20expand/k0s-%v-%p%x", ver))
 }
 
+func TestK0sAirgapBundle(t *testing.T) {
+	baseDir := t.TempDir()
+	airgap := &K0sAirgap{Path: "k0s-airgap-bundle-%v-%p"}
+	airgap.Resolve(baseDir)
+	target := version.MustParse("v1.30.0+k0s.0")
+
+	h := &Host{Role: "worker", DataDir: "/var/lib/k0s", airgap: airgap}
+	h.Metadata.Arch = "arm64"
+	b, err := h.K0sAirgapBundle(target)
+	require.NoError(t, err)
+	require.NotNil(t, b)
+	require.Equal(t, filepath.Join(baseDir, "k0s-airgap-bundle-v1.30.0+k0s.0-arm64"), b.Source())
+	require.Equal(t, "/var/lib/k0s/images/k0s-airgap-bundle", b.Destination())
+
+	h.Role = "controller"
+	b, err = h.K0sAirgapBundle(target)
+	require.NoError(t, err)
+	require.Nil(t, b)
+
+	h.Role = "controller+worker"
+	h.airgap = nil
+	b, err = h.K0sAirgapBundle(target)
+	require.NoError(t, err)
+	require.Nil(t, b)
+}
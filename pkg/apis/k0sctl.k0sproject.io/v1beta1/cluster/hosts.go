@@ -90,6 +90,24 @@ func (hosts Hosts) Filter(filter func(h *Host) bool) Hosts {
 	return result
 }
 
+// GroupBy splits the hosts into groups by the key returned by the function. The groups are in the
+// order of their first host and the hosts keep their order within the groups.
+func (hosts Hosts) GroupBy(key func(h *Host) string) []Hosts {
+	var groups []Hosts
+	index := make(map[string]int)
+	for _, h := range hosts {
+		k := key(h)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, Hosts{})
+		}
+		groups[i] = append(groups[i], h)
+	}
+	return groups
+}
+
 // WithRole returns a ltered list of Hosts that have the given role
 func (hosts Hosts) WithRole(s string) Hosts {
 	return hosts.Filter(func(h *Host) bool {
@@ -34,7 +34,11 @@ type K0s struct {
 	VersionChannel string           `yaml:"versionChannel,omitempty"`
 	DynamicConfig  bool             `yaml:"dynamicConfig,omitempty" default:"false"`
 	Config         dig.Mapping      `yaml:"config,omitempty"`
-	Metadata       K0sMetadata      `yaml:"-"`
+	// Verify configures the integrity verification of the k0s binaries
+	Verify *K0sBinaryVerification `yaml:"verify,omitempty"`
+	// Airgap configures the airgap image bundles uploaded to the worker hosts
+	Airgap   *K0sAirgap  `yaml:"airgap,omitempty"`
+	Metadata K0sMetadata `yaml:"-"`
 }
 
 // K0sMetadata contains gathered information about k0s cluster
@@ -108,6 +112,8 @@ func (k *K0s) Validate() error {
 		validation.Field(&k.Version, validation.By(validateVersion)),
 		validation.Field(&k.DynamicConfig, validation.By(k.validateMinDynamic())),
 		validation.Field(&k.VersionChannel, validation.In("stable", "latest"), validation.When(k.VersionChannel != "")),
+		validation.Field(&k.Verify),
+		validation.Field(&k.Airgap),
 	)
 }
 
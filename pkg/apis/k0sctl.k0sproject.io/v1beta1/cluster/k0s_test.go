@@ -72,3 +72,45 @@ func TestNodeConfigUsesLowercaseMetadataKey(t *testing.T) {
 	require.NotContains(t, nodeConfig, "Metadata")
 	require.Equal(t, "k0s", nodeConfig.DigString("metadata", "name"))
 }
+
+func TestK0sVerifyValidation(t *testing.T) {
+	k0s := &K0s{}
+	err := yaml.Unmarshal([]byte(`
+version: v1.30.0+k0s.0
+verify:
+  checksums:
+    amd64: sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+  signature:
+    type: cosign
+    publicKey: cosign.pub
+`), k0s)
+	require.NoError(t, err)
+	require.NoError(t, k0s.Validate())
+
+	k0s.Verify.Checksums["arm64"] = "abc"
+	require.ErrorContains(t, k0s.Validate(), "not a valid sha256 checksum")
+	delete(k0s.Verify.Checksums, "arm64")
+
+	k0s.Verify.Signature.Type = "gpg"
+	require.Error(t, k0s.Validate())
+}
+
+func TestK0sAirgapValidation(t *testing.T) {
+	k0s := &K0s{}
+	err := yaml.Unmarshal([]byte(`
+version: v1.30.0+k0s.0
+airgap:
+  url: https://example.com/k0s-airgap-bundle-%v-%p
+  checksums:
+    amd64: sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+`), k0s)
+	require.NoError(t, err)
+	require.NoError(t, k0s.Validate())
+
+	k0s.Airgap.Checksums["arm64"] = "abc"
+	require.ErrorContains(t, k0s.Validate(), "not a valid sha256 checksum")
+	delete(k0s.Airgap.Checksums, "arm64")
+
+	k0s.Airgap.Path = "bundle"
+	require.ErrorContains(t, k0s.Validate(), "path and url can not be set at the same time")
+}
@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// NodeMetadataAnnotation is the node annotation where k0sctl records the labels, annotations and
+// taints it has set, so that the ones removed from the configuration can be removed from the node
+// without touching the ones managed by others
+const NodeMetadataAnnotation = "k0sctl.k0sproject.io/managed-metadata"
+
+// taintEffects are the valid kubernetes taint effects
+var taintEffects = []string{"NoSchedule", "PreferNoSchedule", "NoExecute"}
+
+var (
+	metadataNameRe   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+	metadataPrefixRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]{0,251}[a-z0-9])?$`)
+	labelValueRe     = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+// NodeTaint is a kubernetes node taint in the <key>[=<value>]:<effect> format
+type NodeTaint struct {
+	Key    string
+	Value  string
+	Effect string
+}
+
+// ParseNodeTaint parses a taint in the <key>[=<value>]:<effect> format
+func ParseNodeTaint(s string) (NodeTaint, error) {
+	kv, effect, ok := strings.Cut(s, ":")
+	if !ok {
+		return NodeTaint{}, fmt.Errorf("invalid taint %q, expected <key>[=<value>]:<effect>", s)
+	}
+	if !slices.Contains(taintEffects, effect) {
+		return NodeTaint{}, fmt.Errorf("invalid taint %q, effect must be one of %s", s, strings.Join(taintEffects, ", "))
+	}
+	key, value, _ := strings.Cut(kv, "=")
+	if err := validateMetadataKey(key); err != nil {
+		return NodeTaint{}, fmt.Errorf("invalid taint %q: %w", s, err)
+	}
+	if !labelValueRe.MatchString(value) {
+		return NodeTaint{}, fmt.Errorf("invalid taint %q: invalid value %q", s, value)
+	}
+	return NodeTaint{Key: key, Value: value, Effect: effect}, nil
+}
+
+// String returns the taint in the <key>[=<value>]:<effect> format
+func (t NodeTaint) String() string {
+	if t.Value == "" {
+		return t.Key + ":" + t.Effect
+	}
+	return t.Key + "=" + t.Value + ":" + t.Effect
+}
+
+// ID returns the <key>:<effect> identifier of the taint, a node can only have one taint per identifier
+func (t NodeTaint) ID() string {
+	return t.Key + ":" + t.Effect
+}
+
+// ParseNodeTaints returns the parsed taints of the host
+func (h *Host) ParseNodeTaints() ([]NodeTaint, error) {
+	taints := make([]NodeTaint, 0, len(h.NodeTaints))
+	for _, s := range h.NodeTaints {
+		t, err := ParseNodeTaint(s)
+		if err != nil {
+			return nil, err
+		}
+		taints = append(taints, t)
+	}
+	return taints, nil
+}
+
+// validateMetadataKey validates a label, annotation or taint key in the [<prefix>/]<name> format
+func validateMetadataKey(key string) error {
+	prefix, name, ok := strings.Cut(key, "/")
+	if !ok {
+		name, prefix = prefix, ""
+	} else if !metadataPrefixRe.MatchString(prefix) {
+		return fmt.Errorf("invalid key %q: invalid prefix %q", key, prefix)
+	}
+	if !metadataNameRe.MatchString(name) {
+		return fmt.Errorf("invalid key %q: invalid name %q", key, name)
+	}
+	return nil
+}
+
+func validateNodeLabels(val any) error {
+	labels, ok := val.(map[string]string)
+	if !ok {
+		return fmt.Errorf("invalid type")
+	}
+	for k, v := range labels {
+		if err := validateMetadataKey(k); err != nil {
+			return err
+		}
+		if !labelValueRe.MatchString(v) {
+			return fmt.Errorf("invalid value %q for label %q", v, k)
+		}
+	}
+	return nil
+}
+
+func validateNodeAnnotations(val any) error {
+	annotations, ok := val.(map[string]string)
+	if !ok {
+		return fmt.Errorf("invalid type")
+	}
+	for k := range annotations {
+		if err := validateMetadataKey(k); err != nil {
+			return err
+		}
+		if k == NodeMetadataAnnotation {
+			return fmt.Errorf("annotation %q is reserved for k0sctl", k)
+		}
+	}
+	return nil
+}
+
+func validateNodeTaint(val any) error {
+	s, ok := val.(string)
+	if !ok {
+		return fmt.Errorf("invalid type")
+	}
+	_, err := ParseNodeTaint(s)
+	return err
+}
@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -15,6 +16,9 @@ type Options struct {
 	Drain       DrainOption       `yaml:"drain"`
 	Concurrency ConcurrencyOption `yaml:"concurrency"`
 	EvictTaint  EvictTaintOption  `yaml:"evictTaint"`
+
+	BinaryDistribution BinaryDistributionOption `yaml:"binaryDistribution"`
+	UpgradeStrategy    UpgradeStrategyOption    `yaml:"upgradeStrategy"`
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface for Options.
@@ -125,6 +129,8 @@ type ConcurrencyOption struct {
 	Limit                   int `yaml:"limit" default:"30"`                   // Max number of hosts to operate on at once
 	WorkerDisruptionPercent int `yaml:"workerDisruptionPercent" default:"10"` // Max percentage of hosts to disrupt at once
 	Uploads                 int `yaml:"uploads" default:"5"`                  // Max concurrent file uploads
+	// GroupBy is a node label key, the workers are upgraded and reset one group of hosts with the same label value at a time
+	GroupBy string `yaml:"groupBy,omitempty"`
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface for ConcurrencyOption.
@@ -201,3 +207,200 @@ func (e *EvictTaintOption) Validate() error {
 		),
 	)
 }
+
+// Binary distribution modes
+const (
+	// BinaryDistributionDirect uploads the k0s binary from the local machine to every host
+	BinaryDistributionDirect = "direct"
+	// BinaryDistributionPeer uploads the k0s binary to one seed host per binary and lets the
+	// rest of the hosts download it from the seed
+	BinaryDistributionPeer = "peer"
+
+	// DefaultPeerDistributionPort is the port of the http server on the seed host
+	DefaultPeerDistributionPort = 9119
+)
+
+// BinaryDistributionOption controls how k0s binaries that are uploaded from the local machine
+// (uploadBinary or an OCI k0sBinarySource with uploadBinary) are distributed to the hosts.
+type BinaryDistributionOption struct {
+	Mode string `yaml:"mode" default:"direct"`
+	Port int    `yaml:"port" default:"9119"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for BinaryDistributionOption.
+func (b *BinaryDistributionOption) UnmarshalYAML(unmarshal func(any) error) error {
+	type binaryDistributionOption BinaryDistributionOption
+	var tmp binaryDistributionOption
+
+	if err := unmarshal(&tmp); err != nil {
+		return err
+	}
+
+	if err := defaults.Set(&tmp); err != nil {
+		return fmt.Errorf("set defaults for binaryDistribution: %w", err)
+	}
+
+	*b = BinaryDistributionOption(tmp)
+	return nil
+}
+
+// Validate checks if the BinaryDistributionOption is valid.
+func (b *BinaryDistributionOption) Validate() error {
+	return validation.ValidateStruct(b,
+		validation.Field(&b.Mode, validation.In(BinaryDistributionDirect, BinaryDistributionPeer)),
+		validation.Field(&b.Port, validation.Min(0), validation.Max(65535)),
+	)
+}
+
+// PeerEnabled returns true when the binaries are distributed host-to-host
+func (b BinaryDistributionOption) PeerEnabled() bool {
+	return b.Mode == BinaryDistributionPeer
+}
+
+// PortValue returns the effective port of the http server on the seed host
+func (b BinaryDistributionOption) PortValue() int {
+	if b.Port == 0 {
+		return DefaultPeerDistributionPort
+	}
+	return b.Port
+}
+
+// Upgrade strategy modes
+const (
+	// UpgradeStrategyK0sctl upgrades the hosts over the k0sctl connections
+	UpgradeStrategyK0sctl = "k0sctl"
+	// UpgradeStrategyAutopilot upgrades the hosts with a k0s autopilot plan
+	UpgradeStrategyAutopilot = "autopilot"
+)
+
+// UpgradeStrategyOption controls how the hosts are upgraded. In the k0sctl mode, the first Canary
+// workers are upgraded before the others and followed by a SoakPeriod wait. The health gates are
+// checked after the canaries and after every following batch of workers. In the autopilot mode,
+// k0sctl applies a k0s autopilot plan and waits for up to PlanTimeout for it to complete.
+//
+// The mode can also be given as a plain string, `upgradeStrategy: autopilot`.
+type UpgradeStrategyOption struct {
+	Mode           string            `yaml:"mode" default:"k0sctl"`
+	PlanTimeout    time.Duration     `yaml:"planTimeout" default:"60m"`
+	Canary         int               `yaml:"canary" default:"0"`
+	SoakPeriod     time.Duration     `yaml:"soakPeriod" default:"0s"`
+	AbortOnFailure *bool             `yaml:"abortOnFailure" default:"true"`
+	HealthGates    HealthGatesOption `yaml:"healthGates"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for UpgradeStrategyOption.
+func (u *UpgradeStrategyOption) UnmarshalYAML(unmarshal func(any) error) error {
+	type upgradeStrategyOption UpgradeStrategyOption
+	var tmp upgradeStrategyOption
+
+	var mode string
+	if err := unmarshal(&mode); err == nil {
+		tmp.Mode = mode
+	} else if err := unmarshal(&tmp); err != nil {
+		return err
+	}
+
+	if err := defaults.Set(&tmp); err != nil {
+		return fmt.Errorf("set defaults for upgradeStrategy: %w", err)
+	}
+
+	*u = UpgradeStrategyOption(tmp)
+	return nil
+}
+
+// Validate checks if the UpgradeStrategyOption is valid.
+func (u *UpgradeStrategyOption) Validate() error {
+	return validation.ValidateStruct(u,
+		validation.Field(&u.Mode, validation.In(UpgradeStrategyK0sctl, UpgradeStrategyAutopilot)),
+		validation.Field(&u.PlanTimeout, validation.Min(time.Duration(0))),
+		validation.Field(&u.Canary, validation.Min(0), validation.Empty.When(u.AutopilotEnabled()).Error(notWithAutopilot)),
+		validation.Field(&u.SoakPeriod, validation.Min(time.Duration(0)), validation.Empty.When(u.AutopilotEnabled()).Error(notWithAutopilot)),
+		validation.Field(&u.HealthGates, validation.By(func(_ any) error {
+			if u.AutopilotEnabled() && u.HealthGates.Enabled() {
+				return errors.New(notWithAutopilot)
+			}
+			return nil
+		})),
+	)
+}
+
+// notWithAutopilot is the validation error for the options that only apply to the k0sctl upgrade mode
+const notWithAutopilot = "can not be used with the autopilot upgrade strategy"
+
+// AutopilotEnabled returns true when the hosts are upgraded with a k0s autopilot plan.
+func (u UpgradeStrategyOption) AutopilotEnabled() bool {
+	return u.Mode == UpgradeStrategyAutopilot
+}
+
+// AbortOnFailureValue returns the effective abortOnFailure flag, defaulting to true when unset.
+func (u UpgradeStrategyOption) AbortOnFailureValue() bool {
+	return boolPtrValue(u.AbortOnFailure, true)
+}
+
+// HealthGatesOption defines the checks that must pass between the worker upgrade batches.
+type HealthGatesOption struct {
+	NodesReady          bool              `yaml:"nodesReady" default:"false"`
+	CrashLoopNamespaces []string          `yaml:"crashLoopNamespaces,omitempty"`
+	HTTP                []HTTPHealthCheck `yaml:"http,omitempty"`
+	Timeout             time.Duration     `yaml:"timeout" default:"5m"`
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for HealthGatesOption.
+func (g *HealthGatesOption) UnmarshalYAML(unmarshal func(any) error) error {
+	type healthGatesOption HealthGatesOption
+	var tmp healthGatesOption
+
+	if err := unmarshal(&tmp); err != nil {
+		return err
+	}
+
+	if err := defaults.Set(&tmp); err != nil {
+		return fmt.Errorf("set defaults for healthGates: %w", err)
+	}
+
+	*g = HealthGatesOption(tmp)
+	return nil
+}
+
+// Validate checks if the HealthGatesOption is valid.
+func (g HealthGatesOption) Validate() error {
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.HTTP),
+		validation.Field(&g.Timeout, validation.Min(time.Duration(0))),
+	)
+}
+
+// Enabled returns true when any of the health gates is configured.
+func (g HealthGatesOption) Enabled() bool {
+	return g.NodesReady || len(g.CrashLoopNamespaces) > 0 || len(g.HTTP) > 0
+}
+
+// HTTPHealthCheck is a HTTP request made from a host, the check passes when the response status
+// code is one of the expected ones.
+type HTTPHealthCheck struct {
+	URL    string `yaml:"url"`
+	Host   string `yaml:"host,omitempty"`
+	Status []int  `yaml:"status,omitempty"`
+}
+
+// Validate checks if the HTTPHealthCheck is valid.
+func (c HTTPHealthCheck) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.URL, validation.Required, validation.By(func(value any) error {
+			s, _ := value.(string)
+			if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+				return fmt.Errorf("must be a http:// or https:// url")
+			}
+			return nil
+		})),
+		validation.Field(&c.Status, validation.Each(validation.Min(100), validation.Max(599))),
+	)
+}
+
+// ExpectedStatus returns the expected response status codes, defaulting to 200.
+func (c HTTPHealthCheck) ExpectedStatus() []int {
+	if len(c.Status) == 0 {
+		return []int{200}
+	}
+	return c.Status
+}
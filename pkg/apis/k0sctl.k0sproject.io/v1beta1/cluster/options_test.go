@@ -0,0 +1,103 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestBinaryDistributionOption(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		o := &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte("binaryDistribution:\n  mode: peer\n"), o))
+		require.True(t, o.BinaryDistribution.PeerEnabled())
+		require.Equal(t, DefaultPeerDistributionPort, o.BinaryDistribution.PortValue())
+		require.NoError(t, o.BinaryDistribution.Validate())
+	})
+
+	t.Run("direct by default", func(t *testing.T) {
+		o := &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte("concurrency:\n  limit: 10\n"), o))
+		require.False(t, o.BinaryDistribution.PeerEnabled())
+		require.Equal(t, BinaryDistributionDirect, o.BinaryDistribution.Mode)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		o := BinaryDistributionOption{Mode: "torrent"}
+		require.ErrorContains(t, o.Validate(), "Mode")
+		o = BinaryDistributionOption{Mode: BinaryDistributionPeer, Port: 70000}
+		require.ErrorContains(t, o.Validate(), "Port")
+	})
+}
+
+func TestUpgradeStrategyOption(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		o := &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte("upgradeStrategy:\n  canary: 2\n  healthGates:\n    nodesReady: true\n"), o))
+		require.Equal(t, 2, o.UpgradeStrategy.Canary)
+		require.True(t, o.UpgradeStrategy.AbortOnFailureValue())
+		require.True(t, o.UpgradeStrategy.HealthGates.Enabled())
+		require.Equal(t, 5*time.Minute, o.UpgradeStrategy.HealthGates.Timeout)
+		require.NoError(t, o.UpgradeStrategy.Validate())
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		o := &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte("concurrency:\n  limit: 10\n"), o))
+		require.Zero(t, o.UpgradeStrategy.Canary)
+		require.False(t, o.UpgradeStrategy.HealthGates.Enabled())
+	})
+
+	t.Run("http checks", func(t *testing.T) {
+		o := &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte(`upgradeStrategy:
+  abortOnFailure: false
+  healthGates:
+    http:
+      - url: https://app.example.com/healthz
+        host: 10.0.0.1
+        status: [200, 204]
+      - url: http://10.0.0.2:8080/
+`), o))
+		require.False(t, o.UpgradeStrategy.AbortOnFailureValue())
+		require.NoError(t, o.UpgradeStrategy.Validate())
+		require.Equal(t, []int{200, 204}, o.UpgradeStrategy.HealthGates.HTTP[0].ExpectedStatus())
+		require.Equal(t, []int{200}, o.UpgradeStrategy.HealthGates.HTTP[1].ExpectedStatus())
+	})
+
+	t.Run("autopilot", func(t *testing.T) {
+		o := &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte("upgradeStrategy: autopilot\n"), o))
+		require.True(t, o.UpgradeStrategy.AutopilotEnabled())
+		require.Equal(t, 60*time.Minute, o.UpgradeStrategy.PlanTimeout)
+		require.NoError(t, o.UpgradeStrategy.Validate())
+
+		o = &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte("upgradeStrategy:\n  mode: autopilot\n  planTimeout: 2h\n"), o))
+		require.True(t, o.UpgradeStrategy.AutopilotEnabled())
+		require.Equal(t, 2*time.Hour, o.UpgradeStrategy.PlanTimeout)
+
+		o = &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte("upgradeStrategy: rolling\n"), o))
+		require.ErrorContains(t, o.UpgradeStrategy.Validate(), "Mode")
+
+		o = &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte("upgradeStrategy:\n  mode: autopilot\n  canary: 1\n"), o))
+		require.ErrorContains(t, o.UpgradeStrategy.Validate(), "Canary: can not be used with the autopilot upgrade strategy")
+
+		o = &Options{}
+		require.NoError(t, yaml.Unmarshal([]byte("upgradeStrategy:\n  mode: autopilot\n  healthGates:\n    nodesReady: true\n"), o))
+		require.ErrorContains(t, o.UpgradeStrategy.Validate(), "HealthGates: can not be used with the autopilot upgrade strategy")
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		o := UpgradeStrategyOption{Canary: -1}
+		require.ErrorContains(t, o.Validate(), "Canary")
+		o = UpgradeStrategyOption{HealthGates: HealthGatesOption{HTTP: []HTTPHealthCheck{{URL: "app.example.com"}}}}
+		require.ErrorContains(t, o.Validate(), "http")
+		o = UpgradeStrategyOption{HealthGates: HealthGatesOption{HTTP: []HTTPHealthCheck{{URL: "http://app", Status: []int{42}}}}}
+		require.Error(t, o.Validate())
+	})
+}
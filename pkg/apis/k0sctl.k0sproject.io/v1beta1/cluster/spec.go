@@ -12,11 +12,14 @@ import (
 
 // Spec defines cluster config spec section
 type Spec struct {
-	Hosts   Hosts   `yaml:"hosts,omitempty"`
-	K0s     *K0s    `yaml:"k0s,omitempty"`
-	Options Options `yaml:"options"`
-
-	k0sLeader *Host
+	HostDefaults *HostDefaults            `yaml:"hostDefaults,omitempty"`
+	HostGroups   map[string]*HostDefaults `yaml:"hostGroups,omitempty"`
+	Hosts        Hosts                    `yaml:"hosts,omitempty"`
+	K0s          *K0s                     `yaml:"k0s,omitempty"`
+	Options      Options                  `yaml:"options"`
+
+	k0sLeader     *Host
+	hostsExpanded bool
 }
 
 // UnmarshalYAML sets in some sane defaults when unmarshaling the data from yaml
@@ -32,7 +35,8 @@ func (s *Spec) UnmarshalYAML(unmarshal func(any) error) error {
 	return defaults.Set(s)
 }
 
-// MarshalYAML overrides default YAML marshaling to get rid of "k0s: null" when nothing is set in spec.k0s
+// MarshalYAML overrides default YAML marshaling to get rid of "k0s: null" when nothing is set in spec.k0s.
+// Once the host defaults and groups have been merged into the hosts, only the expanded hosts are output.
 func (s *Spec) MarshalYAML() (any, error) {
 	type spec Spec
 
@@ -42,6 +46,17 @@ func (s *Spec) MarshalYAML() (any, error) {
 		copy.K0s = nil
 	}
 
+	if s.hostsExpanded {
+		copy.HostDefaults = nil
+		copy.HostGroups = nil
+		copy.Hosts = make(Hosts, len(s.Hosts))
+		for i, h := range s.Hosts {
+			hc := *h
+			hc.Groups = nil
+			copy.Hosts[i] = &hc
+		}
+	}
+
 	return copy, nil
 }
 
@@ -55,6 +70,12 @@ func isEmptyK0s(k *K0s) bool {
 	if k.Version != nil {
 		return false
 	}
+	if k.Verify != nil {
+		return false
+	}
+	if k.Airgap != nil {
+		return false
+	}
 	return len(k.Config) == 0
 }
 
@@ -90,11 +111,30 @@ func (s *Spec) K0sLeader() *Host {
 }
 
 func (s *Spec) Validate() error {
-	return validation.ValidateStruct(s,
+	if err := validation.ValidateStruct(s,
 		validation.Field(&s.Hosts, validation.Required),
 		validation.Field(&s.Hosts),
 		validation.Field(&s.K0s),
-	)
+	); err != nil {
+		return err
+	}
+	if err := s.Options.BinaryDistribution.Validate(); err != nil {
+		return fmt.Errorf("options.binaryDistribution: %w", err)
+	}
+	if k := s.Options.Concurrency.GroupBy; k != "" {
+		if err := validateMetadataKey(k); err != nil {
+			return fmt.Errorf("options.concurrency.groupBy: %w", err)
+		}
+	}
+	if err := s.Options.UpgradeStrategy.Validate(); err != nil {
+		return fmt.Errorf("options.upgradeStrategy: %w", err)
+	}
+	for _, c := range s.Options.UpgradeStrategy.HealthGates.HTTP {
+		if c.Host != "" && s.Hosts.Find(func(h *Host) bool { return h.Address() == c.Host }) == nil {
+			return fmt.Errorf("options.upgradeStrategy.healthGates.http: host %s not found", c.Host)
+		}
+	}
+	return nil
 }
 
 // ResolveUploadFilePaths resolves all host file sources relative to baseDir.
@@ -107,8 +147,52 @@ func (s *Spec) ResolveUploadFilePaths(baseDir string) error {
 	return nil
 }
 
+// ExpandHosts merges spec.hostDefaults and the spec.hostGroups the hosts are members of into the hosts
+func (s *Spec) ExpandHosts() error {
+	if s.hostsExpanded {
+		return nil
+	}
+	for _, h := range s.Hosts {
+		var layers []*HostDefaults
+		if s.HostDefaults != nil {
+			layers = append(layers, s.HostDefaults)
+		}
+		for _, name := range h.Groups {
+			g, ok := s.HostGroups[name]
+			if !ok {
+				return fmt.Errorf("host %s: unknown host group %q", h, name)
+			}
+			if g != nil {
+				layers = append(layers, g)
+			}
+		}
+		if err := h.ApplyDefaults(layers...); err != nil {
+			return fmt.Errorf("host %s: %w", h, err)
+		}
+	}
+	s.hostsExpanded = true
+	return nil
+}
+
 // Resolve prepares spec-level data after unmarshalling by cascading to hosts.
 func (s *Spec) Resolve(baseDir string) error {
+	if err := s.ExpandHosts(); err != nil {
+		return err
+	}
+	if s.K0s != nil && s.K0s.Verify != nil {
+		if err := s.K0s.Verify.Resolve(baseDir); err != nil {
+			return err
+		}
+		for _, h := range s.Hosts {
+			h.binaryVerification = s.K0s.Verify
+		}
+	}
+	if s.K0s != nil && s.K0s.Airgap != nil {
+		s.K0s.Airgap.Resolve(baseDir)
+		for _, h := range s.Hosts {
+			h.airgap = s.K0s.Airgap
+		}
+	}
 	return s.ResolveUploadFilePaths(baseDir)
 }
 
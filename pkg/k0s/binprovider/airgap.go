@@ -0,0 +1,215 @@
+package binprovider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/adrg/xdg"
+	"github.com/k0sproject/k0sctl/pkg/k0s"
+	"github.com/k0sproject/rig/v2/remotefs"
+	"github.com/k0sproject/version"
+	log "github.com/sirupsen/logrus"
+)
+
+// AirgapBundleFileName is the name of the airgap image bundle file in the <dataDir>/images
+// directory. A fixed name makes an upgrade replace the bundle of the previous version.
+const AirgapBundleFileName = "k0s-airgap-bundle"
+
+// ErrAirgapChecksumMismatch is returned when the checksum of an airgap image bundle does not match the expected checksum
+var ErrAirgapChecksumMismatch = errors.New("k0s airgap bundle checksum mismatch")
+
+// AirgapBundle is the k0s airgap image bundle of a host. It implements k0s.BinaryCacher so
+// that each bundle is downloaded to the local cache only once, no matter how many hosts need it.
+type AirgapBundle struct {
+	host      Host
+	localPath string
+	url       string
+	published bool
+	checksum  string
+	dest      string
+}
+
+var _ k0s.BinaryCacher = (*AirgapBundle)(nil)
+
+// NewAirgapBundle returns the airgap image bundle for the host. When localPath is set, the bundle
+// is uploaded from the local file, otherwise it is downloaded from url into the local cache. An
+// empty url means the bundle published with the k0s release, which is verified against the
+// published checksums. The optional checksum is a pinned sha256 checksum of the bundle. The
+// bundle is uploaded to the images directory in dataDir.
+func NewAirgapBundle(h Host, target *version.Version, localPath, url, checksum, dataDir string) (*AirgapBundle, error) {
+	b := &AirgapBundle{
+		host:      h,
+		localPath: localPath,
+		url:       url,
+		checksum:  NormalizeChecksum(checksum),
+		dest:      path.Join(dataDir, "images", AirgapBundleFileName),
+	}
+	if localPath == "" && url == "" {
+		if target == nil {
+			return nil, errors.New("no target version set")
+		}
+		arch, err := h.Arch()
+		if err != nil {
+			return nil, fmt.Errorf("get host arch: %w", err)
+		}
+		b.url = target.AirgapDownloadURL(arch)
+		b.published = true
+	}
+	return b, nil
+}
+
+// Destination returns the path of the bundle on the host
+func (b *AirgapBundle) Destination() string {
+	return b.dest
+}
+
+// Source returns the local path or the URL of the bundle
+func (b *AirgapBundle) Source() string {
+	if b.localPath != "" {
+		return b.localPath
+	}
+	return b.url
+}
+
+// BinaryCacheKey returns the local path of the bundle, which is a path in the local cache for
+// downloaded bundles
+func (b *AirgapBundle) BinaryCacheKey() (string, error) {
+	if b.localPath != "" {
+		return b.localPath, nil
+	}
+	u, err := url.Parse(b.url)
+	if err != nil {
+		return "", fmt.Errorf("parse airgap bundle url: %w", err)
+	}
+	// the url hash keeps bundles with the same file name from different sources apart
+	sum := sha256.Sum256([]byte(b.url))
+	fn := path.Join("k0sctl", "k0s", "airgap", hex.EncodeToString(sum[:8]), path.Base(u.Path))
+	if cached, err := xdg.SearchCacheFile(fn); err == nil {
+		return cached, nil
+	}
+	return xdg.CacheFile(fn)
+}
+
+// EnsureCached downloads the bundle to the local cache if it is not already there and verifies it
+func (b *AirgapBundle) EnsureCached(ctx context.Context) error {
+	file, err := b.BinaryCacheKey()
+	if err != nil {
+		return err
+	}
+	verify := func(f string) error {
+		return b.verify(ctx, f)
+	}
+
+	if b.localPath != "" {
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("airgap bundle: %w", err)
+		}
+		return verify(file)
+	}
+
+	if _, err := os.Stat(file); err == nil {
+		verifyErr := verify(file)
+		if verifyErr == nil {
+			log.Debugf("using cached k0s airgap bundle %s", file)
+			return nil
+		}
+		if !errors.Is(verifyErr, ErrAirgapChecksumMismatch) {
+			return verifyErr
+		}
+		log.Warnf("cached k0s airgap bundle %s failed verification, downloading it again: %v", file, verifyErr)
+		if err := os.Remove(file); err != nil {
+			return fmt.Errorf("remove cached airgap bundle %s: %w", file, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stat airgap bundle cache path %s: %w", file, err)
+	}
+
+	log.Infof("downloading k0s airgap bundle from %s", b.url)
+	if err := downloadToFile(ctx, b.url, file, verify); err != nil {
+		return fmt.Errorf("download k0s airgap bundle: %w", err)
+	}
+	log.Debugf("cached k0s airgap bundle to %s", file)
+	return nil
+}
+
+// verify compares the checksum of the local file to the pinned or the published checksum
+func (b *AirgapBundle) verify(ctx context.Context, file string) error {
+	expected := b.checksum
+	if expected == "" && b.published {
+		sum, err := publishedChecksum(ctx, b.url)
+		if err != nil {
+			log.Warnf("failed to get the published checksum for %s: %v", b.url, err)
+		}
+		expected = sum
+	}
+	if expected == "" {
+		log.Debugf("no checksum available for the k0s airgap bundle %s, skipping verification", b.Source())
+		return nil
+	}
+	actual, err := FileSha256(file)
+	if err != nil {
+		return fmt.Errorf("calculate checksum of %s: %w", file, err)
+	}
+	if actual != expected {
+		return fmt.Errorf("%w: %s has sha256 %s, expected %s", ErrAirgapChecksumMismatch, file, actual, expected)
+	}
+	log.Debugf("verified checksum of %s", file)
+	return nil
+}
+
+// NeedsUpload returns false when the bundle on the host has the same checksum as the local bundle.
+// EnsureCached must be called first.
+func (b *AirgapBundle) NeedsUpload() (bool, error) {
+	file, err := b.BinaryCacheKey()
+	if err != nil {
+		return false, err
+	}
+	if !b.host.Sudo().FS().FileExist(b.dest) {
+		return true, nil
+	}
+	expected, err := FileSha256(file)
+	if err != nil {
+		return false, fmt.Errorf("calculate checksum of %s: %w", file, err)
+	}
+	actual, err := b.host.FileSha256(b.dest)
+	if err != nil {
+		log.Debugf("%s: failed to calculate the checksum of %s: %v", b.host, b.dest, err)
+		return true, nil
+	}
+	return NormalizeChecksum(actual) != expected, nil
+}
+
+// Upload uploads the bundle to the images directory on the host
+func (b *AirgapBundle) Upload() error {
+	file, err := b.BinaryCacheKey()
+	if err != nil {
+		return err
+	}
+	dir, err := b.host.Dir(b.dest)
+	if err != nil {
+		return err
+	}
+	if err := b.host.Sudo().FS().MkdirAll(dir, fs.FileMode(0o755)); err != nil {
+		return fmt.Errorf("create airgap bundle directory %s: %w", dir, err)
+	}
+	// the bundle is uploaded outside of the images directory first so that k0s never sees a partial bundle
+	tmp := path.Join(path.Dir(dir), "."+AirgapBundleFileName+".tmp")
+	log.Infof("%s: uploading k0s airgap bundle %s to %s", b.host, file, b.dest)
+	if err := remotefs.Upload(b.host.Sudo().FS(), file, tmp, remotefs.WithPermissions(fs.FileMode(0o644))); err != nil {
+		if rmErr := b.host.DeleteFile(tmp); rmErr != nil {
+			log.Debugf("%s: failed to remove partial airgap bundle %s: %v", b.host, tmp, rmErr)
+		}
+		return fmt.Errorf("upload k0s airgap bundle: %w", err)
+	}
+	if err := b.host.Sudo().FS().Rename(tmp, b.dest); err != nil {
+		return fmt.Errorf("move k0s airgap bundle to %s: %w", b.dest, err)
+	}
+	return nil
+}
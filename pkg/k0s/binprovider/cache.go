@@ -0,0 +1,184 @@
+package binprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/k0sproject/version"
+	log "github.com/sirupsen/logrus"
+)
+
+// Checksum statuses of the cached k0s binaries
+const (
+	ChecksumVerified = "verified"
+	ChecksumMismatch = "mismatch"
+	ChecksumUnknown  = "unknown"
+	ChecksumSkipped  = "-"
+)
+
+// CachedBinary is a k0s binary in the local cache
+type CachedBinary struct {
+	OS       string           `json:"os"`
+	Arch     string           `json:"arch"`
+	Version  *version.Version `json:"version"`
+	Path     string           `json:"path"`
+	Size     int64            `json:"size"`
+	Checksum string           `json:"checksum"`
+}
+
+// CacheDir returns the directory of the k0s binary cache
+func CacheDir() string {
+	return filepath.Join(xdg.CacheHome, "k0sctl", "k0s")
+}
+
+// ListCache returns the k0s binaries in the local cache sorted by os, arch and version. When
+// verify is true, the binaries are compared to the checksums published with the k0s releases.
+func ListCache(ctx context.Context, verify bool) ([]*CachedBinary, error) {
+	dir := CacheDir()
+	osDirs, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read k0s cache directory: %w", err)
+	}
+
+	var binaries []*CachedBinary
+	for _, osDir := range osDirs {
+		if !osDir.IsDir() {
+			continue
+		}
+		archDirs, err := os.ReadDir(filepath.Join(dir, osDir.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read k0s cache directory: %w", err)
+		}
+		for _, archDir := range archDirs {
+			if !archDir.IsDir() {
+				continue
+			}
+			files, err := os.ReadDir(filepath.Join(dir, osDir.Name(), archDir.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("read k0s cache directory: %w", err)
+			}
+			for _, f := range files {
+				b := parseCachedBinary(osDir.Name(), archDir.Name(), f.Name())
+				if b == nil {
+					continue
+				}
+				info, err := f.Info()
+				if err != nil {
+					return nil, fmt.Errorf("stat cached k0s binary: %w", err)
+				}
+				b.Path = filepath.Join(dir, osDir.Name(), archDir.Name(), f.Name())
+				b.Size = info.Size()
+				b.Checksum = ChecksumSkipped
+				if verify {
+					b.Checksum = cachedChecksumStatus(ctx, b)
+				}
+				binaries = append(binaries, b)
+			}
+		}
+	}
+
+	slices.SortFunc(binaries, func(a, b *CachedBinary) int {
+		if c := strings.Compare(a.OS, b.OS); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Arch, b.Arch); c != 0 {
+			return c
+		}
+		return a.Version.Compare(b.Version)
+	})
+	return binaries, nil
+}
+
+// parseCachedBinary parses a file name created by cacheFilePath, other files return nil
+func parseCachedBinary(osKind, arch, name string) *CachedBinary {
+	if osKind == "windows" {
+		if !strings.HasSuffix(name, ".exe") {
+			return nil
+		}
+		name = strings.TrimSuffix(name, ".exe")
+	}
+	if !strings.HasPrefix(name, "k0s-") || strings.Contains(name, ".tmp-") || strings.HasSuffix(name, checksumFileSuffix) {
+		return nil
+	}
+	v, err := version.NewVersion(strings.TrimPrefix(name, "k0s-"))
+	if err != nil {
+		return nil
+	}
+	return &CachedBinary{OS: osKind, Arch: arch, Version: v}
+}
+
+func cachedChecksumStatus(ctx context.Context, b *CachedBinary) string {
+	expected, err := publishedChecksum(ctx, b.Version.DownloadURL(b.OS, b.Arch))
+	if err != nil {
+		log.Debugf("failed to get the published checksum of k0s %s for %s-%s: %v", b.Version, b.OS, b.Arch, err)
+		return ChecksumUnknown
+	}
+	actual, err := FileSha256(b.Path)
+	if err != nil {
+		log.Debugf("failed to calculate the checksum of %s: %v", b.Path, err)
+		return ChecksumUnknown
+	}
+	if actual != NormalizeChecksum(expected) {
+		return ChecksumMismatch
+	}
+	return ChecksumVerified
+}
+
+// PrefetchBinary downloads the k0s release binary to the local cache unless a verified copy
+// is already there and returns the path of the cached binary
+func PrefetchBinary(ctx context.Context, osKind, arch string, v *version.Version) (string, error) {
+	return ensureCachedBinary(ctx, nil, osKind, arch, v)
+}
+
+// PruneCache removes all but the keep newest versions of each os and arch combination from the
+// local cache and returns the removed binaries. When dryRun is true, nothing is removed.
+func PruneCache(keep int, dryRun bool) ([]*CachedBinary, error) {
+	if keep < 0 {
+		return nil, fmt.Errorf("invalid number of versions to keep: %d", keep)
+	}
+	binaries, err := ListCache(context.Background(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]*CachedBinary)
+	var keys []string
+	for _, b := range binaries {
+		key := b.OS + "/" + b.Arch
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], b)
+	}
+
+	var removed []*CachedBinary
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) <= keep {
+			continue
+		}
+		// the groups are sorted by version, oldest first
+		for _, b := range group[:len(group)-keep] {
+			if !dryRun {
+				if err := os.Remove(b.Path); err != nil {
+					return removed, fmt.Errorf("remove cached k0s binary %s: %w", b.Path, err)
+				}
+				if err := os.Remove(b.Path + checksumFileSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return removed, fmt.Errorf("remove the recorded checksum of %s: %w", b.Path, err)
+				}
+			}
+			log.Debugf("removed cached k0s binary %s", b.Path)
+			removed = append(removed, b)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,66 @@
+package binprovider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/k0sproject/version"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCachedBinary(t *testing.T) {
+	b := parseCachedBinary("linux", "amd64", "k0s-1.30.0+k0s.0")
+	require.NotNil(t, b)
+	require.Equal(t, "v1.30.0+k0s.0", b.Version.String())
+
+	require.NotNil(t, parseCachedBinary("windows", "amd64", "k0s-1.30.0+k0s.0.exe"))
+	require.Nil(t, parseCachedBinary("windows", "amd64", "k0s-1.30.0+k0s.0"))
+	require.Nil(t, parseCachedBinary("linux", "amd64", "k0s-1.30.0+k0s.0.tmp-123"))
+	require.Nil(t, parseCachedBinary("linux", "amd64", "k0s-1.30.0+k0s.0.sha256"))
+	require.Nil(t, parseCachedBinary("linux", "amd64", "k0s-airgap-bundle-v1.30.0+k0s.0-amd64"))
+}
+
+func TestPruneCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	for _, v := range []string{"v1.29.1+k0s.0", "v1.30.0+k0s.0", "v1.28.5+k0s.0"} {
+		for _, arch := range []string{"amd64", "arm64"} {
+			file, err := cacheFilePath("linux", arch, version.MustParse(v))
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(file, []byte(v), 0o755))
+		}
+	}
+	// leftovers of other caches are not touched
+	other := filepath.Join(CacheDir(), "airgap", "0123abcd", "k0s-airgap-bundle-v1.28.5+k0s.0-amd64")
+	require.NoError(t, os.MkdirAll(filepath.Dir(other), 0o755))
+	require.NoError(t, os.WriteFile(other, []byte("bundle"), 0o644))
+
+	binaries, err := ListCache(context.Background(), false)
+	require.NoError(t, err)
+	require.Len(t, binaries, 6)
+	require.Equal(t, "v1.28.5+k0s.0", binaries[0].Version.String())
+	require.Equal(t, ChecksumSkipped, binaries[0].Checksum)
+
+	removed, err := PruneCache(2, true)
+	require.NoError(t, err)
+	require.Len(t, removed, 2)
+	binaries, err = ListCache(context.Background(), false)
+	require.NoError(t, err)
+	require.Len(t, binaries, 6)
+
+	removed, err = PruneCache(1, false)
+	require.NoError(t, err)
+	require.Len(t, removed, 4)
+	binaries, err = ListCache(context.Background(), false)
+	require.NoError(t, err)
+	require.Len(t, binaries, 2)
+	for _, b := range binaries {
+		require.Equal(t, "v1.30.0+k0s.0", b.Version.String())
+	}
+	require.FileExists(t, other)
+}
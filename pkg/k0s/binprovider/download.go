@@ -3,6 +3,7 @@ package binprovider
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/k0sproject/k0sctl/pkg/k0s"
 	"github.com/k0sproject/version"
@@ -15,6 +16,8 @@ type downloadProvider struct {
 	installPath string
 	urlFor      func(*version.Version) (string, error)
 	target      *version.Version
+	// published is true when a sha256sums.txt is published next to the binary
+	published bool
 }
 
 func (p *downloadProvider) IsUpload() bool { return false }
@@ -23,7 +26,7 @@ func (p *downloadProvider) NeedsUpgrade() bool {
 	return needsUpgrade(p.host, p.target, p.host.InstalledK0sVersion(), p.host.RunningK0sVersion())
 }
 
-func (p *downloadProvider) Stage(_ context.Context) (string, error) {
+func (p *downloadProvider) Stage(ctx context.Context) (string, error) {
 	if p.target == nil {
 		return "", errors.New("no target version set")
 	}
@@ -36,15 +39,52 @@ func (p *downloadProvider) Stage(_ context.Context) (string, error) {
 		return "", err
 	}
 	p.tmpPath = tmp
+	if err := verifyRemoteBinary(ctx, p.host, tmp, url, p.target, p.published); err != nil {
+		p.CleanUp(ctx)
+		return "", err
+	}
 	return tmp, nil
 }
 
+// DownloadURL returns the URL of the binary for the target version
+func (p *downloadProvider) DownloadURL() (string, error) {
+	if p.target == nil {
+		return "", errors.New("no target version set")
+	}
+	return p.urlFor(p.target)
+}
+
+// DownloadSha256 returns the pinned or published checksum of the binary for the target version.
+// A checksum is required when a signature is configured because the hosts can only verify the
+// downloaded binary with a checksum.
+func (p *downloadProvider) DownloadSha256(ctx context.Context) (string, error) {
+	url, err := p.DownloadURL()
+	if err != nil {
+		return "", err
+	}
+	arch, err := p.host.Arch()
+	if err != nil {
+		return "", fmt.Errorf("get host arch: %w", err)
+	}
+	verifier := p.host.K0sBinaryVerifier()
+	sum, err := verifier.requireChecksum(ctx, arch, url, p.published)
+	if err != nil {
+		return "", err
+	}
+	if sum == "" && verifier.signatureEnabled() {
+		return "", fmt.Errorf("%w: the signature of the binary can not be verified on the host, pin the checksum for %s in spec.k0s.verify.checksums", ErrChecksumUnavailable, arch)
+	}
+	return sum, nil
+}
+
 // NewGitHub returns a BinaryProvider that fetches the k0s binary from GitHub.
+// The binary is verified against the sha256sums.txt published with the release.
 func NewGitHub(h Host, installPath string, target *version.Version) k0s.BinaryProvider {
 	return &downloadProvider{
 		stagedFile:  stagedFile{host: h},
 		installPath: installPath,
 		target:      target,
+		published:   true,
 		urlFor: func(v *version.Version) (string, error) {
 			arch, err := h.Arch()
 			if err != nil {
@@ -61,6 +101,7 @@ func NewGitHub(h Host, installPath string, target *version.Version) k0s.BinaryPr
 
 // NewCustomURL returns a BinaryProvider that downloads k0s from a user-supplied URL.
 // urlFor is called at Stage time to resolve the final URL for the target version.
+// The binary is verified against the pinned checksums of the host's Verifier, if any.
 func NewCustomURL(h Host, installPath string, urlFor func(*version.Version) string, target *version.Version) k0s.BinaryProvider {
 	return &downloadProvider{
 		stagedFile:  stagedFile{host: h},
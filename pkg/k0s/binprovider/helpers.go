@@ -2,12 +2,16 @@ package binprovider
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/k0sproject/rig/v2/remotefs"
@@ -145,3 +149,73 @@ func stageDownload(h Host, url, installPath string, targetVersion *version.Versi
 
 	return tmp, nil
 }
+
+// stagePeer downloads the binary from a peer host and verifies it against the checksum of the
+// local source file the peer received the binary from.
+func stagePeer(h Host, url, src, installPath string, target *version.Version) (string, error) {
+	expected, err := FileSha256(src)
+	if err != nil {
+		return "", fmt.Errorf("calculate checksum of %s: %w", src, err)
+	}
+	tmp, err := stageDownload(h, url, installPath, target)
+	if err != nil {
+		return "", err
+	}
+	actual, err := h.FileSha256(tmp)
+	if err == nil && NormalizeChecksum(actual) != expected {
+		err = fmt.Errorf("%w: the binary from %s has sha256 %s, expected %s", ErrChecksumMismatch, url, NormalizeChecksum(actual), expected)
+	}
+	if err != nil {
+		if rmErr := h.DeleteFile(tmp); rmErr != nil {
+			log.Debugf("%s: failed to remove staged temp binary %s: %v", h, tmp, rmErr)
+		}
+		return "", err
+	}
+	return tmp, nil
+}
+
+// hashFile returns the hex encoded sha256 checksum of a local file
+func hashFile(file string) (sum string, retErr error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := f.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type localChecksum struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+var localChecksums sync.Map // path -> localChecksum
+
+// FileSha256 returns the hex encoded sha256 checksum of a local file. The checksums are cached by the
+// file size and modification time because the same cached binary is verified for many hosts.
+func FileSha256(file string) (string, error) {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+	if c, ok := localChecksums.Load(file); ok {
+		if c := c.(localChecksum); c.size == stat.Size() && c.modTime.Equal(stat.ModTime()) {
+			return c.sum, nil
+		}
+	}
+	sum, err := hashFile(file)
+	if err != nil {
+		return "", err
+	}
+	localChecksums.Store(file, localChecksum{size: stat.Size(), modTime: stat.ModTime(), sum: sum})
+	return sum, nil
+}
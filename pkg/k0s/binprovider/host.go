@@ -39,4 +39,10 @@ type Host interface {
 	Touch(path string, modTime time.Time) error
 	DeleteFile(path string) error
 	SetFileMode(path string, mode fs.FileMode) error
+	// FileSha256 returns the hex encoded sha256 checksum of a file on the host.
+	FileSha256(path string) (string, error)
+
+	// K0sBinaryVerifier returns the configured binary verification or nil when
+	// only the published checksums should be used.
+	K0sBinaryVerifier() *Verifier
 }
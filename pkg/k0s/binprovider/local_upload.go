@@ -2,6 +2,8 @@ package binprovider
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -26,7 +28,10 @@ type localUpload struct {
 	target      *version.Version
 }
 
-var _ k0s.BinaryCacher = (*localUpload)(nil)
+var (
+	_ k0s.BinaryCacher = (*localUpload)(nil)
+	_ k0s.PeerStager   = (*localUpload)(nil)
+)
 
 var downloadHTTPClient = &http.Client{
 	Timeout: 10 * time.Minute,
@@ -81,26 +86,53 @@ func (p *localUpload) EnsureCached(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("get host os kind: %w", err)
 	}
-	dest, err := cacheFilePath(osKind, arch, p.target)
+	_, err = ensureCachedBinary(ctx, p.host.K0sBinaryVerifier(), osKind, arch, p.target)
+	return err
+}
+
+// ensureCachedBinary downloads the k0s release binary to the local cache unless a verified copy
+// is already there and returns the path of the cached binary
+func ensureCachedBinary(ctx context.Context, verifier *Verifier, osKind, arch string, v *version.Version) (string, error) {
+	dest, err := cacheFilePath(osKind, arch, v)
 	if err != nil {
-		return fmt.Errorf("prepare k0s cache path: %w", err)
+		return "", fmt.Errorf("prepare k0s cache path: %w", err)
 	}
+	url := v.DownloadURL(osKind, arch)
 	if _, err := os.Stat(dest); err == nil {
-		log.Debugf("using cached k0s %s binary for %s-%s from %s", p.target, osKind, arch, dest)
-		return nil
+		verifyErr := verifyCachedFile(ctx, verifier, dest, arch, url, v)
+		if verifyErr == nil {
+			log.Debugf("using cached k0s %s binary for %s-%s from %s", v, osKind, arch, dest)
+			return dest, nil
+		}
+		if !errors.Is(verifyErr, ErrChecksumMismatch) && !errors.Is(verifyErr, ErrSignatureInvalid) {
+			return "", fmt.Errorf("verify cached k0s binary: %w", verifyErr)
+		}
+		log.Warnf("cached k0s binary %s failed verification, downloading it again: %v", dest, verifyErr)
+		if err := os.Remove(dest); err != nil {
+			return "", fmt.Errorf("remove cached k0s binary %s: %w", dest, err)
+		}
 	} else if !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("stat k0s cache path %s: %w", dest, err)
+		return "", fmt.Errorf("stat k0s cache path %s: %w", dest, err)
+	}
+	log.Infof("downloading k0s %s binary for %s-%s", v, osKind, arch)
+	verify := func(file string) error {
+		return verifyLocalFile(ctx, verifier, file, arch, url, v)
 	}
-	url := p.target.DownloadURL(osKind, arch)
-	log.Infof("downloading k0s %s binary for %s-%s", p.target, osKind, arch)
-	if err := downloadToFile(ctx, url, dest); err != nil {
-		return fmt.Errorf("download k0s binary: %w", err)
+	if err := downloadToFile(ctx, url, dest, verify); err != nil {
+		return "", fmt.Errorf("download k0s binary: %w", err)
 	}
 	log.Debugf("cached k0s binary to %s", dest)
-	return nil
+	return dest, nil
 }
 
-func downloadToFile(ctx context.Context, url, dest string) (retErr error) {
+// checksumFileSuffix is appended to the path of a downloaded file for the file that records
+// its sha256 checksum
+const checksumFileSuffix = ".sha256"
+
+// downloadToFile downloads the url to dest. The verify function is called for the
+// downloaded temporary file before it is moved into place. The checksum of the verified
+// file is recorded next to it, so that it can later be verified without network access.
+func downloadToFile(ctx context.Context, url, dest string, verify func(string) error) (retErr error) {
 	dir := filepath.Dir(dest)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return err
@@ -138,7 +170,8 @@ func downloadToFile(ctx context.Context, url, dest string) (retErr error) {
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("unexpected http status %s from %s", resp.Status, url)
 	}
-	if _, err := io.Copy(tmpFile, resp.Body); err != nil {
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmpFile, hasher), resp.Body); err != nil {
 		return err
 	}
 	if err := tmpFile.Sync(); err != nil {
@@ -149,6 +182,12 @@ func downloadToFile(ctx context.Context, url, dest string) (retErr error) {
 		return err
 	}
 	tmpFile = nil
+	if err := verify(tmpPath); err != nil {
+		return err
+	}
+	if err := os.Remove(dest + checksumFileSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove the previously recorded checksum: %w", err)
+	}
 	// os.Rename is atomic on Unix (replaces dest if it exists), so concurrent runs are safe.
 	// On Windows it fails if dest already exists; two simultaneous k0sctl processes targeting
 	// the same version/arch could race here. We intentionally propagate that error rather than
@@ -156,9 +195,17 @@ func downloadToFile(ctx context.Context, url, dest string) (retErr error) {
 	if err := os.Rename(tmpPath, dest); err != nil {
 		return err
 	}
+	if err := os.WriteFile(dest+checksumFileSuffix, []byte(hex.EncodeToString(hasher.Sum(nil))+"\n"), 0o644); err != nil {
+		log.Warnf("failed to record the checksum of %s: %v", dest, err)
+	}
 	return nil
 }
 
+// DownloadFile downloads the url to dest on the local machine
+func DownloadFile(ctx context.Context, url, dest string) error {
+	return downloadToFile(ctx, url, dest, func(string) error { return nil })
+}
+
 func (p *localUpload) IsUpload() bool { return true }
 
 func (p *localUpload) NeedsUpgrade() bool {
@@ -195,6 +242,20 @@ func (p *localUpload) Stage(ctx context.Context) (string, error) {
 	return tmp, nil
 }
 
+// StageFromPeer downloads the binary from a peer host that has received it from the local cache.
+func (p *localUpload) StageFromPeer(_ context.Context, url string) (string, error) {
+	cachePath, err := p.BinaryCacheKey()
+	if err != nil {
+		return "", fmt.Errorf("locate k0s cache: %w", err)
+	}
+	tmp, err := stagePeer(p.host, url, cachePath, p.installPath, p.target)
+	if err != nil {
+		return "", err
+	}
+	p.tmpPath = tmp
+	return tmp, nil
+}
+
 // NewLocalUpload returns a BinaryProvider that downloads k0s to a local cache and uploads it to the host.
 func NewLocalUpload(h Host, installPath string, target *version.Version) k0s.BinaryProvider {
 	return &localUpload{stagedFile: stagedFile{host: h}, installPath: installPath, target: target}
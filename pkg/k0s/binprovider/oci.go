@@ -0,0 +1,322 @@
+package binprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/k0sproject/k0sctl/pkg/k0s"
+	"github.com/k0sproject/k0sctl/pkg/oci"
+	"github.com/k0sproject/rig/v2/cmd"
+	ps "github.com/k0sproject/rig/v2/powershell"
+	"github.com/k0sproject/version"
+	log "github.com/sirupsen/logrus"
+)
+
+// ociProvider pulls the k0s binary from an OCI registry. The artifact is resolved on the
+// local machine using the registry credentials from the docker config files. With upload
+// set the binary is pulled into the local cache and uploaded to the host, otherwise the
+// host downloads the blob itself using a short lived token obtained by k0sctl.
+type ociProvider struct {
+	stagedFile
+	installPath string
+	refFor      func(*version.Version) string
+	target      *version.Version
+	upload      bool
+	client      *oci.Client
+}
+
+var (
+	_ k0s.BinaryCacher = (*ociProvider)(nil)
+	_ k0s.PeerStager   = (*ociProvider)(nil)
+)
+
+// NewOCI returns a BinaryProvider that pulls k0s from an OCI registry. refFor is called to
+// resolve the oci:// reference for the target version. When upload is true, the binary is
+// pulled to the local cache and uploaded to the host, otherwise it is downloaded on the host.
+func NewOCI(h Host, installPath string, refFor func(*version.Version) string, target *version.Version, upload bool) k0s.BinaryProvider {
+	return &ociProvider{
+		stagedFile:  stagedFile{host: h},
+		installPath: installPath,
+		refFor:      refFor,
+		target:      target,
+		upload:      upload,
+		client:      &oci.Client{HTTPClient: downloadHTTPClient},
+	}
+}
+
+func (p *ociProvider) IsUpload() bool { return p.upload }
+
+func (p *ociProvider) NeedsUpgrade() bool {
+	return needsUpgrade(p.host, p.target, p.host.InstalledK0sVersion(), p.host.RunningK0sVersion())
+}
+
+// reference returns the parsed reference and the platform of the host
+func (p *ociProvider) reference() (*oci.Reference, oci.Platform, error) {
+	if p.target == nil {
+		return nil, oci.Platform{}, errors.New("no target version set")
+	}
+	arch, err := p.host.Arch()
+	if err != nil {
+		return nil, oci.Platform{}, fmt.Errorf("get host arch: %w", err)
+	}
+	osKind, err := p.host.OSKind()
+	if err != nil {
+		return nil, oci.Platform{}, fmt.Errorf("get host os kind: %w", err)
+	}
+	ref, err := oci.ParseReference(p.refFor(p.target))
+	if err != nil {
+		return nil, oci.Platform{}, err
+	}
+	return ref, oci.Platform{OS: osKind, Architecture: arch}, nil
+}
+
+func (p *ociProvider) resolve(ctx context.Context) (*oci.Blob, *oci.Reference, oci.Platform, error) {
+	ref, platform, err := p.reference()
+	if err != nil {
+		return nil, nil, platform, err
+	}
+	blob, err := p.client.Resolve(ctx, ref, platform)
+	if err != nil {
+		return nil, nil, platform, fmt.Errorf("resolve %s: %w", ref, err)
+	}
+	log.Debugf("%s: resolved %s for %s to %s", p.host, ref, platform, blob.Digest)
+	return blob, ref, platform, nil
+}
+
+// ociCacheFilePath returns the local cache path for the reference. The registry, repository
+// and tag or digest are used as the path so that different references never share a file.
+func ociCacheFilePath(ref *oci.Reference, platform oci.Platform) (string, error) {
+	ext := ""
+	if platform.OS == "windows" {
+		ext = ".exe"
+	}
+	tag := ref.Tag
+	if ref.Digest != "" {
+		tag = strings.ReplaceAll(ref.Digest, ":", "-")
+	}
+	registry := strings.ReplaceAll(ref.Registry, ":", "_")
+	fn := path.Join("k0sctl", "oci", registry, ref.Repository, tag, platform.OS, platform.Architecture, "k0s"+ext)
+	if cached, err := xdg.SearchCacheFile(fn); err == nil {
+		return cached, nil
+	}
+	return xdg.CacheFile(fn)
+}
+
+func (p *ociProvider) BinaryCacheKey() (string, error) {
+	ref, platform, err := p.reference()
+	if err != nil {
+		return "", err
+	}
+	return ociCacheFilePath(ref, platform)
+}
+
+// EnsureCached pulls the binary into the local cache. The reference is always resolved
+// because a tag may have been moved, a cached file that matches the digest is reused.
+func (p *ociProvider) EnsureCached(ctx context.Context) error {
+	blob, ref, platform, err := p.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	dest, err := ociCacheFilePath(ref, platform)
+	if err != nil {
+		return fmt.Errorf("prepare k0s cache path: %w", err)
+	}
+	verify := func(file string) error {
+		return p.verifyLocal(ctx, file, platform.Architecture)
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		if sum, err := hashFile(dest); err == nil && sum == blob.Sha256() {
+			log.Debugf("using cached k0s binary %s for %s from %s", ref, platform, dest)
+			return verify(dest)
+		}
+		log.Infof("cached k0s binary %s does not match %s, pulling it again", dest, blob.Digest)
+		if err := os.Remove(dest); err != nil {
+			return fmt.Errorf("remove cached k0s binary %s: %w", dest, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stat k0s cache path %s: %w", dest, err)
+	}
+
+	log.Infof("pulling k0s binary %s for %s", ref, platform)
+	if err := pullToFile(ctx, p.client, blob, dest, verify); err != nil {
+		return fmt.Errorf("pull k0s binary: %w", err)
+	}
+	log.Debugf("cached k0s binary to %s", dest)
+	return nil
+}
+
+// verifyLocal applies the spec.k0s.verify checksums and signature, the digest has already
+// been verified
+func (p *ociProvider) verifyLocal(ctx context.Context, file, arch string) error {
+	verifier := p.host.K0sBinaryVerifier()
+	if verifier == nil {
+		return nil
+	}
+	return verifyLocalFile(ctx, verifier, file, arch, "", p.target)
+}
+
+// pullToFile downloads the blob to dest through a temporary file, the blob digest is
+// verified while downloading and verify is called before the file is moved into place
+func pullToFile(ctx context.Context, client *oci.Client, blob *oci.Blob, dest string, verify func(string) error) (retErr error) {
+	dir := filepath.Dir(dest)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(dir, filepath.Base(dest)+".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		if tmpFile != nil {
+			if err := tmpFile.Close(); err != nil && retErr == nil {
+				retErr = err
+			}
+		}
+		if retErr != nil {
+			if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+				log.Warnf("failed to remove partial download at %s: %v", tmpPath, err)
+			}
+		}
+	}()
+	if err := client.Fetch(ctx, blob, tmpFile); err != nil {
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		tmpFile = nil
+		return err
+	}
+	tmpFile = nil
+	if err := verify(tmpPath); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, dest)
+}
+
+func (p *ociProvider) Stage(ctx context.Context) (string, error) {
+	if p.upload {
+		return p.stageUpload()
+	}
+	return p.stageDownload(ctx)
+}
+
+func (p *ociProvider) stageUpload() (string, error) {
+	cachePath, err := p.BinaryCacheKey()
+	if err != nil {
+		return "", fmt.Errorf("locate k0s cache: %w", err)
+	}
+	if _, err := os.Stat(cachePath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("k0s binary not in local cache at %s: EnsureCached must be called first", cachePath)
+		}
+		return "", fmt.Errorf("stat k0s cache path: %w", err)
+	}
+	tmp, err := stageUpload(p.host, cachePath, p.installPath)
+	if err != nil {
+		return "", err
+	}
+	p.tmpPath = tmp
+	return tmp, nil
+}
+
+// StageFromPeer downloads the binary from a peer host that has received it from the local cache.
+func (p *ociProvider) StageFromPeer(_ context.Context, url string) (string, error) {
+	if !p.upload {
+		return "", errors.New("the binary is not pulled to the local cache")
+	}
+	cachePath, err := p.BinaryCacheKey()
+	if err != nil {
+		return "", fmt.Errorf("locate k0s cache: %w", err)
+	}
+	tmp, err := stagePeer(p.host, url, cachePath, p.installPath, p.target)
+	if err != nil {
+		return "", err
+	}
+	p.tmpPath = tmp
+	return tmp, nil
+}
+
+func (p *ociProvider) stageDownload(ctx context.Context) (string, error) {
+	blob, ref, _, err := p.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	tmp := stageTempPath(p.host, p.installPath)
+	dir, err := p.host.Dir(p.installPath)
+	if err != nil {
+		return "", err
+	}
+	if err := p.host.Sudo().FS().MkdirAll(dir, fs.FileMode(0o755)); err != nil {
+		return "", fmt.Errorf("failed to create k0s install directory: %w", err)
+	}
+
+	log.Infof("%s: pulling k0s binary %s", p.host, ref)
+	p.tmpPath = tmp
+	if err := downloadBlobOnHost(p.host, blob, tmp); err != nil {
+		p.CleanUp(ctx)
+		return "", fmt.Errorf("failed to pull k0s binary: %w", err)
+	}
+
+	actual, err := p.host.FileSha256(tmp)
+	if err != nil {
+		p.CleanUp(ctx)
+		return "", fmt.Errorf("calculate k0s binary checksum: %w", err)
+	}
+	if NormalizeChecksum(actual) != blob.Sha256() {
+		p.CleanUp(ctx)
+		return "", fmt.Errorf("%w: %s has sha256 %s, expected %s", ErrChecksumMismatch, ref, NormalizeChecksum(actual), blob.Digest)
+	}
+	log.Debugf("%s: verified k0s binary digest %s", p.host, blob.Digest)
+
+	if p.host.K0sBinaryVerifier() != nil {
+		if err := verifyRemoteBinary(ctx, p.host, tmp, ref.String(), p.target, false); err != nil {
+			p.CleanUp(ctx)
+			return "", err
+		}
+	}
+
+	if !p.host.IsWindows() {
+		if err := p.host.SetFileMode(tmp, fs.FileMode(0o755)); err != nil {
+			log.Debugf("%s: chmod %s failed: %v", p.host, tmp, err)
+		}
+	}
+	return tmp, nil
+}
+
+// downloadBlobOnHost downloads the blob to dest on the host. The authorization header is
+// passed on stdin to keep the token out of the process list, to curl in a config file and
+// to powershell as a line read from stdin.
+func downloadBlobOnHost(h Host, blob *oci.Blob, dest string) error {
+	if h.IsWindows() {
+		headers := ""
+		opts := []cmd.ExecOption{cmd.Sensitive()}
+		if blob.Authorization != "" {
+			headers = " -Headers @{Authorization=[Console]::In.ReadLine()}"
+			opts = append(opts, cmd.StdinString(blob.Authorization+"\n"))
+		}
+		c := fmt.Sprintf("$ProgressPreference='SilentlyContinue'; Invoke-WebRequest -UseBasicParsing -Uri %s%s -OutFile %s", ps.SingleQuote(blob.URL), headers, ps.SingleQuote(dest))
+		return h.Sudo().Exec(ps.Cmd(c), opts...)
+	}
+
+	var config strings.Builder
+	fmt.Fprintf(&config, "url = %s\n", curlConfigQuote(blob.URL))
+	fmt.Fprintf(&config, "output = %s\n", curlConfigQuote(dest))
+	if blob.Authorization != "" {
+		fmt.Fprintf(&config, "header = %s\n", curlConfigQuote("Authorization: "+blob.Authorization))
+	}
+	// curl does not send the authorization header when the registry redirects to another host
+	return h.Sudo().Exec("curl --fail --silent --show-error --location --retry 3 --config -", cmd.StdinString(config.String()), cmd.Sensitive())
+}
+
+// curlConfigQuote quotes a value for a curl config file
+func curlConfigQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + `"`
+}
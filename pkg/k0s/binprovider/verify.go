@@ -0,0 +1,447 @@
+package binprovider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/k0sproject/version"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	// ErrChecksumMismatch is returned when the checksum of a k0s binary does not match the expected checksum
+	ErrChecksumMismatch = errors.New("k0s binary checksum mismatch")
+	// ErrSignatureInvalid is returned when the signature of a k0s binary can not be verified
+	ErrSignatureInvalid = errors.New("k0s binary signature verification failed")
+	// ErrChecksumUnavailable is returned when a k0s binary must be verified but no checksum or
+	// signature for it can be found
+	ErrChecksumUnavailable = errors.New("no checksum available for the k0s binary")
+)
+
+// Signature types supported by the Verifier
+const (
+	SignatureCosign   = "cosign"
+	SignatureMinisign = "minisign"
+)
+
+// publishedChecksumsFile is the name of the checksum file published with the k0s releases
+const publishedChecksumsFile = "sha256sums.txt"
+
+// maxVerificationFileSize limits the size of the downloaded checksum and signature files
+const maxVerificationFileSize = 1 << 20
+
+// Verifier verifies the integrity of the k0s binaries. A nil Verifier only uses the
+// checksums published with the k0s releases.
+type Verifier struct {
+	// Checksums are pinned sha256 checksums of the k0s binary per architecture. They take
+	// precedence over the published checksums.
+	Checksums map[string]string
+	// SignatureType is SignatureCosign or SignatureMinisign, empty disables signature verification
+	SignatureType string
+	// PublicKey is the PEM encoded cosign public key or the content of a minisign public key file
+	PublicKey []byte
+	// SignatureURL returns the URL of the signature for the binary. When nil, the signature is
+	// expected next to the binary with a .sig (cosign) or .minisig (minisign) suffix.
+	SignatureURL func(binaryURL string, v *version.Version) string
+}
+
+// pinnedChecksum returns the pinned checksum for the architecture as lower case hex
+func (v *Verifier) pinnedChecksum(arch string) string {
+	if v == nil {
+		return ""
+	}
+	return NormalizeChecksum(v.Checksums[arch])
+}
+
+func (v *Verifier) signatureEnabled() bool {
+	return v != nil && v.SignatureType != ""
+}
+
+// configured returns true when checksums or a signature have been configured
+func (v *Verifier) configured() bool {
+	return v != nil && (len(v.Checksums) > 0 || v.SignatureType != "")
+}
+
+// NormalizeChecksum returns a checksum in lower case hex without the optional "sha256:" prefix
+func NormalizeChecksum(s string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "sha256:"))
+}
+
+// expectedChecksum returns the pinned checksum for the architecture or, when binaryURL is set,
+// the checksum published next to the binary. An empty string is returned when there is no pinned
+// checksum and binaryURL is empty.
+func (v *Verifier) expectedChecksum(ctx context.Context, arch, binaryURL string) (string, error) {
+	if sum := v.pinnedChecksum(arch); sum != "" {
+		return sum, nil
+	}
+	if binaryURL == "" {
+		return "", nil
+	}
+	sum, err := publishedChecksum(ctx, binaryURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: get the published checksum for %s: %w", ErrChecksumUnavailable, binaryURL, err)
+	}
+	return NormalizeChecksum(sum), nil
+}
+
+// requireChecksum returns the expected checksum of the binary. The binary must be verified when
+// it is published with checksums or when checksums or a signature have been configured, an error
+// is returned when neither a checksum nor a signature is available for it.
+func (v *Verifier) requireChecksum(ctx context.Context, arch, binaryURL string, published bool) (string, error) {
+	lookupURL := binaryURL
+	if !published {
+		lookupURL = ""
+	}
+	expected, err := v.expectedChecksum(ctx, arch, lookupURL)
+	if err != nil {
+		return "", err
+	}
+	if expected == "" && !v.signatureEnabled() && (published || v.configured()) {
+		return "", fmt.Errorf("%w: no checksum configured for %s in spec.k0s.verify", ErrChecksumUnavailable, arch)
+	}
+	return expected, nil
+}
+
+var publishedChecksums sync.Map // checksum file url -> map[string]string
+
+// publishedChecksum looks up the checksum of the binary from the sha256sums.txt published
+// in the same location as the binary. The checksum files are only downloaded once.
+func publishedChecksum(ctx context.Context, binaryURL string) (string, error) {
+	u, err := url.Parse(binaryURL)
+	if err != nil {
+		return "", fmt.Errorf("parse url: %w", err)
+	}
+	name, err := url.PathUnescape(path.Base(u.EscapedPath()))
+	if err != nil {
+		return "", fmt.Errorf("parse url: %w", err)
+	}
+	u.RawPath = ""
+	u.Path = path.Join(path.Dir(u.Path), publishedChecksumsFile)
+	u.RawQuery = ""
+	sumsURL := u.String()
+
+	sums, ok := publishedChecksums.Load(sumsURL)
+	if !ok {
+		data, err := fetchVerificationFile(ctx, sumsURL)
+		if err != nil {
+			return "", err
+		}
+		sums = parseChecksums(data)
+		publishedChecksums.Store(sumsURL, sums)
+	}
+
+	sum, ok := sums.(map[string]string)[name]
+	if !ok {
+		return "", fmt.Errorf("%s not found in %s", name, sumsURL)
+	}
+	return sum, nil
+}
+
+// parseChecksums parses the output of sha256sum into a map of file names to checksums
+func parseChecksums(data []byte) map[string]string {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return sums
+}
+
+func fetchVerificationFile(ctx context.Context, url string) (data []byte, retErr error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := downloadHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected http status %s from %s", resp.Status, url)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxVerificationFileSize))
+}
+
+// verifyRemoteBinary verifies a binary that has been downloaded directly on the host. The checksum
+// is calculated on the host so that the binary does not need to be transferred back.
+func verifyRemoteBinary(ctx context.Context, h Host, tmpPath, binaryURL string, target *version.Version, published bool) error {
+	verifier := h.K0sBinaryVerifier()
+	arch, err := h.Arch()
+	if err != nil {
+		return fmt.Errorf("get host arch: %w", err)
+	}
+	expected, err := verifier.requireChecksum(ctx, arch, binaryURL, published)
+	if err != nil {
+		return err
+	}
+	if expected == "" && !verifier.signatureEnabled() {
+		log.Debugf("%s: no checksums or signature configured for the k0s binary from %s, skipping verification", h, binaryURL)
+		return nil
+	}
+
+	actual, err := h.FileSha256(tmpPath)
+	if err != nil {
+		return fmt.Errorf("calculate k0s binary checksum: %w", err)
+	}
+	actual = NormalizeChecksum(actual)
+	if expected != "" {
+		if actual != expected {
+			return fmt.Errorf("%w: %s has sha256 %s, expected %s", ErrChecksumMismatch, binaryURL, actual, expected)
+		}
+		log.Infof("%s: verified k0s binary checksum", h)
+	}
+
+	if verifier.signatureEnabled() {
+		digest, err := hex.DecodeString(actual)
+		if err != nil {
+			return fmt.Errorf("decode k0s binary checksum: %w", err)
+		}
+		if err := verifier.verifySignature(ctx, binaryURL, target, digest, nil); err != nil {
+			return err
+		}
+		log.Infof("%s: verified k0s binary signature", h)
+	}
+	return nil
+}
+
+// verifyLocalFile verifies a binary in the local cache. A binaryURL is given for the published
+// k0s release binaries, their checksum is looked up from the checksums published with the release.
+func verifyLocalFile(ctx context.Context, verifier *Verifier, file, arch, binaryURL string, target *version.Version) error {
+	expected, err := verifier.requireChecksum(ctx, arch, binaryURL, binaryURL != "")
+	if err != nil {
+		return err
+	}
+	return verifyFile(ctx, verifier, file, expected, binaryURL, target)
+}
+
+// verifyCachedFile verifies a binary that is already in the local cache. A pinned checksum is
+// preferred over the checksum recorded when the binary was downloaded. The published checksum is
+// only looked up when neither is available and the binary is used with a warning when it can not
+// be fetched, so that a cache filled in advance can be used offline.
+func verifyCachedFile(ctx context.Context, verifier *Verifier, file, arch, binaryURL string, target *version.Version) error {
+	expected := verifier.pinnedChecksum(arch)
+	if expected == "" {
+		expected = recordedChecksum(file)
+	}
+	if expected == "" && binaryURL != "" {
+		sum, err := verifier.expectedChecksum(ctx, arch, binaryURL)
+		if err != nil {
+			log.Warnf("can not verify the checksum of the cached k0s binary %s: %v", file, err)
+		}
+		expected = sum
+	}
+	if expected == "" && !verifier.signatureEnabled() && verifier.configured() {
+		return fmt.Errorf("%w: no checksum configured for %s in spec.k0s.verify", ErrChecksumUnavailable, arch)
+	}
+	return verifyFile(ctx, verifier, file, expected, binaryURL, target)
+}
+
+// verifyFile compares the checksum of a local file to the expected checksum and verifies its
+// signature when a signature is configured
+func verifyFile(ctx context.Context, verifier *Verifier, file, expected, binaryURL string, target *version.Version) error {
+	if expected == "" && !verifier.signatureEnabled() {
+		log.Debugf("no checksums or signature configured for %s, skipping verification", file)
+		return nil
+	}
+
+	actual, err := FileSha256(file)
+	if err != nil {
+		return fmt.Errorf("calculate checksum of %s: %w", file, err)
+	}
+	if expected != "" {
+		if actual != expected {
+			return fmt.Errorf("%w: %s has sha256 %s, expected %s", ErrChecksumMismatch, file, actual, expected)
+		}
+		log.Debugf("verified checksum of %s", file)
+	}
+
+	if verifier.signatureEnabled() {
+		digest, err := hex.DecodeString(actual)
+		if err != nil {
+			return fmt.Errorf("decode checksum of %s: %w", file, err)
+		}
+		content := func() ([]byte, error) {
+			return os.ReadFile(file)
+		}
+		if err := verifier.verifySignature(ctx, binaryURL, target, digest, content); err != nil {
+			return err
+		}
+		log.Debugf("verified signature of %s", file)
+	}
+	return nil
+}
+
+// recordedChecksum returns the checksum recorded by downloadToFile for a downloaded file or an
+// empty string when there is none
+func recordedChecksum(file string) string {
+	data, err := os.ReadFile(file + checksumFileSuffix)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Debugf("failed to read the recorded checksum of %s: %v", file, err)
+		}
+		return ""
+	}
+	return NormalizeChecksum(string(data))
+}
+
+// verifySignature downloads the signature for the binary and verifies it. Cosign signatures
+// are verified against the sha256 digest, minisign signatures require the binary content which
+// is only read when needed. A nil content function means the content is not available.
+func (v *Verifier) verifySignature(ctx context.Context, binaryURL string, target *version.Version, digest []byte, content func() ([]byte, error)) error {
+	if len(v.PublicKey) == 0 {
+		return fmt.Errorf("%w: no public key configured", ErrSignatureInvalid)
+	}
+
+	var sigURL string
+	switch {
+	case v.SignatureURL != nil:
+		sigURL = v.SignatureURL(binaryURL, target)
+	case v.SignatureType == SignatureMinisign:
+		sigURL = binaryURL + ".minisig"
+	default:
+		sigURL = binaryURL + ".sig"
+	}
+	sig, err := fetchVerificationFile(ctx, sigURL)
+	if err != nil {
+		return fmt.Errorf("%w: download signature: %w", ErrSignatureInvalid, err)
+	}
+
+	switch v.SignatureType {
+	case SignatureCosign:
+		err = verifyCosign(v.PublicKey, sig, digest)
+	case SignatureMinisign:
+		if content == nil {
+			return fmt.Errorf("%w: minisign signatures can only be verified for binaries downloaded by k0sctl, set uploadBinary: true on the host", ErrSignatureInvalid)
+		}
+		data, readErr := content()
+		if readErr != nil {
+			return fmt.Errorf("read the k0s binary: %w", readErr)
+		}
+		err = verifyMinisign(v.PublicKey, sig, data)
+	default:
+		err = fmt.Errorf("unknown signature type %q", v.SignatureType)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrSignatureInvalid, sigURL, err)
+	}
+	return nil
+}
+
+// verifyCosign verifies a base64 encoded signature created with "cosign sign-blob" using an ECDSA key
+func verifyCosign(publicKey, signature, digest []byte) error {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return errors.New("public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse public key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported public key type %T, only ECDSA keys are supported", key)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+	if !ecdsa.VerifyASN1(ecKey, digest, sig) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// verifyMinisign verifies a minisign signature file, including the trusted comment
+func verifyMinisign(publicKey, signature, content []byte) error {
+	keyData, err := minisignDecode(publicKey, 42)
+	if err != nil {
+		return fmt.Errorf("decode public key: %w", err)
+	}
+	if string(keyData[:2]) != "Ed" {
+		return errors.New("unsupported public key algorithm")
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(signature), "\r\n", "\n"), "\n")
+	if len(lines) < 4 {
+		return errors.New("invalid signature file")
+	}
+	sigData, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sigData) != 74 {
+		return errors.New("invalid signature")
+	}
+	if !bytes.Equal(sigData[2:10], keyData[2:10]) {
+		return errors.New("signature was created with a different key")
+	}
+
+	pub := ed25519.PublicKey(keyData[10:42])
+	message := content
+	switch string(sigData[:2]) {
+	case "Ed":
+	case "ED":
+		sum := blake2b.Sum512(content)
+		message = sum[:]
+	default:
+		return errors.New("unsupported signature algorithm")
+	}
+	if !ed25519.Verify(pub, message, sigData[10:74]) {
+		return errors.New("invalid signature")
+	}
+
+	trusted, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return errors.New("invalid trusted comment")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return errors.New("invalid global signature")
+	}
+	if !ed25519.Verify(pub, append(sigData[10:74:74], []byte(trusted)...), globalSig) {
+		return errors.New("invalid trusted comment signature")
+	}
+	return nil
+}
+
+// minisignDecode decodes the base64 line of a minisign key file, skipping the comment line
+func minisignDecode(data []byte, size int) ([]byte, error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(decoded) != size {
+			return nil, fmt.Errorf("unexpected length %d", len(decoded))
+		}
+		return decoded, nil
+	}
+	return nil, errors.New("no key found")
+}
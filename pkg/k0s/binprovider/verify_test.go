@@ -0,0 +1,176 @@
+package binprovider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k0sproject/version"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestParseChecksums(t *testing.T) {
+	sums := parseChecksums([]byte("ABCDEF  k0s-v1.30.0+k0s.0-amd64\n012345 *k0s-v1.30.0+k0s.0-amd64.exe\ninvalid line here\n"))
+	require.Equal(t, map[string]string{
+		"k0s-v1.30.0+k0s.0-amd64":     "abcdef",
+		"k0s-v1.30.0+k0s.0-amd64.exe": "012345",
+	}, sums)
+}
+
+func TestVerifyLocalFile(t *testing.T) {
+	content := []byte("k0s binary")
+	sum := sha256.Sum256(content)
+	hexSum := hex.EncodeToString(sum[:])
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/sha256sums.txt" {
+			fmt.Fprintf(w, "%s  k0s-v1.30.0+k0s.0-amd64\n", hexSum)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "k0s")
+	require.NoError(t, os.WriteFile(file, content, 0o600))
+	v := version.MustParse("v1.30.0+k0s.0")
+	ctx := context.Background()
+
+	t.Run("published", func(t *testing.T) {
+		require.NoError(t, verifyLocalFile(ctx, nil, file, "amd64", srv.URL+"/v1/k0s-v1.30.0%2Bk0s.0-amd64", v))
+	})
+
+	t.Run("pinned", func(t *testing.T) {
+		verifier := &Verifier{Checksums: map[string]string{"amd64": "sha256:" + hexSum}}
+		require.NoError(t, verifyLocalFile(ctx, verifier, file, "amd64", "", v))
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		verifier := &Verifier{Checksums: map[string]string{"amd64": hex.EncodeToString(make([]byte, 32))}}
+		require.ErrorIs(t, verifyLocalFile(ctx, verifier, file, "amd64", "", v), ErrChecksumMismatch)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		require.ErrorIs(t, verifyLocalFile(ctx, nil, file, "arm64", srv.URL+"/v2/k0s-v1.30.0%2Bk0s.0-arm64", v), ErrChecksumUnavailable)
+	})
+
+	t.Run("not in published checksums", func(t *testing.T) {
+		require.ErrorIs(t, verifyLocalFile(ctx, nil, file, "arm64", srv.URL+"/v1/k0s-v1.30.0%2Bk0s.0-arm64", v), ErrChecksumUnavailable)
+	})
+
+	t.Run("pinned for another arch", func(t *testing.T) {
+		verifier := &Verifier{Checksums: map[string]string{"amd64": hexSum}}
+		require.ErrorIs(t, verifyLocalFile(ctx, verifier, file, "arm64", "", v), ErrChecksumUnavailable)
+	})
+
+	t.Run("nothing configured", func(t *testing.T) {
+		require.NoError(t, verifyLocalFile(ctx, nil, file, "amd64", "", v))
+	})
+}
+
+func TestVerifyCachedFile(t *testing.T) {
+	content := []byte("k0s binary")
+	sum := sha256.Sum256(content)
+	hexSum := hex.EncodeToString(sum[:])
+
+	// the checksum file is never available, as when running offline
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	binaryURL := srv.URL + "/v1/k0s-v1.30.0%2Bk0s.0-amd64"
+
+	file := filepath.Join(t.TempDir(), "k0s")
+	require.NoError(t, os.WriteFile(file, content, 0o600))
+	v := version.MustParse("v1.30.0+k0s.0")
+	ctx := context.Background()
+
+	t.Run("published checksum unavailable", func(t *testing.T) {
+		require.NoError(t, verifyCachedFile(ctx, nil, file, "amd64", binaryURL, v))
+	})
+
+	require.NoError(t, os.WriteFile(file+checksumFileSuffix, []byte(hexSum+"\n"), 0o600))
+
+	t.Run("recorded", func(t *testing.T) {
+		require.NoError(t, verifyCachedFile(ctx, nil, file, "amd64", binaryURL, v))
+	})
+
+	t.Run("pinned takes precedence", func(t *testing.T) {
+		verifier := &Verifier{Checksums: map[string]string{"amd64": hex.EncodeToString(make([]byte, 32))}}
+		require.ErrorIs(t, verifyCachedFile(ctx, verifier, file, "amd64", binaryURL, v), ErrChecksumMismatch)
+	})
+
+	t.Run("recorded mismatch", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(file+checksumFileSuffix, []byte(hex.EncodeToString(make([]byte, 32))), 0o600))
+		require.ErrorIs(t, verifyCachedFile(ctx, nil, file, "amd64", binaryURL, v), ErrChecksumMismatch)
+	})
+}
+
+func TestDownloadToFileRecordsChecksum(t *testing.T) {
+	content := []byte("k0s binary")
+	sum := sha256.Sum256(content)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "k0s")
+	require.NoError(t, os.WriteFile(dest+checksumFileSuffix, []byte("stale"), 0o600))
+
+	require.Error(t, downloadToFile(context.Background(), srv.URL, dest, func(string) error { return ErrChecksumMismatch }))
+	require.NoFileExists(t, dest)
+
+	require.NoError(t, downloadToFile(context.Background(), srv.URL, dest, func(string) error { return nil }))
+	require.Equal(t, hex.EncodeToString(sum[:]), recordedChecksum(dest))
+}
+
+func TestVerifyCosign(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	digest := sha256.Sum256([]byte("k0s binary"))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	encoded := []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+
+	require.NoError(t, verifyCosign(pub, encoded, digest[:]))
+
+	other := sha256.Sum256([]byte("tampered"))
+	require.Error(t, verifyCosign(pub, encoded, other[:]))
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	pubFile := "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...)) + "\n"
+
+	content := []byte("k0s binary")
+	prehash := blake2b.Sum512(content)
+	sig := ed25519.Sign(priv, prehash[:])
+	trusted := "timestamp:1700000000"
+	globalSig := ed25519.Sign(priv, append(append([]byte{}, sig...), []byte(trusted)...))
+	sigFile := "untrusted comment: signature\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("ED"), keyID...), sig...)) + "\n" +
+		"trusted comment: " + trusted + "\n" +
+		base64.StdEncoding.EncodeToString(globalSig) + "\n"
+
+	require.NoError(t, verifyMinisign([]byte(pubFile), []byte(sigFile), content))
+	require.Error(t, verifyMinisign([]byte(pubFile), []byte(sigFile), []byte("tampered")))
+}
@@ -4,8 +4,8 @@ import "context"
 
 // BinaryProvider knows how to acquire a k0s binary for a specific host.
 // Implementations are typically created per-host and may close over host-specific state.
-// The five built-in implementations in pkg/k0s/binprovider are binprovider.NewGitHub, binprovider.NewCustomURL,
-// binprovider.NewLocalFile, binprovider.NewLocalUpload, and binprovider.NewExisting, selected automatically
+// The six built-in implementations in pkg/k0s/binprovider are binprovider.NewGitHub, binprovider.NewCustomURL,
+// binprovider.NewLocalFile, binprovider.NewLocalUpload, binprovider.NewOCI, and binprovider.NewExisting, selected automatically
 // based on the host configuration, but custom implementations can be set via host.SetK0sBinaryProvider.
 type BinaryProvider interface {
 	// NeedsUpgrade reports whether the host's k0s binary needs to be updated.
@@ -41,3 +41,23 @@ type BinaryCacher interface {
 	// EnsureCached downloads the binary to the local cache if not already present.
 	EnsureCached(ctx context.Context) error
 }
+
+// DownloadURLProvider is an optional interface for BinaryProvider implementations where the host
+// downloads the binary from an URL. It is used for the k0s autopilot upgrade plans, where the
+// nodes download the binary themselves.
+type DownloadURLProvider interface {
+	// DownloadURL returns the URL of the binary for the provider's target version.
+	DownloadURL() (string, error)
+	// DownloadSha256 returns the expected sha256 checksum of the binary for the provider's target
+	// version or an empty string when the binary is not verified.
+	DownloadSha256(ctx context.Context) (string, error)
+}
+
+// PeerStager is an optional interface for upload providers that can stage the binary by
+// downloading it from another host that already has it instead of uploading it from the
+// local machine. StageBinaries uses it when peer-to-peer binary distribution is enabled.
+type PeerStager interface {
+	// StageFromPeer downloads the binary from url on the host, verifies it against the
+	// checksum of the locally cached binary and returns the path to a temporary file on the host.
+	StageFromPeer(ctx context.Context, url string) (string, error)
+}
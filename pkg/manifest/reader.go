@@ -20,7 +20,8 @@ type ResourceDefinition struct {
 	APIVersion string `yaml:"apiVersion"`
 	Kind       string `yaml:"kind"`
 	Metadata   struct {
-		Name string `yaml:"name"`
+		Name        string            `yaml:"name"`
+		Annotations map[string]string `yaml:"annotations"`
 	} `yaml:"metadata"`
 	Origin string `yaml:"-"`
 	Raw    []byte `yaml:"-"`
@@ -0,0 +1,192 @@
+package oci
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dockerHubAuthKey is the key docker uses for the Docker Hub credentials
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// Credentials for a registry. An empty Credentials means anonymous access.
+type Credentials struct {
+	Username string
+	Password string
+	// IdentityToken is an OAuth2 refresh token used instead of the password
+	IdentityToken string
+}
+
+// IsEmpty returns true when there are no credentials
+func (c Credentials) IsEmpty() bool {
+	return c.Username == "" && c.Password == "" && c.IdentityToken == ""
+}
+
+// CredentialsFunc returns the credentials for a registry host
+type CredentialsFunc func(registry string) (Credentials, error)
+
+// dockerConfig is the subset of the docker config.json and the containers auth.json used for registry auth
+type dockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredsStore  string                `json:"credsStore"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+}
+
+type dockerAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// DockerConfigFiles returns the registry auth files in the order they are searched:
+// $REGISTRY_AUTH_FILE, $XDG_RUNTIME_DIR/containers/auth.json and $DOCKER_CONFIG/config.json
+// or ~/.docker/config.json
+func DockerConfigFiles() []string {
+	var files []string
+	if f := os.Getenv("REGISTRY_AUTH_FILE"); f != "" {
+		files = append(files, f)
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		files = append(files, filepath.Join(dir, "containers", "auth.json"))
+	}
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		files = append(files, filepath.Join(dir, "config.json"))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".docker", "config.json"))
+	}
+	return files
+}
+
+// DockerCredentials looks up the credentials for the registry from the docker config files
+// returned by DockerConfigFiles, including the credential helpers configured in them.
+// Empty credentials are returned when none of the files has credentials for the registry.
+func DockerCredentials(registry string) (Credentials, error) {
+	return credentialsFromFiles(DockerConfigFiles(), registry)
+}
+
+func credentialsFromFiles(files []string, registry string) (Credentials, error) {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Debugf("failed to read registry auth file %s: %v", file, err)
+			}
+			continue
+		}
+		cfg := &dockerConfig{}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return Credentials{}, fmt.Errorf("parse registry auth file %s: %w", file, err)
+		}
+		creds, found, err := cfg.credentials(registry)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("registry auth file %s: %w", file, err)
+		}
+		if found {
+			log.Debugf("using credentials from %s for registry %s", file, registry)
+			return creds, nil
+		}
+	}
+	return Credentials{}, nil
+}
+
+// credentials returns the credentials for the registry. The registry specific credential helper
+// takes precedence over the auths entries, which take precedence over the default credential store.
+func (c *dockerConfig) credentials(registry string) (Credentials, bool, error) {
+	keys := []string{registry}
+	storeKey := registry
+	if registry == dockerHubRegistry {
+		keys = append(keys, dockerHubAuthKey, "index.docker.io", dockerHubAPIRegistry)
+		storeKey = dockerHubAuthKey
+	}
+
+	for _, key := range keys {
+		if helper, ok := c.CredHelpers[key]; ok {
+			creds, err := helperCredentials(helper, key)
+			return creds, err == nil, err
+		}
+	}
+
+	for k, auth := range c.Auths {
+		if !slices.Contains(keys, normalizeAuthKey(k)) && !slices.Contains(keys, k) {
+			continue
+		}
+		creds, err := auth.credentials()
+		if err != nil {
+			return Credentials{}, false, fmt.Errorf("auths entry for %s: %w", k, err)
+		}
+		if !creds.IsEmpty() {
+			return creds, true, nil
+		}
+	}
+
+	if c.CredsStore != "" {
+		creds, err := helperCredentials(c.CredsStore, storeKey)
+		if err != nil {
+			// the default store does not have credentials for every registry
+			log.Debugf("credential store %s has no credentials for %s: %v", c.CredsStore, registry, err)
+			return Credentials{}, false, nil
+		}
+		return creds, true, nil
+	}
+
+	return Credentials{}, false, nil
+}
+
+// normalizeAuthKey strips the scheme and path from an auths key, the keys can be URLs like https://index.docker.io/v1/
+func normalizeAuthKey(key string) string {
+	key = strings.TrimPrefix(key, "https://")
+	key = strings.TrimPrefix(key, "http://")
+	host, _, _ := strings.Cut(key, "/")
+	return host
+}
+
+func (a dockerAuth) credentials() (Credentials, error) {
+	creds := Credentials{Username: a.Username, Password: a.Password, IdentityToken: a.IdentityToken}
+	if a.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("decode auth: %w", err)
+		}
+		user, pass, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return Credentials{}, errors.New("decode auth: invalid format")
+		}
+		creds.Username = user
+		creds.Password = pass
+	}
+	return creds, nil
+}
+
+// helperCredentials runs the docker-credential-<helper> get command
+func helperCredentials(helper, serverURL string) (Credentials, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get") //nolint:gosec // the helper name comes from the user's docker config
+	cmd.Stdin = strings.NewReader(serverURL)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return Credentials{}, fmt.Errorf("credential helper %s: %w: %s", helper, err, strings.TrimSpace(stderr.String()))
+	}
+	var resp struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return Credentials{}, fmt.Errorf("credential helper %s: decode output: %w", helper, err)
+	}
+	// a username of <token> means the secret is an identity token
+	if resp.Username == "<token>" {
+		return Credentials{IdentityToken: resp.Secret}, nil
+	}
+	return Credentials{Username: resp.Username, Password: resp.Secret}, nil
+}
@@ -0,0 +1,78 @@
+package oci
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeAuthFile(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func TestCredentialsFromFiles(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("robot$k0s:secret"))
+	docker := writeAuthFile(t, `{"auths": {
+		"https://harbor.example.com": {"auth": "`+auth+`"},
+		"https://index.docker.io/v1/": {"username": "hubuser", "password": "hubpass"},
+		"token.example.com": {"identitytoken": "refresh"}
+	}}`)
+	containers := writeAuthFile(t, `{"auths": {"quay.example.com": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("quay:pass"))+`"}}}`)
+	files := []string{filepath.Join(t.TempDir(), "missing.json"), containers, docker}
+
+	t.Run("auth with scheme in key", func(t *testing.T) {
+		creds, err := credentialsFromFiles(files, "harbor.example.com")
+		require.NoError(t, err)
+		require.Equal(t, Credentials{Username: "robot$k0s", Password: "secret"}, creds)
+	})
+
+	t.Run("docker hub", func(t *testing.T) {
+		creds, err := credentialsFromFiles(files, "docker.io")
+		require.NoError(t, err)
+		require.Equal(t, Credentials{Username: "hubuser", Password: "hubpass"}, creds)
+	})
+
+	t.Run("identity token", func(t *testing.T) {
+		creds, err := credentialsFromFiles(files, "token.example.com")
+		require.NoError(t, err)
+		require.Equal(t, Credentials{IdentityToken: "refresh"}, creds)
+	})
+
+	t.Run("first file wins", func(t *testing.T) {
+		creds, err := credentialsFromFiles(files, "quay.example.com")
+		require.NoError(t, err)
+		require.Equal(t, "quay", creds.Username)
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		creds, err := credentialsFromFiles(files, "other.example.com")
+		require.NoError(t, err)
+		require.True(t, creds.IsEmpty())
+	})
+
+	t.Run("invalid auth", func(t *testing.T) {
+		invalid := writeAuthFile(t, `{"auths": {"harbor.example.com": {"auth": "bm9jb2xvbg=="}}}`)
+		_, err := credentialsFromFiles([]string{invalid}, "harbor.example.com")
+		require.ErrorContains(t, err, "invalid format")
+	})
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:k0s/k0s:pull,push"`)
+	require.Equal(t, "Bearer", scheme)
+	require.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:k0s/k0s:pull,push",
+	}, params)
+
+	scheme, params = parseChallenge(`Basic realm=registry`)
+	require.Equal(t, "Basic", scheme)
+	require.Equal(t, "registry", params["realm"])
+}
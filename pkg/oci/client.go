@@ -0,0 +1,432 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Media types of the manifests the client can resolve
+const (
+	MediaTypeImageIndex     = "application/vnd.oci.image.index.v1+json"
+	MediaTypeImageManifest  = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// AnnotationTitle is the layer annotation that holds the file name of an artifact
+const AnnotationTitle = "org.opencontainers.image.title"
+
+const (
+	maxManifestSize      = 4 << 20
+	maxTokenResponseSize = 1 << 20
+	tokenClientID        = "k0sctl"
+	manifestAccept       = MediaTypeImageIndex + ", " + MediaTypeImageManifest + ", " + MediaTypeDockerList + ", " + MediaTypeDockerManifest
+)
+
+// ErrDigestMismatch is returned when the content of a blob does not match its digest
+var ErrDigestMismatch = errors.New("oci blob digest mismatch")
+
+// Platform selects a manifest from an image index
+type Platform struct {
+	OS           string
+	Architecture string
+	// Variant is only compared when set, for example v7 for arm
+	Variant string
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Blob is a resolved blob in a registry
+type Blob struct {
+	// URL of the blob, the registry may redirect it to a storage backend
+	URL string
+	// Digest of the blob content, for example sha256:abcd..
+	Digest string
+	// Size of the blob in bytes
+	Size int64
+	// Authorization is the value for the Authorization header when downloading the blob, empty for
+	// anonymous access. It is usually a short lived bearer token scoped to the repository.
+	Authorization string
+}
+
+// Sha256 returns the hex encoded sha256 checksum from the digest or an empty string when the
+// digest uses a different algorithm
+func (b *Blob) Sha256() string {
+	if sum, ok := strings.CutPrefix(b.Digest, "sha256:"); ok {
+		return strings.ToLower(sum)
+	}
+	return ""
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+		Variant      string `json:"variant,omitempty"`
+	} `json:"platform,omitempty"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// Client is a minimal client for the OCI distribution API that resolves and downloads single
+// file artifacts, such as the ones pushed with `oras push`.
+type Client struct {
+	HTTPClient *http.Client
+	// Credentials returns the credentials for a registry, DockerCredentials when nil
+	Credentials CredentialsFunc
+}
+
+// session holds the authorization of a single resolve operation
+type session struct {
+	c             *Client
+	ref           *Reference
+	authorization string
+}
+
+// Resolve resolves the reference to the blob of the artifact file. When the reference points
+// to an image index, the manifest for the platform is selected. The artifact manifest must
+// have a single layer or a layer with a title annotation starting with "k0s".
+func (c *Client) Resolve(ctx context.Context, ref *Reference, platform Platform) (*Blob, error) {
+	s := &session{c: c, ref: ref}
+	m, err := s.manifest(ctx, ref.manifestReference())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Manifests) > 0 || m.MediaType == MediaTypeImageIndex || m.MediaType == MediaTypeDockerList {
+		d, err := selectPlatform(m.Manifests, platform)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		m, err = s.manifest(ctx, d.Digest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	layer, err := selectLayer(m.Layers)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+
+	return &Blob{
+		URL:           ref.baseURL() + ref.Repository + "/blobs/" + layer.Digest,
+		Digest:        layer.Digest,
+		Size:          layer.Size,
+		Authorization: s.authorization,
+	}, nil
+}
+
+// Fetch downloads the blob to w and verifies its digest
+func (c *Client) Fetch(ctx context.Context, blob *Blob, w io.Writer) (retErr error) {
+	algo, expected, ok := strings.Cut(blob.Digest, ":")
+	if !ok || algo != "sha256" {
+		return fmt.Errorf("unsupported digest %q", blob.Digest)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blob.URL, nil)
+	if err != nil {
+		return err
+	}
+	if blob.Authorization != "" {
+		req.Header.Set("Authorization", blob.Authorization)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected http status %s from %s", resp.Status, blob.URL)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), resp.Body)
+	if err != nil {
+		return err
+	}
+	if blob.Size > 0 && n != blob.Size {
+		return fmt.Errorf("%w: received %d bytes, expected %d", ErrDigestMismatch, n, blob.Size)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != strings.ToLower(expected) {
+		return fmt.Errorf("%w: received sha256:%s, expected %s", ErrDigestMismatch, actual, blob.Digest)
+	}
+	return nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) credentials(registry string) (Credentials, error) {
+	if c.Credentials != nil {
+		return c.Credentials(registry)
+	}
+	return DockerCredentials(registry)
+}
+
+func selectPlatform(manifests []descriptor, platform Platform) (*descriptor, error) {
+	var available []string
+	for i, d := range manifests {
+		if d.Platform == nil {
+			continue
+		}
+		if d.Platform.OS == platform.OS && d.Platform.Architecture == platform.Architecture && (platform.Variant == "" || d.Platform.Variant == platform.Variant) {
+			return &manifests[i], nil
+		}
+		available = append(available, Platform{OS: d.Platform.OS, Architecture: d.Platform.Architecture, Variant: d.Platform.Variant}.String())
+	}
+	return nil, fmt.Errorf("no manifest for platform %s in the image index (available: %s)", platform, strings.Join(available, ", "))
+}
+
+func selectLayer(layers []descriptor) (*descriptor, error) {
+	var layer *descriptor
+	switch len(layers) {
+	case 0:
+		return nil, errors.New("the manifest has no layers")
+	case 1:
+		layer = &layers[0]
+	default:
+		for i, l := range layers {
+			if strings.HasPrefix(path.Base(l.Annotations[AnnotationTitle]), "k0s") {
+				layer = &layers[i]
+				break
+			}
+		}
+		if layer == nil {
+			return nil, fmt.Errorf("the manifest has %d layers and none of them has a %s annotation starting with k0s", len(layers), AnnotationTitle)
+		}
+	}
+	if strings.Contains(layer.MediaType, "image.rootfs") || strings.Contains(layer.MediaType, ".tar") {
+		return nil, fmt.Errorf("the layer has the media type %s of a container image, the k0s binary must be pushed as a single file artifact", layer.MediaType)
+	}
+	return layer, nil
+}
+
+// manifest fetches and decodes a manifest or an image index
+func (s *session) manifest(ctx context.Context, reference string) (*manifest, error) {
+	u := s.ref.baseURL() + s.ref.Repository + "/manifests/" + reference
+	resp, err := s.get(ctx, u, manifestAccept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck // the body has been read
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get manifest %s: unexpected http status %s", s.ref, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("get manifest %s: %w", s.ref, err)
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("decode manifest %s: %w", s.ref, err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+	return m, nil
+}
+
+// get performs a GET request. When the registry responds with 401, the authorization is
+// obtained using the challenge and the request is retried.
+func (s *session) get(ctx context.Context, u, accept string) (*http.Response, error) {
+	resp, err := s.do(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized || s.authorization != "" {
+		return resp, nil
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	_ = resp.Body.Close()
+
+	if err := s.authorize(ctx, challenge); err != nil {
+		return nil, fmt.Errorf("authenticate to %s: %w", s.ref.Registry, err)
+	}
+	return s.do(ctx, u, accept)
+}
+
+func (s *session) do(ctx context.Context, u, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+	return s.c.httpClient().Do(req)
+}
+
+// authorize sets the authorization for the session based on the WWW-Authenticate challenge
+func (s *session) authorize(ctx context.Context, challenge string) error {
+	creds, err := s.c.credentials(s.ref.Registry)
+	if err != nil {
+		return fmt.Errorf("get credentials: %w", err)
+	}
+
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds.Username == "" {
+			return errors.New("the registry requires basic auth but no credentials were found in the docker config")
+		}
+		s.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password))
+		return nil
+	case "bearer":
+		token, err := s.token(ctx, params, creds)
+		if err != nil {
+			return err
+		}
+		s.authorization = "Bearer " + token
+		return nil
+	default:
+		return fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+}
+
+// token obtains a bearer token from the token service of the registry
+func (s *session) token(ctx context.Context, params map[string]string, creds Credentials) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.New("the bearer challenge has no realm")
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + s.ref.Repository + ":pull"
+	}
+
+	var req *http.Request
+	var err error
+	if creds.IdentityToken != "" {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {creds.IdentityToken},
+			"service":       {params["service"]},
+			"scope":         {scope},
+			"client_id":     {tokenClientID},
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, realm, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		u, err := url.Parse(realm)
+		if err != nil {
+			return "", fmt.Errorf("parse token realm: %w", err)
+		}
+		q := u.Query()
+		if service := params["service"]; service != "" {
+			q.Set("service", service)
+		}
+		q.Set("scope", scope)
+		u.RawQuery = q.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if creds.Username != "" {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+
+	resp, err := s.c.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("get token: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // the body has been read
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get token: unexpected http status %s from %s", resp.Status, realm)
+	}
+	var tr struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxTokenResponseSize)).Decode(&tr); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if tr.Token != "" {
+		return tr.Token, nil
+	}
+	if tr.AccessToken != "" {
+		return tr.AccessToken, nil
+	}
+	return "", errors.New("the token response does not contain a token")
+}
+
+// parseChallenge parses a WWW-Authenticate header value such as
+// Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:k0s:pull"
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		key, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		var value string
+		if strings.HasPrefix(after, `"`) {
+			after = after[1:]
+			var sb strings.Builder
+			i := 0
+			for ; i < len(after); i++ {
+				if after[i] == '\\' && i+1 < len(after) {
+					i++
+					sb.WriteByte(after[i])
+					continue
+				}
+				if after[i] == '"' {
+					break
+				}
+				sb.WriteByte(after[i])
+			}
+			value = sb.String()
+			if i < len(after) {
+				i++
+			}
+			rest = after[i:]
+		} else {
+			value, rest, _ = strings.Cut(after, ",")
+			value = strings.TrimSpace(value)
+		}
+		params[key] = value
+	}
+	return scheme, params
+}
@@ -0,0 +1,162 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRegistry struct {
+	*httptest.Server
+	blobs     map[string][]byte
+	manifests map[string]any
+	token     string
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newTestRegistry serves an image index for k0s:v1.30.0-k0s.0 with linux/amd64 and linux/arm64
+// artifacts behind bearer token auth that requires the user k0s with the password secret
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	r := &testRegistry{blobs: map[string][]byte{}, manifests: map[string]any{}, token: "t0ken"}
+
+	var index []descriptor
+	for _, arch := range []string{"amd64", "arm64"} {
+		content := []byte("k0s binary for " + arch)
+		r.blobs[digestOf(content)] = content
+		m := map[string]any{
+			"schemaVersion": 2,
+			"mediaType":     MediaTypeImageManifest,
+			"layers": []descriptor{
+				{MediaType: "application/vnd.oci.image.config.v1+json", Digest: digestOf([]byte("readme")), Annotations: map[string]string{AnnotationTitle: "README.md"}},
+				{MediaType: "application/octet-stream", Digest: digestOf(content), Size: int64(len(content)), Annotations: map[string]string{AnnotationTitle: "k0s-v1.30.0+k0s.0-" + arch}},
+			},
+		}
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+		r.manifests[digestOf(data)] = m
+		d := descriptor{MediaType: MediaTypeImageManifest, Digest: digestOf(data)}
+		d.Platform = &struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+			Variant      string `json:"variant,omitempty"`
+		}{Architecture: arch, OS: "linux"}
+		index = append(index, d)
+	}
+	r.manifests["v1.30.0-k0s.0"] = map[string]any{"schemaVersion": 2, "mediaType": MediaTypeImageIndex, "manifests": index}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != "k0s" || pass != "secret" || req.URL.Query().Get("scope") != "repository:k0s/k0s:pull" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": r.token})
+	})
+	mux.HandleFunc("/v2/k0s/k0s/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer "+r.token {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:k0s/k0s:pull"`, r.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		kind, ref, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/v2/k0s/k0s/"), "/")
+		switch kind {
+		case "manifests":
+			m, ok := r.manifests[ref]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(m)
+		case "blobs":
+			b, ok := r.blobs[ref]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(b)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	r.Server = httptest.NewServer(mux)
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *testRegistry) reference(t *testing.T, tag string) *Reference {
+	t.Helper()
+	ref, err := ParseReference(PlainHTTPScheme + strings.TrimPrefix(r.URL, "http://") + "/k0s/k0s:" + tag)
+	require.NoError(t, err)
+	return ref
+}
+
+func TestClientResolveAndFetch(t *testing.T) {
+	r := newTestRegistry(t)
+	c := &Client{Credentials: func(registry string) (Credentials, error) {
+		return Credentials{Username: "k0s", Password: "secret"}, nil
+	}}
+
+	blob, err := c.Resolve(context.Background(), r.reference(t, "v1.30.0-k0s.0"), Platform{OS: "linux", Architecture: "arm64"})
+	require.NoError(t, err)
+	require.Equal(t, digestOf([]byte("k0s binary for arm64")), blob.Digest)
+	require.Equal(t, "Bearer t0ken", blob.Authorization)
+	require.Equal(t, strings.TrimPrefix(blob.Digest, "sha256:"), blob.Sha256())
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Fetch(context.Background(), blob, &buf))
+	require.Equal(t, "k0s binary for arm64", buf.String())
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		r.blobs[blob.Digest] = []byte("tampered binary for arm")
+		buf.Reset()
+		require.ErrorIs(t, c.Fetch(context.Background(), blob, &buf), ErrDigestMismatch)
+	})
+
+	t.Run("unknown platform", func(t *testing.T) {
+		_, err := c.Resolve(context.Background(), r.reference(t, "v1.30.0-k0s.0"), Platform{OS: "linux", Architecture: "riscv64"})
+		require.ErrorContains(t, err, "no manifest for platform linux/riscv64")
+		require.ErrorContains(t, err, "linux/amd64, linux/arm64")
+	})
+
+	t.Run("unknown tag", func(t *testing.T) {
+		_, err := c.Resolve(context.Background(), r.reference(t, "v1.29.0-k0s.0"), Platform{OS: "linux", Architecture: "amd64"})
+		require.ErrorContains(t, err, "404")
+	})
+}
+
+func TestClientResolveWithoutCredentials(t *testing.T) {
+	r := newTestRegistry(t)
+	c := &Client{Credentials: func(string) (Credentials, error) { return Credentials{}, nil }}
+	_, err := c.Resolve(context.Background(), r.reference(t, "v1.30.0-k0s.0"), Platform{OS: "linux", Architecture: "amd64"})
+	require.ErrorContains(t, err, "authenticate to")
+}
+
+func TestSelectLayer(t *testing.T) {
+	_, err := selectLayer(nil)
+	require.ErrorContains(t, err, "no layers")
+
+	_, err = selectLayer([]descriptor{{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: "sha256:aa"}})
+	require.ErrorContains(t, err, "single file artifact")
+
+	_, err = selectLayer([]descriptor{{Digest: "sha256:aa"}, {Digest: "sha256:bb"}})
+	require.ErrorContains(t, err, "none of them")
+
+	l, err := selectLayer([]descriptor{{Digest: "sha256:aa"}, {Digest: "sha256:bb", Annotations: map[string]string{AnnotationTitle: "k0s.exe"}}})
+	require.NoError(t, err)
+	require.Equal(t, "sha256:bb", l.Digest)
+}
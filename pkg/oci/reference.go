@@ -0,0 +1,138 @@
+package oci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Scheme is the URL scheme of OCI references
+	Scheme = "oci://"
+	// PlainHTTPScheme is the URL scheme of OCI references to registries that are served over plain http
+	PlainHTTPScheme = "oci+http://"
+
+	dockerHubRegistry    = "docker.io"
+	dockerHubAPIRegistry = "registry-1.docker.io"
+)
+
+var (
+	tagRe    = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRe = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+	repoRe   = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+)
+
+// Reference is a parsed reference to an artifact in an OCI registry, such as
+// oci://registry.example.com/k0s/k0s:v1.30.0-k0s.0
+type Reference struct {
+	// Registry is the registry host with an optional port
+	Registry string
+	// Repository is the repository path within the registry
+	Repository string
+	// Tag is empty when the reference is by digest
+	Tag string
+	// Digest is the optional content digest, it takes precedence over the tag
+	Digest string
+	// PlainHTTP is true when the registry is accessed over http instead of https
+	PlainHTTP bool
+}
+
+// IsReference returns true when the string has an OCI reference scheme
+func IsReference(s string) bool {
+	return strings.HasPrefix(s, Scheme) || strings.HasPrefix(s, PlainHTTPScheme)
+}
+
+// ParseReference parses an oci:// or oci+http:// reference. References without a registry
+// host refer to Docker Hub.
+func ParseReference(s string) (*Reference, error) {
+	ref := &Reference{}
+	switch {
+	case strings.HasPrefix(s, Scheme):
+		s = strings.TrimPrefix(s, Scheme)
+	case strings.HasPrefix(s, PlainHTTPScheme):
+		s = strings.TrimPrefix(s, PlainHTTPScheme)
+		ref.PlainHTTP = true
+	default:
+		return nil, fmt.Errorf("invalid oci reference %q: must start with %s or %s", s, Scheme, PlainHTTPScheme)
+	}
+
+	name := s
+	if before, after, ok := strings.Cut(name, "@"); ok {
+		name = before
+		ref.Digest = after
+		if !digestRe.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("invalid oci reference %q: invalid digest %q", s, ref.Digest)
+		}
+	}
+	// the tag separator is the last colon that comes after the last slash, a colon before it is a port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if !tagRe.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("invalid oci reference %q: invalid tag %q", s, ref.Tag)
+		}
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+
+	registry, repo, ok := strings.Cut(name, "/")
+	if !ok || !(strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		// no registry host, for example oci://k0sproject/k0s:v1.30.0-k0s.0
+		registry = dockerHubRegistry
+		repo = name
+	}
+	if registry == dockerHubRegistry && !strings.Contains(repo, "/") {
+		repo = "library/" + repo
+	}
+	if !repoRe.MatchString(repo) {
+		return nil, fmt.Errorf("invalid oci reference %q: invalid repository %q", s, repo)
+	}
+	ref.Registry = registry
+	ref.Repository = repo
+
+	return ref, nil
+}
+
+// String returns the reference in the oci:// form
+func (r *Reference) String() string {
+	var sb strings.Builder
+	if r.PlainHTTP {
+		sb.WriteString(PlainHTTPScheme)
+	} else {
+		sb.WriteString(Scheme)
+	}
+	sb.WriteString(r.Registry)
+	sb.WriteByte('/')
+	sb.WriteString(r.Repository)
+	if r.Tag != "" {
+		sb.WriteByte(':')
+		sb.WriteString(r.Tag)
+	}
+	if r.Digest != "" {
+		sb.WriteByte('@')
+		sb.WriteString(r.Digest)
+	}
+	return sb.String()
+}
+
+// manifestReference returns the digest or the tag used in the manifest URL
+func (r *Reference) manifestReference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// baseURL returns the URL of the registry API endpoint
+func (r *Reference) baseURL() string {
+	scheme := "https"
+	if r.PlainHTTP {
+		scheme = "http"
+	}
+	host := r.Registry
+	if host == dockerHubRegistry {
+		host = dockerHubAPIRegistry
+	}
+	return scheme + "://" + host + "/v2/"
+}
@@ -0,0 +1,68 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Reference
+		baseURL string
+	}{
+		{
+			in:      "oci://harbor.example.com/k0s/k0s:v1.30.0-k0s.0",
+			want:    Reference{Registry: "harbor.example.com", Repository: "k0s/k0s", Tag: "v1.30.0-k0s.0"},
+			baseURL: "https://harbor.example.com/v2/",
+		},
+		{
+			in:      "oci+http://localhost:5000/k0s",
+			want:    Reference{Registry: "localhost:5000", Repository: "k0s", Tag: "latest", PlainHTTP: true},
+			baseURL: "http://localhost:5000/v2/",
+		},
+		{
+			in:      "oci://registry.example.com:8443/mirror/k0s@sha256:abcdef0123",
+			want:    Reference{Registry: "registry.example.com:8443", Repository: "mirror/k0s", Digest: "sha256:abcdef0123"},
+			baseURL: "https://registry.example.com:8443/v2/",
+		},
+		{
+			in:      "oci://k0sproject/k0s:v1.30.0-k0s.0",
+			want:    Reference{Registry: "docker.io", Repository: "k0sproject/k0s", Tag: "v1.30.0-k0s.0"},
+			baseURL: "https://registry-1.docker.io/v2/",
+		},
+		{
+			in:      "oci://k0s",
+			want:    Reference{Registry: "docker.io", Repository: "library/k0s", Tag: "latest"},
+			baseURL: "https://registry-1.docker.io/v2/",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			ref, err := ParseReference(tc.in)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, *ref)
+			require.Equal(t, tc.baseURL, ref.baseURL())
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, in := range []string{
+			"https://harbor.example.com/k0s:v1.30.0",
+			"oci://harbor.example.com/k0s:v1.30.0+k0s.0",
+			"oci://harbor.example.com/K0S:v1.30.0",
+			"oci://harbor.example.com/k0s@invalid",
+		} {
+			_, err := ParseReference(in)
+			require.Error(t, err, in)
+		}
+	})
+}
+
+func TestReferenceString(t *testing.T) {
+	ref, err := ParseReference("oci+http://localhost:5000/k0s/k0s:v1.30.0-k0s.0")
+	require.NoError(t, err)
+	require.Equal(t, "oci+http://localhost:5000/k0s/k0s:v1.30.0-k0s.0", ref.String())
+}
@@ -0,0 +1,512 @@
+// Package overlay applies configuration overlays on top of the k0sctl Cluster document.
+//
+// An overlay is either a strategic merge patch, a YAML mapping that is merged into the document,
+// or an RFC 6902 JSON patch, a YAML or JSON list of patch operations. In both forms the entries of
+// spec.hosts can be selected by the host address or by the host role.
+package overlay
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// patchDirective is the key of the strategic merge directive, a mapping with `$patch: delete`
+// removes the matching entry and one with `$patch: replace` replaces it instead of merging
+const patchDirective = "$patch"
+
+// Overlay is a parsed configuration overlay
+type Overlay struct {
+	// Origin is the file the overlay was read from
+	Origin string
+
+	merge map[string]any
+	ops   []Operation
+}
+
+// Operation is a JSON patch operation
+type Operation struct {
+	Op    string `yaml:"op"`
+	Path  string `yaml:"path"`
+	From  string `yaml:"from,omitempty"`
+	Value any    `yaml:"value,omitempty"`
+}
+
+// Parse parses an overlay. A mapping is a strategic merge patch and a list is a JSON patch.
+func Parse(data []byte, origin string) (*Overlay, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse overlay %s: %w", origin, err)
+	}
+	o := &Overlay{Origin: origin}
+	switch v := normalize(doc).(type) {
+	case nil:
+		return o, nil
+	case map[string]any:
+		o.merge = v
+	case []any:
+		if err := yaml.UnmarshalStrict(data, &o.ops); err != nil {
+			return nil, fmt.Errorf("parse overlay %s: %w", origin, err)
+		}
+		for i, op := range o.ops {
+			o.ops[i].Value = normalize(op.Value)
+		}
+	default:
+		return nil, fmt.Errorf("parse overlay %s: expected a mapping or a list of patch operations", origin)
+	}
+	return o, nil
+}
+
+// Apply applies the overlay to the YAML document and returns the result
+func (o *Overlay) Apply(data []byte) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse document: %w", err)
+	}
+	result := normalize(doc)
+
+	var err error
+	switch {
+	case o.merge != nil:
+		result, err = mergeValue(result, o.merge, nil)
+	case len(o.ops) > 0:
+		for _, op := range o.ops {
+			result, err = applyOperation(result, op)
+			if err != nil {
+				break
+			}
+		}
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("apply overlay %s: %w", o.Origin, err)
+	}
+
+	out, err := yaml.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("encode document: %w", err)
+	}
+	return out, nil
+}
+
+// normalize converts the mappings decoded by yaml.v2 into maps with string keys
+func normalize(v any) any {
+	switch n := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(n))
+		for k, v := range n {
+			m[fmt.Sprint(k)] = normalize(v)
+		}
+		return m
+	case map[string]any:
+		for k, v := range n {
+			n[k] = normalize(v)
+		}
+		return n
+	case []any:
+		for i, v := range n {
+			n[i] = normalize(v)
+		}
+		return n
+	default:
+		return v
+	}
+}
+
+// deepCopy returns a copy of a normalized value
+func deepCopy(v any) any {
+	switch n := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(n))
+		for k, v := range n {
+			m[k] = deepCopy(v)
+		}
+		return m
+	case []any:
+		l := make([]any, len(n))
+		for i, v := range n {
+			l[i] = deepCopy(v)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// isHostList returns true when the path points to spec.hosts
+func isHostList(path []string) bool {
+	return len(path) == 2 && path[0] == "spec" && path[1] == "hosts"
+}
+
+// mergeValue merges the patch into the value. Mappings are merged recursively, a nil value in the
+// patch removes the key, the hosts are merged by address or role and other lists are replaced.
+func mergeValue(dst, patch any, path []string) (any, error) {
+	switch p := patch.(type) {
+	case map[string]any:
+		directive, _ := p[patchDirective].(string)
+		switch directive {
+		case "":
+		case "replace":
+			c := deepCopy(p).(map[string]any)
+			delete(c, patchDirective)
+			return c, nil
+		default:
+			return nil, fmt.Errorf("%s: unsupported %s directive %q", strings.Join(path, "."), patchDirective, directive)
+		}
+		d, ok := dst.(map[string]any)
+		if !ok {
+			d = make(map[string]any, len(p))
+		}
+		for k, v := range p {
+			if v == nil {
+				delete(d, k)
+				continue
+			}
+			merged, err := mergeValue(d[k], v, append(path, k))
+			if err != nil {
+				return nil, err
+			}
+			d[k] = merged
+		}
+		return d, nil
+	case []any:
+		if isHostList(path) {
+			d, _ := dst.([]any)
+			return mergeHosts(d, p)
+		}
+		return deepCopy(p), nil
+	default:
+		return p, nil
+	}
+}
+
+// mergeHosts merges the host entries of the patch into the hosts. An entry with an address is
+// merged into the host with the same address or added as a new host when there is none. An entry
+// without an address is merged into all the hosts with the same role.
+func mergeHosts(hosts []any, patch []any) ([]any, error) {
+	for i, item := range patch {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("spec.hosts[%d]: expected a mapping", i)
+		}
+		selector, matches := selectHosts(hosts, entry)
+		if selector == "" {
+			return nil, fmt.Errorf("spec.hosts[%d]: an address or a role is required to select the hosts", i)
+		}
+		directive, _ := entry[patchDirective].(string)
+
+		if len(matches) == 0 {
+			if directive != "" || !strings.HasPrefix(selector, "address=") {
+				return nil, fmt.Errorf("spec.hosts[%d]: no hosts match %s", i, selector)
+			}
+			hosts = append(hosts, deepCopy(entry))
+			continue
+		}
+
+		if directive == "delete" {
+			kept := make([]any, 0, len(hosts))
+			for j, h := range hosts {
+				if !contains(matches, j) {
+					kept = append(kept, h)
+				}
+			}
+			hosts = kept
+			continue
+		}
+
+		for _, j := range matches {
+			merged, err := mergeValue(hosts[j], entry, []string{"spec", "hosts", strconv.Itoa(j)})
+			if err != nil {
+				return nil, err
+			}
+			hosts[j] = merged
+		}
+	}
+	return hosts, nil
+}
+
+// selectHosts returns the selector of the host entry and the indexes of the hosts it matches
+func selectHosts(hosts []any, entry map[string]any) (string, []int) {
+	var key, value string
+	if addr := hostAddress(entry); addr != "" {
+		key, value = "address", addr
+	} else if role, ok := entry["role"].(string); ok && role != "" {
+		key, value = "role", role
+	} else {
+		return "", nil
+	}
+	return key + "=" + value, matchHosts(hosts, key, value)
+}
+
+// matchHosts returns the indexes of the hosts that have the given address or role
+func matchHosts(hosts []any, key, value string) []int {
+	var matches []int
+	for i, item := range hosts {
+		h, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch key {
+		case "address":
+			if hostAddress(h) == value {
+				matches = append(matches, i)
+			}
+		case "role":
+			if role, _ := h["role"].(string); role == value {
+				matches = append(matches, i)
+			}
+		}
+	}
+	return matches
+}
+
+// hostAddress returns the address of the host from its connection configuration
+func hostAddress(h map[string]any) string {
+	for _, proto := range []string{"ssh", "winRM", "openSSH"} {
+		if c, ok := h[proto].(map[string]any); ok {
+			if addr, ok := c["address"].(string); ok && addr != "" {
+				return addr
+			}
+		}
+	}
+	if c, ok := h["localhost"].(map[string]any); ok {
+		if enabled, _ := c["enabled"].(bool); enabled {
+			return "localhost"
+		}
+	}
+	return ""
+}
+
+func contains(list []int, i int) bool {
+	for _, v := range list {
+		if v == i {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOperation applies a JSON patch operation to the document. In addition to the RFC 6901
+// array indexes, the hosts can be selected with address=<address> and role=<role> path segments.
+// A role selector can match multiple hosts, the operation is then applied to each of them.
+func applyOperation(doc any, op Operation) (any, error) {
+	paths, err := expandPath(doc, op.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+	}
+	for _, path := range paths {
+		doc, err = applyToPath(doc, op, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyToPath(doc any, op Operation, path []string) (any, error) {
+	switch op.Op {
+	case "add":
+		return add(doc, path, deepCopy(op.Value))
+	case "remove":
+		doc, _, err := remove(doc, path)
+		return doc, err
+	case "replace":
+		if _, err := get(doc, path); err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(op.Value))
+	case "move", "copy":
+		from, err := expandPath(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(from) != 1 {
+			return nil, fmt.Errorf("from %s must select exactly one value", op.From)
+		}
+		var value any
+		if op.Op == "move" {
+			doc, value, err = remove(doc, from[0])
+		} else {
+			value, err = get(doc, from[0])
+			value = deepCopy(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "test":
+		value, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, op.Value) {
+			return nil, fmt.Errorf("test failed, value is %v", value)
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unsupported operation %q", op.Op)
+	}
+}
+
+// expandPath splits the JSON pointer into its tokens and resolves the host selectors into indexes
+func expandPath(doc any, pointer string) ([][]string, error) {
+	if pointer == "" {
+		return [][]string{{}}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %q, must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	if len(tokens) < 3 || !isHostList(tokens[:2]) {
+		return [][]string{tokens}, nil
+	}
+	key, value, ok := strings.Cut(tokens[2], "=")
+	if !ok || (key != "address" && key != "role") {
+		return [][]string{tokens}, nil
+	}
+	hosts, err := get(doc, tokens[:2])
+	if err != nil {
+		return nil, err
+	}
+	list, _ := hosts.([]any)
+	matches := matchHosts(list, key, value)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no hosts match %s", tokens[2])
+	}
+	paths := make([][]string, 0, len(matches))
+	// the indexes are processed from the last one so that removing a host does not shift the others
+	for i := len(matches) - 1; i >= 0; i-- {
+		p := append([]string{tokens[0], tokens[1], strconv.Itoa(matches[i])}, tokens[3:]...)
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// get returns the value at the path
+func get(doc any, path []string) (any, error) {
+	node := doc
+	for i, t := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			v, ok := n[t]
+			if !ok {
+				return nil, fmt.Errorf("/%s not found", strings.Join(path[:i+1], "/"))
+			}
+			node = v
+		case []any:
+			idx, err := index(t, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[idx]
+		default:
+			return nil, fmt.Errorf("/%s is not a mapping or a list", strings.Join(path[:i], "/"))
+		}
+	}
+	return node, nil
+}
+
+// update calls fn with the container of the last token of the path and replaces the container
+// with the value it returns
+func update(node any, path []string, fn func(container any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("%s not found", path[0])
+		}
+		c, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = c
+		return n, nil
+	case []any:
+		idx, err := index(path[0], len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		c, err := update(n[idx], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = c
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%s is not a mapping or a list", path[0])
+	}
+}
+
+// add sets the value at the path, an index or - in a list inserts the value
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case map[string]any:
+			c[token] = value
+			return c, nil
+		case []any:
+			if token == "-" {
+				return append(c, value), nil
+			}
+			idx, err := index(token, len(c))
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:idx], append([]any{value}, c[idx:]...)...), nil
+		default:
+			return nil, fmt.Errorf("can not add %s, parent is not a mapping or a list", token)
+		}
+	})
+}
+
+// remove removes the value at the path and returns it
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("can not remove the document")
+	}
+	var removed any
+	doc, err := update(doc, path, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case map[string]any:
+			v, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", token)
+			}
+			removed = v
+			delete(c, token)
+			return c, nil
+		case []any:
+			idx, err := index(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = c[idx]
+			return append(c[:idx], c[idx+1:]...), nil
+		default:
+			return nil, fmt.Errorf("can not remove %s, parent is not a mapping or a list", token)
+		}
+	})
+	return doc, removed, err
+}
+
+// index parses a list index token, the index must be between 0 and maxIndex
+func index(token string, maxIndex int) (int, error) {
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || idx > maxIndex || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid list index %q", token)
+	}
+	return idx, nil
+}
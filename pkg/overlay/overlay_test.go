@@ -0,0 +1,153 @@
+package overlay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+const base = `apiVersion: k0sctl.k0sproject.io/v1beta1
+kind: Cluster
+metadata:
+  name: staging
+spec:
+  hosts:
+    - role: controller
+      ssh:
+        address: 10.0.0.1
+    - role: worker
+      ssh:
+        address: 10.0.0.2
+    - role: worker
+      ssh:
+        address: 10.0.0.3
+  k0s:
+    version: 1.30.0+k0s.0
+`
+
+func apply(t *testing.T, overlay string) map[string]any {
+	t.Helper()
+	o, err := Parse([]byte(overlay), "overlay.yaml")
+	require.NoError(t, err)
+	out, err := o.Apply([]byte(base))
+	require.NoError(t, err)
+	var doc any
+	require.NoError(t, yaml.Unmarshal(out, &doc))
+	return normalize(doc).(map[string]any)
+}
+
+func hosts(doc map[string]any) []any {
+	return doc["spec"].(map[string]any)["hosts"].([]any)
+}
+
+func TestStrategicMerge(t *testing.T) {
+	doc := apply(t, `
+metadata:
+  name: production
+spec:
+  hosts:
+    - role: worker
+      environment:
+        TZ: UTC
+    - ssh:
+        address: 10.0.0.3
+        user: admin
+    - ssh:
+        address: 10.0.0.4
+      role: worker
+    - ssh:
+        address: 10.0.0.1
+      $patch: delete
+  k0s:
+    version: null
+`)
+	require.Equal(t, "production", doc["metadata"].(map[string]any)["name"])
+	require.NotContains(t, doc["spec"].(map[string]any)["k0s"], "version")
+
+	h := hosts(doc)
+	require.Len(t, h, 3)
+	w1 := h[0].(map[string]any)
+	require.Equal(t, "10.0.0.2", hostAddress(w1))
+	require.Equal(t, map[string]any{"TZ": "UTC"}, w1["environment"])
+	w2 := h[1].(map[string]any)
+	require.Equal(t, map[string]any{"address": "10.0.0.3", "user": "admin"}, w2["ssh"])
+	require.Equal(t, map[string]any{"TZ": "UTC"}, w2["environment"])
+	w3 := h[2].(map[string]any)
+	require.Equal(t, "10.0.0.4", hostAddress(w3))
+	require.NotContains(t, w3, "environment")
+}
+
+func TestStrategicMergeErrors(t *testing.T) {
+	for name, overlay := range map[string]string{
+		"no selector":    "spec:\n  hosts:\n    - environment: {TZ: UTC}\n",
+		"no role match":  "spec:\n  hosts:\n    - role: controller+worker\n      environment: {TZ: UTC}\n",
+		"delete missing": "spec:\n  hosts:\n    - ssh: {address: 10.0.0.9}\n      $patch: delete\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			o, err := Parse([]byte(overlay), "overlay.yaml")
+			require.NoError(t, err)
+			_, err = o.Apply([]byte(base))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	doc := apply(t, `
+- op: test
+  path: /metadata/name
+  value: staging
+- op: replace
+  path: /metadata/name
+  value: production
+- op: add
+  path: /spec/hosts/role=worker/environment
+  value:
+    TZ: UTC
+- op: add
+  path: /spec/hosts/address=10.0.0.1/installFlags
+  value: [--debug]
+- op: remove
+  path: /spec/hosts/address=10.0.0.3
+- op: add
+  path: /spec/hosts/-
+  value:
+    role: worker
+    ssh:
+      address: 10.0.0.5
+- op: copy
+  from: /spec/k0s/version
+  path: /metadata/version
+- op: move
+  from: /metadata/version
+  path: /spec/k0s/previousVersion
+`)
+	require.Equal(t, "production", doc["metadata"].(map[string]any)["name"])
+	k0s := doc["spec"].(map[string]any)["k0s"].(map[string]any)
+	require.Equal(t, "1.30.0+k0s.0", k0s["previousVersion"])
+	require.NotContains(t, doc["metadata"], "version")
+
+	h := hosts(doc)
+	require.Len(t, h, 3)
+	require.Equal(t, []any{"--debug"}, h[0].(map[string]any)["installFlags"])
+	require.Equal(t, map[string]any{"TZ": "UTC"}, h[1].(map[string]any)["environment"])
+	require.Equal(t, "10.0.0.5", hostAddress(h[2].(map[string]any)))
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	for name, overlay := range map[string]string{
+		"failed test":    `[{"op": "test", "path": "/metadata/name", "value": "production"}]`,
+		"missing path":   `[{"op": "replace", "path": "/spec/missing", "value": 1}]`,
+		"no host match":  `[{"op": "remove", "path": "/spec/hosts/address=10.0.0.9"}]`,
+		"invalid index":  `[{"op": "remove", "path": "/spec/hosts/3"}]`,
+		"unsupported op": `[{"op": "merge", "path": "/spec"}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			o, err := Parse([]byte(overlay), "overlay.json")
+			require.NoError(t, err)
+			_, err = o.Apply([]byte(base))
+			require.Error(t, err)
+		})
+	}
+}
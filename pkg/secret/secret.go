@@ -0,0 +1,271 @@
+// Package secret resolves secret references in the k0sctl configuration and keeps track of the
+// resolved values so that they can be masked in the output.
+package secret
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/k0sproject/rig/v2/cmd"
+	"gopkg.in/yaml.v2"
+)
+
+// Mask is the replacement for the secret values in the output
+const Mask = "[REDACTED]"
+
+// minMaskLength is the length of the shortest value that is masked, masking shorter values would
+// mangle unrelated output
+const minMaskLength = 4
+
+var (
+	mu     sync.Mutex
+	values []string
+	refs   = make(map[string]string) // resolved value -> secret reference
+)
+
+// refKey is the key of the mapping that refers to a secret
+const refKey = "secretRef"
+
+// Resolve replaces the secret references in a YAML document decoded into a yaml.MapSlice or
+// an interface{} with the referenced values. A reference is a mapping with a single secretRef
+// key, either in the flow style:
+//
+//	password: {secretRef: "file://ssh-password.txt"}
+//
+// or in the block style:
+//
+//	password:
+//	  secretRef: exec://pass show k0s/ssh
+//
+// The mappings and lists of the document are updated in place and the resolved document is
+// returned. Relative file paths are resolved relative to baseDir. The resolved values are
+// registered as sensitive.
+func Resolve(ctx context.Context, doc any, baseDir string) (any, error) {
+	return resolve(ctx, doc, "", baseDir)
+}
+
+func resolve(ctx context.Context, node any, path, baseDir string) (any, error) {
+	switch node := node.(type) {
+	case yaml.MapSlice:
+		if len(node) == 1 && node[0].Key == refKey {
+			return resolveNode(ctx, node[0].Value, path, baseDir)
+		}
+		for i, item := range node {
+			value, err := resolve(ctx, item.Value, joinPath(path, fmt.Sprint(item.Key)), baseDir)
+			if err != nil {
+				return nil, err
+			}
+			node[i].Value = value
+		}
+	case map[any]any:
+		if ref, ok := node[refKey]; ok && len(node) == 1 {
+			return resolveNode(ctx, ref, path, baseDir)
+		}
+		for key, item := range node {
+			value, err := resolve(ctx, item, joinPath(path, fmt.Sprint(key)), baseDir)
+			if err != nil {
+				return nil, err
+			}
+			node[key] = value
+		}
+	case []any:
+		for i, item := range node {
+			value, err := resolve(ctx, item, fmt.Sprintf("%s[%d]", path, i), baseDir)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = value
+		}
+	}
+	return node, nil
+}
+
+// resolveNode resolves the value of a secretRef key
+func resolveNode(ctx context.Context, ref any, path, baseDir string) (string, error) {
+	s, ok := ref.(string)
+	if !ok {
+		return "", fmt.Errorf("%s: the secret reference must be a string", path)
+	}
+	value, err := resolveRef(ctx, s, baseDir)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return value, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// resolveRef returns the value of a file://, exec:// or sops:// reference
+func resolveRef(ctx context.Context, ref, baseDir string) (string, error) {
+	scheme, target, ok := strings.Cut(ref, "://")
+	if !ok || target == "" {
+		return "", fmt.Errorf("invalid secret reference %q, expected file://, exec:// or sops://", ref)
+	}
+
+	var value string
+	switch scheme {
+	case "file":
+		data, err := os.ReadFile(resolvePath(target, baseDir))
+		if err != nil {
+			return "", fmt.Errorf("read secret: %w", err)
+		}
+		value = string(data)
+	case "exec":
+		out, err := run(ctx, baseDir, shell(target)...)
+		if err != nil {
+			return "", fmt.Errorf("run secret command: %w", err)
+		}
+		value = out
+	case "sops":
+		path, key, _ := strings.Cut(target, "#")
+		args := []string{"sops", "--decrypt"}
+		if key != "" {
+			args = append(args, "--extract", sopsExtractPath(key))
+		}
+		out, err := run(ctx, baseDir, append(args, resolvePath(path, baseDir))...)
+		if err != nil {
+			return "", fmt.Errorf("decrypt secret: %w", err)
+		}
+		value = out
+	default:
+		return "", fmt.Errorf("unsupported secret reference scheme %q, expected file://, exec:// or sops://", scheme)
+	}
+
+	value = strings.TrimRight(value, "\r\n")
+	Register(value)
+	mu.Lock()
+	refs[value] = ref
+	mu.Unlock()
+	return value, nil
+}
+
+// Reference returns the secret reference that the value was resolved from
+func Reference(value string) (string, bool) {
+	if value == "" {
+		return "", false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	ref, ok := refs[value]
+	return ref, ok
+}
+
+// Unresolve replaces the values in a YAML document decoded into a yaml.MapSlice or an
+// interface{} that were resolved from secret references with the references, so that the
+// document can be written out without the secret values. The document is updated in place and
+// returned.
+func Unresolve(doc any) any {
+	switch node := doc.(type) {
+	case yaml.MapSlice:
+		for i, item := range node {
+			node[i].Value = Unresolve(item.Value)
+		}
+	case map[any]any:
+		for key, item := range node {
+			node[key] = Unresolve(item)
+		}
+	case []any:
+		for i, item := range node {
+			node[i] = Unresolve(item)
+		}
+	case string:
+		if ref, ok := Reference(node); ok {
+			return yaml.MapSlice{{Key: refKey, Value: ref}}
+		}
+	}
+	return doc
+}
+
+func resolvePath(path, baseDir string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	if !filepath.IsAbs(path) && baseDir != "" {
+		return filepath.Join(baseDir, path)
+	}
+	return path
+}
+
+// sopsExtractPath converts a dotted key path such as ssh.password into the sops --extract format
+func sopsExtractPath(key string) string {
+	var sb strings.Builder
+	for _, part := range strings.Split(key, ".") {
+		sb.WriteString(`["` + part + `"]`)
+	}
+	return sb.String()
+}
+
+func shell(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
+
+func run(ctx context.Context, dir string, args ...string) (string, error) {
+	c := exec.CommandContext(ctx, args[0], args[1:]...)
+	c.Dir = dir
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// Register marks the value as sensitive so that it is masked by Redact
+func Register(value string) {
+	mu.Lock()
+	defer mu.Unlock()
+	add := func(v string) {
+		if len(v) < minMaskLength {
+			return
+		}
+		if !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	add(value)
+	// multi-line values can be output one line at a time
+	if strings.Contains(value, "\n") {
+		for _, line := range strings.Split(value, "\n") {
+			add(strings.TrimSpace(line))
+		}
+	}
+}
+
+// Redact replaces the registered sensitive values in the string with Mask unless redaction has
+// been disabled with --no-redact
+func Redact(s string) string {
+	if cmd.DisableRedact {
+		return s
+	}
+	mu.Lock()
+	sorted := slices.Clone(values)
+	mu.Unlock()
+	// replace the longest values first so that a value containing another one is fully masked
+	slices.SortFunc(sorted, func(a, b string) int { return len(b) - len(a) })
+	for _, v := range sorted {
+		s = strings.ReplaceAll(s, v, Mask)
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package secret
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k0sproject/rig/v2/cmd"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "password.txt"), []byte("s3cr\"et-file\n"), 0o600))
+
+	content := []byte(`spec:
+  hosts:
+    - role: controller
+      ssh:
+        address: 10.0.0.1
+        password: {secretRef: "file://password.txt"}
+      environment:
+        TOKEN:
+          secretRef: exec://echo token-from-exec
+        PLAIN: value
+      files:
+        - name: config
+          data:
+            secretRef: 'exec://printf "line1\nline2\n"'
+          dst: /etc/config
+`)
+	var doc yaml.MapSlice
+	require.NoError(t, yaml.Unmarshal(content, &doc))
+	resolved, err := Resolve(context.Background(), doc, dir)
+	require.NoError(t, err)
+	data, err := yaml.Marshal(resolved)
+	require.NoError(t, err)
+
+	var cfg struct {
+		Spec struct {
+			Hosts []struct {
+				SSH struct {
+					Password string `yaml:"password"`
+				} `yaml:"ssh"`
+				Environment map[string]string `yaml:"environment"`
+				Files       []struct {
+					Data string `yaml:"data"`
+				} `yaml:"files"`
+			} `yaml:"hosts"`
+		} `yaml:"spec"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &cfg))
+	h := cfg.Spec.Hosts[0]
+	require.Equal(t, `s3cr"et-file`, h.SSH.Password)
+	require.Equal(t, "token-from-exec", h.Environment["TOKEN"])
+	require.Equal(t, "value", h.Environment["PLAIN"])
+	require.Equal(t, "line1\nline2", h.Files[0].Data)
+
+	out := Redact("password: token-from-exec, data: line2")
+	require.Equal(t, "password: [REDACTED], data: [REDACTED]", out)
+
+	cmd.DisableRedact = true
+	t.Cleanup(func() { cmd.DisableRedact = false })
+	require.Equal(t, "token-from-exec", Redact("token-from-exec"))
+}
+
+func TestUnresolve(t *testing.T) {
+	content := []byte(`ssh:
+  password: {secretRef: "exec://echo unresolve-password"}
+  user: root
+`)
+	var doc yaml.MapSlice
+	require.NoError(t, yaml.Unmarshal(content, &doc))
+	resolved, err := Resolve(context.Background(), doc, t.TempDir())
+	require.NoError(t, err)
+	data, err := yaml.Marshal(resolved)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "unresolve-password")
+
+	var decoded any
+	require.NoError(t, yaml.Unmarshal(data, &decoded))
+	data, err = yaml.Marshal(Unresolve(decoded))
+	require.NoError(t, err)
+	require.YAMLEq(t, string(content), string(data))
+}
+
+func TestResolveLeavesOtherMappings(t *testing.T) {
+	content := []byte(`ref:
+  secretRef: file://password.txt
+  other: value
+text: "secretRef: file://password.txt"
+`)
+	var doc any
+	require.NoError(t, yaml.Unmarshal(content, &doc))
+	resolved, err := Resolve(context.Background(), doc, t.TempDir())
+	require.NoError(t, err)
+	data, err := yaml.Marshal(resolved)
+	require.NoError(t, err)
+	require.YAMLEq(t, string(content), string(data))
+}
+
+func TestResolveErrors(t *testing.T) {
+	resolve := func(content, baseDir string) error {
+		var doc any
+		require.NoError(t, yaml.Unmarshal([]byte(content), &doc))
+		_, err := Resolve(context.Background(), doc, baseDir)
+		return err
+	}
+
+	require.ErrorContains(t, resolve("password: {secretRef: vault://foo}\n", ""), `password: unsupported secret reference scheme "vault"`)
+	require.ErrorContains(t, resolve("password: {secretRef: file://missing.txt}\n", t.TempDir()), "password: read secret")
+	require.ErrorContains(t, resolve("hosts:\n  - ssh:\n      password:\n        secretRef: exec://exit 1\n", ""), "hosts[0].ssh.password: run secret command")
+	require.ErrorContains(t, resolve("password: {secretRef: [a]}\n", ""), "password: the secret reference must be a string")
+}
+
+func TestSopsExtractPath(t *testing.T) {
+	require.Equal(t, `["ssh"]["password"]`, sopsExtractPath("ssh.password"))
+}